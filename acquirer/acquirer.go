@@ -0,0 +1,71 @@
+package acquirer
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrOperationNotFound is returned by Lookup and LookupAuthorization when the bank never received the operation
+	ErrOperationNotFound = errors.New("operation not found at the acquirer")
+	// ErrNotSent is returned when the request was not sent at all because the bank keeps failing
+	ErrNotSent = errors.New("acquirer unavailable, the request was not sent")
+)
+
+// Acquirer is the acquiring bank the gateway forwards card payments to.
+// A declined operation is not an error, errors are reserved for failures to reach the bank.
+type Acquirer interface {
+	Authorize(ctx context.Context, request AuthorizationRequest) (Response, error)
+	Capture(ctx context.Context, authorizationReference string, request AmountRequest) (Response, error)
+	Void(ctx context.Context, authorizationReference string, request VoidRequest) (Response, error)
+	Refund(ctx context.Context, authorizationReference string, request AmountRequest) (Response, error)
+	Transfer(ctx context.Context, request TransferRequest) (Response, error)
+	// Lookup returns the outcome of the latest capture, void or refund sent with the operation reference
+	Lookup(ctx context.Context, operationReference string) (Response, error)
+	// LookupAuthorization returns the outcome of the latest authorization sent with the merchant reference
+	LookupAuthorization(ctx context.Context, merchantReference string) (Response, error)
+}
+
+// AuthorizationRequest asks the bank to hold Amount on the card
+type AuthorizationRequest struct {
+	MerchantReference string `json:"merchant_reference"`
+	CardNumber        string `json:"card_number"`
+	ExpMonth          int    `json:"expiry_month"`
+	ExpYear           int    `json:"expiry_year"`
+	CVV               int    `json:"cvv"`
+	Amount            int64  `json:"amount"`
+	Currency          string `json:"currency"`
+	// StoredCredential marks payments with a card stored in the vault, they may come without a CVV
+	StoredCredential bool `json:"stored_credential,omitempty"`
+	// StatementDescriptor is shown on the cardholder's statement instead of the merchant name
+	StatementDescriptor string `json:"statement_descriptor,omitempty"`
+}
+
+// AmountRequest carries the amount of a capture or refund, in minor units. OperationReference is the
+// gateway's id of the operation, it finds the outcome again with Lookup.
+type AmountRequest struct {
+	OperationReference string `json:"operation_reference,omitempty"`
+	Amount             int64  `json:"amount"`
+}
+
+// VoidRequest releases the hold of an authorization, OperationReference works as for AmountRequest
+type VoidRequest struct {
+	OperationReference string `json:"operation_reference,omitempty"`
+}
+
+// TransferRequest asks the bank to pay Amount out to a merchant. Reference identifies the payout,
+// the bank makes a single transfer per reference however often it is sent.
+type TransferRequest struct {
+	Reference   string `json:"reference"`
+	Beneficiary string `json:"beneficiary"`
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency"`
+}
+
+// Response is the outcome of an operation at the bank
+type Response struct {
+	Approved      bool   `json:"approved"`
+	Reference     string `json:"reference"`
+	DeclineCode   string `json:"decline_code,omitempty"`
+	DeclineReason string `json:"decline_reason,omitempty"`
+}
@@ -0,0 +1,77 @@
+package acquirer
+
+import (
+	"context"
+	"errors"
+	"github.com/sony/gobreaker"
+)
+
+// breakerAcquirer sends every call through a circuit breaker, so a bank that stopped answering is not waited
+// for on every payment. The breaker opens after consecutive failures to reach the bank and fails calls with
+// ErrNotSent until it lets a trial call through again.
+type breakerAcquirer struct {
+	next    Acquirer
+	breaker *gobreaker.CircuitBreaker
+}
+
+// NewCircuitBreaker wraps the acquirer in a circuit breaker of its own named name
+func NewCircuitBreaker(name string, next Acquirer) Acquirer {
+	return &breakerAcquirer{
+		next: next,
+		breaker: gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name:         name,
+			IsSuccessful: bankAnswered,
+		}),
+	}
+}
+
+// bankAnswered tells whether the bank took part in a call. Declines are no errors, and operations the bank
+// does not know or requests it refused are answers too, only failures to reach the bank count against it.
+func bankAnswered(err error) bool {
+	if err == nil || errors.Is(err, ErrOperationNotFound) {
+		return true
+	}
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode < 500
+}
+
+func (a *breakerAcquirer) call(operation func() (Response, error)) (Response, error) {
+	response, err := a.breaker.Execute(func() (interface{}, error) {
+		return operation()
+	})
+	if err == gobreaker.ErrOpenState || err == gobreaker.ErrTooManyRequests {
+		return Response{}, ErrNotSent
+	}
+	if response == nil {
+		return Response{}, err
+	}
+	return response.(Response), err
+}
+
+func (a *breakerAcquirer) Authorize(ctx context.Context, request AuthorizationRequest) (Response, error) {
+	return a.call(func() (Response, error) { return a.next.Authorize(ctx, request) })
+}
+
+func (a *breakerAcquirer) Capture(ctx context.Context, authorizationReference string, request AmountRequest) (Response, error) {
+	return a.call(func() (Response, error) { return a.next.Capture(ctx, authorizationReference, request) })
+}
+
+func (a *breakerAcquirer) Void(ctx context.Context, authorizationReference string, request VoidRequest) (Response, error) {
+	return a.call(func() (Response, error) { return a.next.Void(ctx, authorizationReference, request) })
+}
+
+func (a *breakerAcquirer) Refund(ctx context.Context, authorizationReference string, request AmountRequest) (Response, error) {
+	return a.call(func() (Response, error) { return a.next.Refund(ctx, authorizationReference, request) })
+}
+
+func (a *breakerAcquirer) Transfer(ctx context.Context, request TransferRequest) (Response, error) {
+	return a.call(func() (Response, error) { return a.next.Transfer(ctx, request) })
+}
+
+func (a *breakerAcquirer) Lookup(ctx context.Context, operationReference string) (Response, error) {
+	return a.call(func() (Response, error) { return a.next.Lookup(ctx, operationReference) })
+}
+
+func (a *breakerAcquirer) LookupAuthorization(ctx context.Context, merchantReference string) (Response, error) {
+	return a.call(func() (Response, error) { return a.next.LookupAuthorization(ctx, merchantReference) })
+}
@@ -0,0 +1,55 @@
+package acquirer
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBankAnswered(t *testing.T) {
+	// Test case 1: answers of the bank, declines and refused requests among them, do not count as failures
+	assert.True(t, bankAnswered(nil))
+	assert.True(t, bankAnswered(ErrOperationNotFound))
+	assert.True(t, bankAnswered(&StatusError{StatusCode: http.StatusBadRequest}))
+
+	// Test case 2: failures to reach the bank do
+	assert.False(t, bankAnswered(&StatusError{StatusCode: http.StatusServiceUnavailable}))
+	assert.False(t, bankAnswered(errors.New("acquirer unreachable: connection refused")))
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	calls := 0
+	status := http.StatusBadRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	bank := NewCircuitBreaker("bank", NewHTTPAcquirer(server.URL, time.Second))
+
+	// Test case 1: requests the bank refuses never open the breaker
+	for i := 0; i < 10; i++ {
+		_, err := bank.Void(context.Background(), "auth_1", VoidRequest{})
+		assert.Error(t, err)
+	}
+	assert.Equal(t, 10, calls)
+
+	// Test case 2: after consecutive server errors the bank is not called any more
+	status = http.StatusServiceUnavailable
+	for i := 0; i < 6; i++ {
+		_, _ = bank.Void(context.Background(), "auth_1", VoidRequest{})
+	}
+	_, err := bank.Void(context.Background(), "auth_1", VoidRequest{})
+	assert.Equal(t, ErrNotSent, err)
+	assert.Equal(t, 16, calls)
+
+	// Test case 3: every bank has a breaker of its own
+	other := NewCircuitBreaker("test bank", NewHTTPAcquirer(server.URL, time.Second))
+	_, err = other.Void(context.Background(), "auth_1", VoidRequest{})
+	assert.NotEqual(t, ErrNotSent, err)
+}
@@ -0,0 +1,47 @@
+package acquirer
+
+// Decline codes reported when an operation is not approved
+const (
+	DeclineInvalidCard         = "invalid_card"
+	DeclineInsufficientFunds   = "insufficient_funds"
+	DeclineSuspectedFraud      = "suspected_fraud"
+	DeclineDoNotHonour         = "do_not_honour"
+	DeclineStolenCard          = "stolen_card"
+	DeclineExpiredCard         = "expired_card"
+	DeclineIssuerUnavailable   = "issuer_unavailable"
+	DeclineInvalidRequest      = "invalid_request"
+	DeclineAcquirerUnavailable = "acquirer_unavailable"
+)
+
+// Decline categories. Soft declines may be approved when retried later,
+// hard declines will not be and the shopper should use another card.
+const (
+	CategorySoft = "soft"
+	CategoryHard = "hard"
+)
+
+var declineCategories = map[string]string{
+	DeclineInvalidCard:         CategoryHard,
+	DeclineInsufficientFunds:   CategorySoft,
+	DeclineSuspectedFraud:      CategoryHard,
+	DeclineDoNotHonour:         CategorySoft,
+	DeclineStolenCard:          CategoryHard,
+	DeclineExpiredCard:         CategoryHard,
+	DeclineIssuerUnavailable:   CategorySoft,
+	DeclineInvalidRequest:      CategoryHard,
+	DeclineAcquirerUnavailable: CategorySoft,
+}
+
+// DeclineCategory tells whether a decline code is worth retrying. Unknown codes are treated as hard declines.
+func DeclineCategory(code string) string {
+	if category, ok := declineCategories[code]; ok {
+		return category
+	}
+	return CategoryHard
+}
+
+// DeclineRetryable tells whether the same payment may be tried again with the same card, which is the case
+// for soft declines: the issuer or the acquirer could not answer, or turned down this attempt only.
+func DeclineRetryable(code string) bool {
+	return DeclineCategory(code) == CategorySoft
+}
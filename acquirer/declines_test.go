@@ -0,0 +1,18 @@
+package acquirer
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDeclineRetryable(t *testing.T) {
+	// Test case 1: soft declines are retryable
+	for _, code := range []string{DeclineAcquirerUnavailable, DeclineDoNotHonour, DeclineIssuerUnavailable, DeclineInsufficientFunds} {
+		assert.True(t, DeclineRetryable(code), code)
+	}
+
+	// Test case 2: hard declines, unknown codes and approvals are not
+	for _, code := range []string{DeclineStolenCard, DeclineExpiredCard, DeclineInvalidCard, "unknown_code", ""} {
+		assert.False(t, DeclineRetryable(code), code)
+	}
+}
@@ -0,0 +1,107 @@
+package acquirer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// StatusError is returned when the bank answers with another status than 200. Statuses below 500 mean the bank
+// refused the request itself, such as an unknown authorization.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("acquirer responded with status %d", e.StatusCode)
+}
+
+// HTTPAcquirer talks to the bank over its HTTP API, see cmd/bank-simulator
+type HTTPAcquirer struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewHTTPAcquirer creates a client for the bank at baseURL. Every call gives up after timeout.
+func NewHTTPAcquirer(baseURL string, timeout time.Duration) *HTTPAcquirer {
+	return &HTTPAcquirer{
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (a *HTTPAcquirer) Authorize(ctx context.Context, request AuthorizationRequest) (Response, error) {
+	return a.post(ctx, "/authorizations", request)
+}
+
+func (a *HTTPAcquirer) Capture(ctx context.Context, authorizationReference string, request AmountRequest) (Response, error) {
+	return a.post(ctx, "/authorizations/"+url.PathEscape(authorizationReference)+"/capture", request)
+}
+
+func (a *HTTPAcquirer) Void(ctx context.Context, authorizationReference string, request VoidRequest) (Response, error) {
+	return a.post(ctx, "/authorizations/"+url.PathEscape(authorizationReference)+"/void", request)
+}
+
+func (a *HTTPAcquirer) Refund(ctx context.Context, authorizationReference string, request AmountRequest) (Response, error) {
+	return a.post(ctx, "/authorizations/"+url.PathEscape(authorizationReference)+"/refunds", request)
+}
+
+func (a *HTTPAcquirer) Transfer(ctx context.Context, request TransferRequest) (Response, error) {
+	return a.post(ctx, "/transfers", request)
+}
+
+func (a *HTTPAcquirer) Lookup(ctx context.Context, operationReference string) (Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.baseURL+"/operations/"+url.PathEscape(operationReference), nil)
+	if err != nil {
+		return Response{}, err
+	}
+	return a.do(req)
+}
+
+func (a *HTTPAcquirer) LookupAuthorization(ctx context.Context, merchantReference string) (Response, error) {
+	query := url.Values{"merchant_reference": {merchantReference}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.baseURL+"/authorizations?"+query.Encode(), nil)
+	if err != nil {
+		return Response{}, err
+	}
+	return a.do(req)
+}
+
+func (a *HTTPAcquirer) post(ctx context.Context, path string, body interface{}) (Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return Response{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return Response{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return a.do(req)
+}
+
+func (a *HTTPAcquirer) do(req *http.Request) (Response, error) {
+	res, err := a.client.Do(req)
+	if err != nil {
+		return Response{}, fmt.Errorf("acquirer unreachable: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound && req.Method == http.MethodGet {
+		return Response{}, ErrOperationNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return Response{}, &StatusError{StatusCode: res.StatusCode}
+	}
+
+	var response Response
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return Response{}, fmt.Errorf("invalid acquirer response: %w", err)
+	}
+	return response, nil
+}
@@ -0,0 +1,121 @@
+package acquirer
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHTTPAcquirerAuthorize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/authorizations", r.URL.Path)
+
+		var request AuthorizationRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		assert.Equal(t, int64(1050), request.Amount)
+
+		_ = json.NewEncoder(w).Encode(Response{Approved: false, Reference: "auth_1", DeclineReason: "insufficient funds"})
+	}))
+	defer server.Close()
+
+	// Test case 1: a decline is returned as a response, not an error
+	response, err := NewHTTPAcquirer(server.URL, time.Second).Authorize(context.Background(), AuthorizationRequest{Amount: 1050, Currency: "USD"})
+	assert.NoError(t, err)
+	assert.Equal(t, false, response.Approved)
+	assert.Equal(t, "auth_1", response.Reference)
+	assert.Equal(t, "insufficient funds", response.DeclineReason)
+}
+
+func TestHTTPAcquirerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/authorizations/slow/capture" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := NewHTTPAcquirer(server.URL, 50*time.Millisecond)
+
+	// Test case 1: server errors are reported as errors
+	_, err := client.Void(context.Background(), "auth_1", VoidRequest{OperationReference: "void_1"})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "503")
+
+	// Test case 2: the bank taking longer than the timeout
+	_, err = client.Capture(context.Background(), "slow", AmountRequest{Amount: 100})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "acquirer unreachable")
+}
+
+func TestHTTPAcquirerTransfer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/transfers", r.URL.Path)
+
+		var request TransferRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		assert.Equal(t, "payout_1", request.Reference)
+		assert.Equal(t, int64(11282), request.Amount)
+
+		_ = json.NewEncoder(w).Encode(Response{Approved: true, Reference: "trf_1"})
+	}))
+	defer server.Close()
+
+	response, err := NewHTTPAcquirer(server.URL, time.Second).Transfer(context.Background(), TransferRequest{Reference: "payout_1", Beneficiary: "merchant_1", Amount: 11282, Currency: "EUR"})
+	assert.NoError(t, err)
+	assert.Equal(t, true, response.Approved)
+	assert.Equal(t, "trf_1", response.Reference)
+}
+
+func TestHTTPAcquirerLookup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		if r.URL.Path != "/operations/cap_1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Response{Approved: true, Reference: "capture_1"})
+	}))
+	defer server.Close()
+
+	client := NewHTTPAcquirer(server.URL, time.Second)
+
+	// Test case 1: the outcome of an operation the bank received
+	response, err := client.Lookup(context.Background(), "cap_1")
+	assert.NoError(t, err)
+	assert.Equal(t, true, response.Approved)
+	assert.Equal(t, "capture_1", response.Reference)
+
+	// Test case 2: an operation that never reached the bank
+	_, err = client.Lookup(context.Background(), "cap_2")
+	assert.Equal(t, ErrOperationNotFound, err)
+}
+
+func TestHTTPAcquirerLookupAuthorization(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodGet, r.Method)
+		assert.Equal(t, "/authorizations", r.URL.Path)
+		if r.URL.Query().Get("merchant_reference") != "pay_1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(Response{Approved: true, Reference: "auth_1"})
+	}))
+	defer server.Close()
+
+	client := NewHTTPAcquirer(server.URL, time.Second)
+
+	// Test case 1: the authorization the bank made for the payment
+	response, err := client.LookupAuthorization(context.Background(), "pay_1")
+	assert.NoError(t, err)
+	assert.Equal(t, true, response.Approved)
+	assert.Equal(t, "auth_1", response.Reference)
+
+	// Test case 2: a payment the bank never received
+	_, err = client.LookupAuthorization(context.Background(), "pay_2")
+	assert.Equal(t, ErrOperationNotFound, err)
+}
@@ -0,0 +1,101 @@
+package acquirer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SettlementTypeCapture is the type of the lines of settlement files, which only settle captures
+const SettlementTypeCapture = "capture"
+
+// settlementFileHeader is the first line of settlement files
+var settlementFileHeader = []string{"reference", "authorization_reference", "merchant_reference", "type", "amount", "currency", "settled_at"}
+
+// SettlementRecord is a line of the settlement file the acquirer sends every day, one per capture it settled.
+// AuthorizationReference is the reference the acquirer answered the authorization with, Amount is in minor units.
+type SettlementRecord struct {
+	Reference              string
+	AuthorizationReference string
+	MerchantReference      string
+	Type                   string
+	Amount                 int64
+	Currency               string
+	SettledAt              time.Time
+}
+
+// WriteSettlementFile writes the records as CSV with a header line
+func WriteSettlementFile(w io.Writer, records []SettlementRecord) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(settlementFileHeader); err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := writer.Write([]string{
+			record.Reference,
+			record.AuthorizationReference,
+			record.MerchantReference,
+			record.Type,
+			strconv.FormatInt(record.Amount, 10),
+			record.Currency,
+			record.SettledAt.UTC().Format(time.RFC3339),
+		}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ReadSettlementFile parses a settlement file, rejecting the whole file on the first invalid line
+func ReadSettlementFile(r io.Reader) ([]SettlementRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(settlementFileHeader)
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("invalid settlement file: %w", err)
+	}
+	if strings.Join(header, ",") != strings.Join(settlementFileHeader, ",") {
+		return nil, fmt.Errorf("invalid settlement file: header must be %s", strings.Join(settlementFileHeader, ","))
+	}
+
+	var records []SettlementRecord
+	for line := 2; ; line++ {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid settlement file: %w", err)
+		}
+
+		amount, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid settlement file: line %d: invalid amount %q", line, fields[4])
+		}
+		settledAt, err := time.Parse(time.RFC3339, fields[6])
+		if err != nil {
+			return nil, fmt.Errorf("invalid settlement file: line %d: invalid settled_at %q", line, fields[6])
+		}
+		if fields[1] == "" {
+			return nil, fmt.Errorf("invalid settlement file: line %d: missing authorization_reference", line)
+		}
+		if fields[3] != SettlementTypeCapture {
+			return nil, fmt.Errorf("invalid settlement file: line %d: unsupported type %q", line, fields[3])
+		}
+
+		records = append(records, SettlementRecord{
+			Reference:              fields[0],
+			AuthorizationReference: fields[1],
+			MerchantReference:      fields[2],
+			Type:                   fields[3],
+			Amount:                 amount,
+			Currency:               fields[5],
+			SettledAt:              settledAt,
+		})
+	}
+}
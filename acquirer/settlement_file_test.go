@@ -0,0 +1,38 @@
+package acquirer
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSettlementFile(t *testing.T) {
+	records := []SettlementRecord{
+		{Reference: "capture_1", AuthorizationReference: "auth_1", MerchantReference: "p1", Type: "capture", Amount: 1050, Currency: "EUR", SettledAt: time.Date(2026, 10, 17, 9, 12, 44, 0, time.UTC)},
+		{Reference: "capture_2", AuthorizationReference: "auth_2", MerchantReference: "p2", Type: "capture", Amount: 500, Currency: "JPY", SettledAt: time.Date(2026, 10, 17, 23, 59, 59, 0, time.UTC)},
+	}
+
+	// Test case 1: files are read back as written
+	var file bytes.Buffer
+	assert.NoError(t, WriteSettlementFile(&file, records))
+	assert.True(t, strings.HasPrefix(file.String(), "reference,authorization_reference,"))
+	read, err := ReadSettlementFile(&file)
+	assert.NoError(t, err)
+	assert.Equal(t, records, read)
+
+	// Test case 2: files of another format are refused
+	_, err = ReadSettlementFile(strings.NewReader("ref,amount\nauth_1,1050\n"))
+	assert.Error(t, err)
+
+	// Test case 3: invalid lines are reported with their line number
+	_, err = ReadSettlementFile(strings.NewReader("reference,authorization_reference,merchant_reference,type,amount,currency,settled_at\n" +
+		"capture_1,auth_1,p1,capture,10.50,EUR,2026-10-17T09:12:44Z\n"))
+	assert.EqualError(t, err, `invalid settlement file: line 2: invalid amount "10.50"`)
+
+	// Test case 4: only captures are settled
+	_, err = ReadSettlementFile(strings.NewReader("reference,authorization_reference,merchant_reference,type,amount,currency,settled_at\n" +
+		"refund_1,auth_1,p1,refund,1050,EUR,2026-10-17T09:12:44Z\n"))
+	assert.EqualError(t, err, `invalid settlement file: line 2: unsupported type "refund"`)
+}
@@ -0,0 +1,31 @@
+package bank
+
+import (
+	"checkout-task/models"
+	"github.com/spf13/viper"
+)
+
+var Config *models.BankSimulatorConfig
+
+func LoadConfig() {
+	v := viper.New()
+	v.AutomaticEnv()
+	v.SetDefault("BANK_SIMULATOR_PORT", "8081")
+	v.SetDefault("BANK_SCENARIOS_FILE", "bank/scenarios.yaml")
+	v.SetDefault("MODE", "debug")
+	v.SetConfigType("dotenv")
+	v.SetConfigName(".env.local")
+	v.AddConfigPath("./")
+
+	if err := v.ReadInConfig(); err != nil {
+		panic(err)
+	}
+
+	if err := v.Unmarshal(&Config); err != nil {
+		panic(err)
+	}
+
+	if err := Config.Validate(); err != nil {
+		panic(err)
+	}
+}
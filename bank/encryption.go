@@ -0,0 +1,113 @@
+package bank
+
+import (
+	"checkout-task/logger"
+	"checkout-task/vault"
+	"fmt"
+	"go.uber.org/zap"
+	"time"
+)
+
+// encryptionBatchSize is how many rows are encrypted per transaction
+const encryptionBatchSize = 500
+
+// cardNumberTables hold a card_number and the card_fingerprint it is looked up by
+var cardNumberTables = []string{"cards", "frauds", "bank_transactions"}
+
+// EncryptedColumns are the columns of the bank simulator sealed by the vault
+var EncryptedColumns = []vault.Column{
+	{Table: "cards", Name: "card_number"},
+	{Table: "frauds", Name: "card_number"},
+	{Table: "bank_transactions", Name: "card_number"},
+}
+
+// RotateVaultKeys moves card data sealed under older keys to the active key, looking for
+// such rows again every interval
+func RotateVaultKeys(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for {
+		if err := Vault.RotateColumns(DbConnection, EncryptedColumns, encryptionBatchSize); err != nil {
+			logger.Error("failed to re-encrypt card data", zap.Error(err))
+		}
+		<-ticker.C
+	}
+}
+
+// KeyUsage counts by column the rows of the bank simulator that depend on the key
+func KeyUsage(keyID string) (map[string]int, error) {
+	usage := map[string]int{}
+	for _, column := range EncryptedColumns {
+		count, err := vault.CountByKey(DbConnection, column, keyID)
+		if err != nil {
+			return nil, err
+		}
+		usage[column.String()] = count
+	}
+	return usage, nil
+}
+
+// RefreshFingerprints recomputes the card fingerprints of the bank simulator with the configured fingerprint key
+func RefreshFingerprints() error {
+	for _, table := range cardNumberTables {
+		column := vault.FingerprintColumn{Table: table, CardNumber: "card_number", Fingerprint: "card_fingerprint"}
+		changed, err := Vault.Refingerprint(DbConnection, column, encryptionBatchSize)
+		if err != nil {
+			return err
+		}
+		logger.Info("Recomputed card fingerprints", zap.String("table", table), zap.Int("rows", changed))
+	}
+	return nil
+}
+
+type cardNumberRow struct {
+	ID         int64
+	CardNumber string
+}
+
+// EncryptCardData encrypts the card numbers stored in plaintext before encryption at rest and
+// fills in their fingerprints. It can be run again safely.
+func EncryptCardData() error {
+	for _, table := range cardNumberTables {
+		rows, err := encryptTable(table)
+		if err != nil {
+			return err
+		}
+		logger.Info("Encrypted card numbers", zap.String("table", table), zap.Int("rows", rows))
+	}
+	return nil
+}
+
+func encryptTable(table string) (int, error) {
+	total := 0
+	for {
+		var rows []cardNumberRow
+		if err := DbConnection.Table(table).Select("id, card_number").
+			Where("card_number NOT LIKE 'env:%'").
+			Order("id asc").Limit(encryptionBatchSize).Scan(&rows).Error; err != nil {
+			return total, fmt.Errorf("cannot read %s: %w", table, err)
+		}
+		if len(rows) == 0 {
+			return total, nil
+		}
+
+		tx := DbConnection.Begin()
+		for _, row := range rows {
+			encrypted, err := Vault.Encrypt(row.CardNumber)
+			if err != nil {
+				tx.Rollback()
+				return total, fmt.Errorf("cannot encrypt %s %d: %w", table, row.ID, err)
+			}
+			if err := tx.Table(table).Where("id = ?", row.ID).UpdateColumns(map[string]interface{}{
+				"card_number":      encrypted,
+				"card_fingerprint": Vault.Fingerprint(row.CardNumber),
+			}).Error; err != nil {
+				tx.Rollback()
+				return total, fmt.Errorf("cannot update %s %d: %w", table, row.ID, err)
+			}
+		}
+		if err := tx.Commit().Error; err != nil {
+			return total, err
+		}
+		total += len(rows)
+	}
+}
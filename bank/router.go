@@ -0,0 +1,149 @@
+package bank
+
+import (
+	"checkout-task/acquirer"
+	"checkout-task/logger"
+	"checkout-task/models"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"time"
+)
+
+// New creates the HTTP API of the bank simulator, the counterpart of acquirer.HTTPAcquirer
+func New() *gin.Engine {
+	gin.SetMode(Config.Mode)
+	r := gin.New()
+	r.Use(gin.Logger())
+	r.Use(gin.Recovery())
+
+	r.NoRoute(func(c *gin.Context) {
+		models.SendErrorResponse(c, http.StatusNotFound, c.Request.RequestURI+" not found")
+	})
+
+	authorizations := r.Group("/authorizations")
+	{
+		authorizations.POST("", authorize)
+		authorizations.GET("", lookupAuthorization)
+		authorizations.POST("/:reference/capture", capture)
+		authorizations.POST("/:reference/void", void)
+		authorizations.POST("/:reference/refunds", refund)
+	}
+
+	r.GET("/operations/:reference", lookup)
+	r.POST("/transfers", transfer)
+	r.GET("/settlement-files/:date", settlementFile)
+
+	return r
+}
+
+func authorize(c *gin.Context) {
+	var request acquirer.AuthorizationRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respond(c)(Authorize(request))
+}
+
+func capture(c *gin.Context) {
+	var request acquirer.AmountRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respond(c)(Capture(c.Param("reference"), request.OperationReference, request.Amount))
+}
+
+func void(c *gin.Context) {
+	// The body is optional, voids sent without an operation reference cannot be looked up
+	var request acquirer.VoidRequest
+	if err := c.ShouldBindJSON(&request); err != nil && err != io.EOF {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respond(c)(Void(c.Param("reference"), request.OperationReference))
+}
+
+func refund(c *gin.Context) {
+	var request acquirer.AmountRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respond(c)(Refund(c.Param("reference"), request.OperationReference, request.Amount))
+}
+
+// lookupAuthorization answers the outcome of the gateway's authorization of a payment, found by the
+// merchant_reference query parameter, or 404 when the bank never received it
+func lookupAuthorization(c *gin.Context) {
+	response, err := LookupAuthorization(c.Query("merchant_reference"))
+	if err == acquirer.ErrOperationNotFound {
+		models.SendErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	respond(c)(response, err)
+}
+
+// lookup answers the outcome of an operation of the gateway, or 404 when the bank never received it
+func lookup(c *gin.Context) {
+	response, err := Lookup(c.Param("reference"))
+	if err == acquirer.ErrOperationNotFound {
+		models.SendErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	respond(c)(response, err)
+}
+
+func transfer(c *gin.Context) {
+	var request acquirer.TransferRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respond(c)(Transfer(request))
+}
+
+// settlementFile answers the settlement file of a day (YYYY-MM-DD) as CSV, optionally with discrepancies
+func settlementFile(c *gin.Context) {
+	date, err := time.Parse("2006-01-02", c.Param("date"))
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, "date must be YYYY-MM-DD")
+		return
+	}
+	var discrepancies Discrepancies
+	if err := c.ShouldBindQuery(&discrepancies); err != nil || discrepancies.Drop < 0 || discrepancies.Extra < 0 || discrepancies.Amount < 0 {
+		models.SendErrorResponse(c, http.StatusBadRequest, "drop, extra and amount_mismatch must be positive whole numbers")
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", "attachment; filename=settlement-"+c.Param("date")+".csv")
+	if err := WriteSettlementFileFor(c.Writer, date, discrepancies); err != nil {
+		logger.Error("failed to write settlement file", zap.Error(err), zap.String("date", c.Param("date")))
+		models.SendErrorResponse(c, http.StatusInternalServerError, "failed to write settlement file")
+	}
+}
+
+// respond writes the outcome of a bank operation. Declines are regular responses,
+// only failures to process the operation are reported as server errors.
+func respond(c *gin.Context) func(acquirer.Response, error) {
+	return func(response acquirer.Response, err error) {
+		if err == ErrIssuerTimeout {
+			models.SendErrorResponse(c, http.StatusGatewayTimeout, err.Error())
+			return
+		}
+		if err != nil {
+			logger.Error("bank operation failed", zap.Error(err), zap.String("path", c.Request.URL.Path))
+			models.SendErrorResponse(c, http.StatusInternalServerError, "bank operation failed")
+			return
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
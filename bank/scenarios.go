@@ -0,0 +1,140 @@
+package bank
+
+import (
+	"checkout-task/acquirer"
+	"checkout-task/logger"
+	"errors"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+)
+
+// Outcomes a scenario can force on the operations it matches. The declining ones are also
+// the decline codes reported to the gateway.
+const (
+	OutcomeApprove           = "approve"
+	OutcomeDoNotHonour       = acquirer.DeclineDoNotHonour
+	OutcomeStolenCard        = acquirer.DeclineStolenCard
+	OutcomeExpiredCard       = acquirer.DeclineExpiredCard
+	OutcomeIssuerUnavailable = acquirer.DeclineIssuerUnavailable
+	OutcomeTimeout           = "timeout"
+)
+
+// defaultTimeoutDelay is how long a timeout keeps the caller waiting when the scenario sets no delay
+const defaultTimeoutDelay = 10 * time.Second
+
+var ErrIssuerTimeout = errors.New("issuer did not respond in time")
+
+// Scenario forces the outcome of the operations it matches. Empty criteria match any value.
+type Scenario struct {
+	Name        string   `mapstructure:"name"`
+	CardNumber  string   `mapstructure:"card_number"`
+	Amount      int64    `mapstructure:"amount"`
+	Operations  []string `mapstructure:"operations"`
+	Outcome     string   `mapstructure:"outcome"`
+	DelayMillis int      `mapstructure:"delay_ms"`
+	FailureRate float64  `mapstructure:"failure_rate"`
+}
+
+// ScenarioConfig is the content of the scenarios file. Seed makes failure rates reproducible.
+type ScenarioConfig struct {
+	Seed      int64      `mapstructure:"seed"`
+	Scenarios []Scenario `mapstructure:"scenarios"`
+}
+
+var scenarios ScenarioConfig
+var random = rand.New(rand.NewSource(1))
+var randomMu sync.Mutex
+
+// LoadScenarios reads the scenarios from a YAML file. A missing file leaves every operation to the regular checks.
+func LoadScenarios(path string) {
+	if _, err := os.Stat(path); err != nil {
+		logger.Info("No bank scenarios loaded", zap.String("file", path))
+		return
+	}
+
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		panic(err)
+	}
+
+	var config ScenarioConfig
+	if err := v.Unmarshal(&config); err != nil {
+		panic(err)
+	}
+	SetScenarios(config)
+
+	logger.Info("Bank scenarios loaded", zap.String("file", path), zap.Int("count", len(config.Scenarios)))
+}
+
+// SetScenarios replaces the active scenarios and reseeds the failure rates
+func SetScenarios(config ScenarioConfig) {
+	randomMu.Lock()
+	defer randomMu.Unlock()
+
+	scenarios = config
+	random = rand.New(rand.NewSource(config.Seed))
+}
+
+// MatchScenario returns the first scenario matching the operation, or nil
+func MatchScenario(operation string, cardNumber string, amount int64) *Scenario {
+	for i := range scenarios.Scenarios {
+		if scenarios.Scenarios[i].matches(operation, cardNumber, amount) {
+			return &scenarios.Scenarios[i]
+		}
+	}
+	return nil
+}
+
+func (s *Scenario) matches(operation string, cardNumber string, amount int64) bool {
+	if s.CardNumber != "" && s.CardNumber != cardNumber {
+		return false
+	}
+	if s.Amount != 0 && s.Amount != amount {
+		return false
+	}
+	if len(s.Operations) == 0 {
+		return operation == TypeAuthorization
+	}
+	for _, o := range s.Operations {
+		if o == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply waits for the scenario's delay and returns the decline code it forces,
+// or an empty string when the operation should go through the regular checks.
+func (s *Scenario) Apply() (string, error) {
+	delay := time.Duration(s.DelayMillis) * time.Millisecond
+	if s.Outcome == OutcomeTimeout && delay == 0 {
+		delay = defaultTimeoutDelay
+	}
+	time.Sleep(delay)
+
+	if s.Outcome == "" || s.Outcome == OutcomeApprove || !s.triggers() {
+		return "", nil
+	}
+
+	logger.Info("Bank scenario triggered", zap.String("scenario", s.Name), zap.String("outcome", s.Outcome))
+	if s.Outcome == OutcomeTimeout {
+		return "", ErrIssuerTimeout
+	}
+	return s.Outcome, nil
+}
+
+// triggers decides whether the outcome applies this time, following the failure rate
+func (s *Scenario) triggers() bool {
+	if s.FailureRate <= 0 {
+		return true
+	}
+
+	randomMu.Lock()
+	defer randomMu.Unlock()
+	return random.Float64() < s.FailureRate
+}
@@ -0,0 +1,60 @@
+package bank
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMatchScenario(t *testing.T) {
+	SetScenarios(ScenarioConfig{Scenarios: []Scenario{
+		{Name: "stolen", CardNumber: "4000000000009979", Outcome: OutcomeStolenCard},
+		{Name: "refunds down", Operations: []string{TypeRefund}, Outcome: OutcomeIssuerUnavailable},
+		{Name: "magic amount", Amount: 40800, Outcome: OutcomeTimeout},
+	}})
+
+	// Test case 1: match by card number, authorizations only by default
+	assert.Equal(t, "stolen", MatchScenario(TypeAuthorization, "4000000000009979", 100).Name)
+	assert.Nil(t, MatchScenario(TypeCapture, "4000000000009979", 100))
+
+	// Test case 2: match by operation for any card
+	assert.Equal(t, "refunds down", MatchScenario(TypeRefund, "4242424242424242", 100).Name)
+
+	// Test case 3: match by amount
+	assert.Equal(t, "magic amount", MatchScenario(TypeAuthorization, "4242424242424242", 40800).Name)
+	assert.Nil(t, MatchScenario(TypeAuthorization, "4242424242424242", 40801))
+}
+
+func TestScenarioApply(t *testing.T) {
+	// Test case 1: declines report the outcome as decline code
+	code, err := (&Scenario{Outcome: OutcomeDoNotHonour}).Apply()
+	assert.NoError(t, err)
+	assert.Equal(t, "do_not_honour", code)
+
+	// Test case 2: timeouts are errors
+	_, err = (&Scenario{Outcome: OutcomeTimeout, DelayMillis: 1}).Apply()
+	assert.Equal(t, ErrIssuerTimeout, err)
+
+	// Test case 3: a slow response without an outcome goes through the regular checks
+	code, err = (&Scenario{DelayMillis: 1}).Apply()
+	assert.NoError(t, err)
+	assert.Equal(t, "", code)
+}
+
+func TestScenarioFailureRateIsReproducible(t *testing.T) {
+	flaky := Scenario{Outcome: OutcomeIssuerUnavailable, FailureRate: 0.5}
+
+	run := func() []string {
+		SetScenarios(ScenarioConfig{Seed: 42})
+		var codes []string
+		for i := 0; i < 20; i++ {
+			code, _ := flaky.Apply()
+			codes = append(codes, code)
+		}
+		return codes
+	}
+
+	first := run()
+	assert.Equal(t, first, run())
+	assert.Contains(t, first, "issuer_unavailable")
+	assert.Contains(t, first, "")
+}
@@ -0,0 +1,77 @@
+package bank
+
+import (
+	"checkout-task/acquirer"
+	db "checkout-task/models/db"
+	"io"
+	"time"
+)
+
+// Discrepancies are injected into settlement files to test reconciliation. Drop leaves captures out of the
+// file, Extra adds captures the gateway never made and Amount changes the amount of captures.
+type Discrepancies struct {
+	Drop   int `form:"drop"`
+	Extra  int `form:"extra"`
+	Amount int `form:"amount_mismatch"`
+}
+
+// SettlementRecords lists the captures approved on the day, in UTC, as the acquirer settles them
+func SettlementRecords(date time.Time) ([]acquirer.SettlementRecord, error) {
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+
+	var captures []db.BankTransaction
+	if err := DbConnection.Where("type = ? AND status = ? AND created_at >= ? AND created_at < ?", TypeCapture, StatusApproved, from, from.AddDate(0, 0, 1)).
+		Order("id asc").Find(&captures).Error; err != nil {
+		return nil, err
+	}
+
+	records := make([]acquirer.SettlementRecord, 0, len(captures))
+	for _, capture := range captures {
+		records = append(records, acquirer.SettlementRecord{
+			Reference:              capture.Reference,
+			AuthorizationReference: capture.AuthorizationReference,
+			MerchantReference:      capture.MerchantReference,
+			Type:                   acquirer.SettlementTypeCapture,
+			Amount:                 capture.Amount,
+			Currency:               capture.Currency,
+			SettledAt:              capture.CreatedAt,
+		})
+	}
+	return records, nil
+}
+
+// InjectDiscrepancies drops the first records, changes the amount of the next ones and adds captures of
+// authorizations that do not exist, as many as asked and as the records allow
+func InjectDiscrepancies(records []acquirer.SettlementRecord, discrepancies Discrepancies, date time.Time) []acquirer.SettlementRecord {
+	drop := discrepancies.Drop
+	if drop > len(records) {
+		drop = len(records)
+	}
+	records = append([]acquirer.SettlementRecord{}, records[drop:]...)
+
+	for i := 0; i < discrepancies.Amount && i < len(records); i++ {
+		records[i].Amount += 100
+	}
+
+	settledAt := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, time.UTC)
+	for i := 0; i < discrepancies.Extra; i++ {
+		records = append(records, acquirer.SettlementRecord{
+			Reference:              newReference(TypeCapture),
+			AuthorizationReference: newReference("auth"),
+			Type:                   TypeCapture,
+			Amount:                 1000,
+			Currency:               "EUR",
+			SettledAt:              settledAt,
+		})
+	}
+	return records
+}
+
+// WriteSettlementFileFor writes the settlement file of the day with the discrepancies asked for
+func WriteSettlementFileFor(w io.Writer, date time.Time, discrepancies Discrepancies) error {
+	records, err := SettlementRecords(date)
+	if err != nil {
+		return err
+	}
+	return acquirer.WriteSettlementFile(w, InjectDiscrepancies(records, discrepancies, date))
+}
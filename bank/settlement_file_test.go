@@ -0,0 +1,32 @@
+package bank
+
+import (
+	"checkout-task/acquirer"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestInjectDiscrepancies(t *testing.T) {
+	date := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	records := []acquirer.SettlementRecord{
+		{AuthorizationReference: "auth_1", Amount: 1050},
+		{AuthorizationReference: "auth_2", Amount: 2000},
+		{AuthorizationReference: "auth_3", Amount: 3000},
+	}
+
+	// Test case 1: no discrepancies leave the file as it is
+	assert.Equal(t, records, InjectDiscrepancies(records, Discrepancies{}, date))
+
+	// Test case 2: dropped, changed and extra captures
+	injected := InjectDiscrepancies(records, Discrepancies{Drop: 1, Amount: 1, Extra: 2}, date)
+	assert.Len(t, injected, 4)
+	assert.Equal(t, "auth_2", injected[0].AuthorizationReference)
+	assert.Equal(t, int64(2100), injected[0].Amount)
+	assert.Equal(t, int64(3000), injected[1].Amount)
+	assert.NotEqual(t, injected[2].AuthorizationReference, injected[3].AuthorizationReference)
+
+	// Test case 3: the records passed in are left alone and counts larger than the file are capped
+	assert.Equal(t, int64(2000), records[1].Amount)
+	assert.Empty(t, InjectDiscrepancies(records, Discrepancies{Drop: 5, Amount: 5}, date))
+}
@@ -0,0 +1,466 @@
+package bank
+
+import (
+	"checkout-task/acquirer"
+	"checkout-task/logger"
+	db "checkout-task/models/db"
+	"checkout-task/utils"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+)
+
+// Kinds of bank transactions
+const (
+	TypeAuthorization = "authorization"
+	TypeCapture       = "capture"
+	TypeVoid          = "void"
+	TypeRefund        = "refund"
+	TypeTransfer      = "transfer"
+)
+
+// Statuses of bank transactions. Authorizations move from Authorized to Captured or Voided,
+// the other kinds are either Approved or Declined.
+const (
+	StatusApproved   = "approved"
+	StatusDeclined   = "declined"
+	StatusAuthorized = "authorized"
+	StatusCaptured   = "captured"
+	StatusVoided     = "voided"
+)
+
+// decline tells why the bank turned an operation down
+type decline struct {
+	Code   string
+	Reason string
+}
+
+// declineReasons describes the decline codes the simulator reports
+var declineReasons = map[string]string{
+	acquirer.DeclineInvalidCard:       "invalid card details",
+	acquirer.DeclineInsufficientFunds: "insufficient funds",
+	acquirer.DeclineSuspectedFraud:    "potential fraud",
+	acquirer.DeclineDoNotHonour:       "do not honour",
+	acquirer.DeclineStolenCard:        "stolen card",
+	acquirer.DeclineExpiredCard:       "expired card",
+	acquirer.DeclineIssuerUnavailable: "issuer unavailable",
+}
+
+func declined(code string) *decline {
+	return &decline{Code: code, Reason: declineReasons[code]}
+}
+
+func invalidRequest(reason string) *decline {
+	return &decline{Code: acquirer.DeclineInvalidRequest, Reason: reason}
+}
+
+func newReference(prefix string) string {
+	return prefix + "_" + uuid.New().String()
+}
+
+// Authorize validates the card and holds the amount on it
+func Authorize(request acquirer.AuthorizationRequest) (acquirer.Response, error) {
+	encryptedCardNumber, err := Vault.Encrypt(request.CardNumber)
+	if err != nil {
+		return acquirer.Response{}, err
+	}
+
+	authorization := db.BankTransaction{
+		Reference:           newReference("auth"),
+		Type:                TypeAuthorization,
+		MerchantReference:   request.MerchantReference,
+		StatementDescriptor: request.StatementDescriptor,
+		CardNumber:          encryptedCardNumber,
+		CardFingerprint:     Vault.Fingerprint(request.CardNumber),
+		Amount:              request.Amount,
+		Currency:            request.Currency,
+		Status:              StatusAuthorized,
+	}
+
+	// Scenarios decide the outcome before the regular checks run
+	var outcome *decline
+	if scenario := MatchScenario(TypeAuthorization, request.CardNumber, request.Amount); scenario != nil {
+		code, err := scenario.Apply()
+		if err != nil {
+			return acquirer.Response{}, err
+		}
+		if code != "" {
+			outcome = declined(code)
+		}
+	}
+
+	tx := DbConnection.Begin()
+	if outcome == nil {
+		outcome = checkAuthorization(tx, request)
+	}
+	if outcome != nil {
+		authorization.Status = StatusDeclined
+		authorization.DeclineCode = outcome.Code
+		authorization.DeclineReason = outcome.Reason
+	}
+
+	if err := tx.Create(&authorization).Error; err != nil {
+		tx.Rollback()
+		return acquirer.Response{}, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return acquirer.Response{}, err
+	}
+
+	logger.Info("Authorization processed", zap.String("reference", authorization.Reference), zap.String("status", authorization.Status))
+	return response(authorization), nil
+}
+
+// checkAuthorization runs the checks of the issuer and holds the funds. It returns why the
+// authorization is declined, or nil when it is approved.
+func checkAuthorization(tx *gorm.DB, request acquirer.AuthorizationRequest) *decline {
+	// Validate card details
+	validCardNumber := utils.ValidateCardNumber(request.CardNumber)
+	validExpiryDate := utils.ValidateExpiryDate(request.ExpMonth, request.ExpYear)
+	validAmount := utils.ValidateAmount(request.Amount)
+	validCurrency := utils.ValidateCurrency(request.Currency)
+	validCVV := utils.ValidateCVV(request.CardNumber, request.CVV) || (request.StoredCredential && request.CVV == 0)
+
+	if !validCardNumber || !validExpiryDate || !validAmount || !validCurrency || !validCVV {
+		return declined(acquirer.DeclineInvalidCard)
+	}
+
+	// Cards and frauds are found by the fingerprint of the card number
+	cardFingerprint := Vault.Fingerprint(request.CardNumber)
+
+	// Check if card has sufficient funds
+	if !CheckFunds(tx, cardFingerprint, request.Amount) {
+		return declined(acquirer.DeclineInsufficientFunds)
+	}
+
+	// Check for fraud
+	if CheckForFraud(tx, cardFingerprint, request.Amount, request.Currency) {
+		return declined(acquirer.DeclineSuspectedFraud)
+	}
+
+	// Hold the funds until the payment is captured
+	if err := HoldFunds(tx, cardFingerprint, request.Amount); err != nil {
+		logger.Error("Authorization declined while holding funds", zap.Error(err))
+		return declined(acquirer.DeclineInsufficientFunds)
+	}
+
+	return nil
+}
+
+// Capture debits the card for up to the authorized amount and releases the rest of the hold
+func Capture(authorizationReference string, operationReference string, amount int64) (acquirer.Response, error) {
+	return updateAuthorization(authorizationReference, operationReference, TypeCapture, amount, func(tx *gorm.DB, authorization *db.BankTransaction) *decline {
+		if authorization.Status != StatusAuthorized {
+			return invalidRequest("authorization is " + authorization.Status)
+		}
+		if amount <= 0 || amount > authorization.Amount {
+			return invalidRequest("capture amount exceeds the authorized amount")
+		}
+		if err := CaptureFunds(tx, authorization.CardFingerprint, authorization.Amount, amount); err != nil {
+			logger.Error("Capture declined while settling funds", zap.Error(err))
+			return invalidRequest("no funds held on card")
+		}
+
+		authorization.Status = StatusCaptured
+		authorization.CapturedAmount = amount
+		return nil
+	})
+}
+
+// Void releases the hold of an authorization that was not captured
+func Void(authorizationReference string, operationReference string) (acquirer.Response, error) {
+	return updateAuthorization(authorizationReference, operationReference, TypeVoid, 0, func(tx *gorm.DB, authorization *db.BankTransaction) *decline {
+		if authorization.Status != StatusAuthorized {
+			return invalidRequest("authorization is " + authorization.Status)
+		}
+		if err := ReleaseFunds(tx, authorization.CardFingerprint, authorization.Amount); err != nil {
+			logger.Error("Void declined while releasing funds", zap.Error(err))
+			return invalidRequest("no funds held on card")
+		}
+
+		authorization.Status = StatusVoided
+		return nil
+	})
+}
+
+// Refund credits part of a captured amount back to the card
+func Refund(authorizationReference string, operationReference string, amount int64) (acquirer.Response, error) {
+	return updateAuthorization(authorizationReference, operationReference, TypeRefund, amount, func(tx *gorm.DB, authorization *db.BankTransaction) *decline {
+		if authorization.Status != StatusCaptured {
+			return invalidRequest("authorization is " + authorization.Status)
+		}
+		if amount <= 0 || authorization.RefundedAmount+amount > authorization.CapturedAmount {
+			return invalidRequest("refund amount exceeds the captured amount")
+		}
+		if err := RefundFunds(tx, authorization.CardFingerprint, amount); err != nil {
+			logger.Error("Refund declined while crediting the card", zap.Error(err))
+			return declined(acquirer.DeclineInvalidCard)
+		}
+
+		authorization.RefundedAmount += amount
+		return nil
+	})
+}
+
+// Transfer pays a payout of the gateway out to the merchant. Payouts sent again get the approved transfer made
+// before, so the gateway can retry after a timeout without paying twice.
+func Transfer(request acquirer.TransferRequest) (acquirer.Response, error) {
+	var existing db.BankTransfer
+	err := DbConnection.Where("payout_reference = ? AND status = ?", request.Reference, StatusApproved).First(&existing).Error
+	if err == nil {
+		return transferResponse(existing), nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return acquirer.Response{}, err
+	}
+
+	transfer := db.BankTransfer{
+		Reference:       newReference("trf"),
+		PayoutReference: request.Reference,
+		Beneficiary:     request.Beneficiary,
+		Amount:          request.Amount,
+		Currency:        request.Currency,
+		Status:          StatusApproved,
+	}
+
+	var outcome *decline
+	if request.Reference == "" || request.Beneficiary == "" {
+		outcome = invalidRequest("transfers need a reference and a beneficiary")
+	} else if request.Amount <= 0 || !utils.ValidateCurrency(request.Currency) {
+		outcome = invalidRequest("invalid transfer amount")
+	} else if scenario := MatchScenario(TypeTransfer, "", request.Amount); scenario != nil {
+		code, err := scenario.Apply()
+		if err != nil {
+			return acquirer.Response{}, err
+		}
+		if code != "" {
+			outcome = declined(code)
+		}
+	}
+	if outcome != nil {
+		transfer.Status = StatusDeclined
+		transfer.DeclineCode = outcome.Code
+		transfer.DeclineReason = outcome.Reason
+	}
+
+	if err := DbConnection.Create(&transfer).Error; err != nil {
+		// The same payout was approved meanwhile, answer with that transfer
+		if uniqueViolation(err) && DbConnection.Where("payout_reference = ? AND status = ?", request.Reference, StatusApproved).First(&existing).Error == nil {
+			return transferResponse(existing), nil
+		}
+		return acquirer.Response{}, err
+	}
+
+	logger.Info("Bank transfer processed", zap.String("reference", transfer.Reference), zap.String("payout_reference", transfer.PayoutReference), zap.String("status", transfer.Status))
+	return transferResponse(transfer), nil
+}
+
+func transferResponse(transfer db.BankTransfer) acquirer.Response {
+	return acquirer.Response{
+		Approved:      transfer.Status != StatusDeclined,
+		Reference:     transfer.Reference,
+		DeclineCode:   transfer.DeclineCode,
+		DeclineReason: transfer.DeclineReason,
+	}
+}
+
+// Lookup returns the outcome of the latest operation the gateway sent with the operation reference
+func Lookup(operationReference string) (acquirer.Response, error) {
+	var transaction db.BankTransaction
+	err := DbConnection.Where("operation_reference = ?", operationReference).Order("id desc").First(&transaction).Error
+	if err == gorm.ErrRecordNotFound {
+		return acquirer.Response{}, acquirer.ErrOperationNotFound
+	}
+	if err != nil {
+		return acquirer.Response{}, err
+	}
+	return response(transaction), nil
+}
+
+// LookupAuthorization returns the outcome of the latest authorization the gateway sent with the merchant reference
+func LookupAuthorization(merchantReference string) (acquirer.Response, error) {
+	if merchantReference == "" {
+		return acquirer.Response{}, acquirer.ErrOperationNotFound
+	}
+	var authorization db.BankTransaction
+	err := DbConnection.Where("merchant_reference = ? AND type = ?", merchantReference, TypeAuthorization).Order("id desc").First(&authorization).Error
+	if err == gorm.ErrRecordNotFound {
+		return acquirer.Response{}, acquirer.ErrOperationNotFound
+	}
+	if err != nil {
+		return acquirer.Response{}, err
+	}
+	return response(authorization), nil
+}
+
+// updateAuthorization locks the authorization, lets apply change it and records the operation
+// as a transaction of its own. apply returns why the operation is declined, if it is.
+func updateAuthorization(authorizationReference string, operationReference string, transactionType string, amount int64, apply func(tx *gorm.DB, authorization *db.BankTransaction) *decline) (acquirer.Response, error) {
+	tx := DbConnection.Begin()
+
+	transaction := db.BankTransaction{
+		Reference:              newReference(transactionType),
+		Type:                   transactionType,
+		AuthorizationReference: authorizationReference,
+		OperationReference:     operationReference,
+		Amount:                 amount,
+		Status:                 StatusApproved,
+	}
+
+	var authorization db.BankTransaction
+	err := tx.Set("gorm:query_option", "FOR UPDATE").
+		Where("reference = ? AND type = ?", authorizationReference, TypeAuthorization).
+		First(&authorization).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		tx.Rollback()
+		return acquirer.Response{}, err
+	}
+
+	var outcome *decline
+	if err == gorm.ErrRecordNotFound {
+		outcome = invalidRequest("authorization not found")
+	} else {
+		transaction.MerchantReference = authorization.MerchantReference
+		transaction.CardNumber = authorization.CardNumber
+		transaction.CardFingerprint = authorization.CardFingerprint
+		transaction.Currency = authorization.Currency
+
+		cardNumber, err := Vault.Decrypt(authorization.CardNumber)
+		if err != nil {
+			tx.Rollback()
+			return acquirer.Response{}, err
+		}
+
+		// Scenarios decide the outcome before the regular checks run
+		if scenario := MatchScenario(transactionType, cardNumber, amount); scenario != nil {
+			code, err := scenario.Apply()
+			if err != nil {
+				tx.Rollback()
+				return acquirer.Response{}, err
+			}
+			if code != "" {
+				outcome = declined(code)
+			}
+		}
+		if outcome == nil {
+			outcome = apply(tx, &authorization)
+		}
+	}
+
+	if outcome != nil {
+		transaction.Status = StatusDeclined
+		transaction.DeclineCode = outcome.Code
+		transaction.DeclineReason = outcome.Reason
+	} else if err := tx.Save(&authorization).Error; err != nil {
+		tx.Rollback()
+		return acquirer.Response{}, err
+	}
+
+	if err := tx.Create(&transaction).Error; err != nil {
+		tx.Rollback()
+		return acquirer.Response{}, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return acquirer.Response{}, err
+	}
+
+	logger.Info("Bank transaction processed", zap.String("type", transactionType), zap.String("reference", transaction.Reference), zap.String("status", transaction.Status))
+	return response(transaction), nil
+}
+
+func response(transaction db.BankTransaction) acquirer.Response {
+	return acquirer.Response{
+		Approved:      transaction.Status != StatusDeclined,
+		Reference:     transaction.Reference,
+		DeclineCode:   transaction.DeclineCode,
+		DeclineReason: transaction.DeclineReason,
+	}
+}
+
+func CheckFunds(tx *gorm.DB, cardFingerprint string, amount int64) bool {
+	var card db.Card
+	if err := tx.Where("card_fingerprint = ?", cardFingerprint).First(&card).Error; err != nil {
+		logger.Error("Error finding card", zap.Error(err))
+		return false
+	}
+
+	return card.Balance-card.HeldBalance >= amount
+}
+
+func CheckForFraud(tx *gorm.DB, cardFingerprint string, amount int64, currency string) bool {
+	var fraud db.Fraud
+	if err := tx.Where("card_fingerprint = ? AND amount >= ? AND currency = ?", cardFingerprint, amount, currency).First(&fraud).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return false
+		}
+		logger.Error("Error finding fraud", zap.Error(err))
+		return true
+	}
+
+	return true
+}
+
+// HoldFunds reserves the amount on the card so that it can be captured later
+func HoldFunds(tx *gorm.DB, cardFingerprint string, amount int64) error {
+	// The balance check is part of the update so concurrent holds cannot overdraw the card
+	result := tx.Model(&db.Card{}).
+		Where("card_fingerprint = ? AND balance - held_balance >= ?", cardFingerprint, amount).
+		UpdateColumn("held_balance", gorm.Expr("held_balance + ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("insufficient funds")
+	}
+
+	return nil
+}
+
+// CaptureFunds debits amount from the card balance and releases the held amount
+func CaptureFunds(tx *gorm.DB, cardFingerprint string, held int64, amount int64) error {
+	result := tx.Model(&db.Card{}).
+		Where("card_fingerprint = ? AND held_balance >= ?", cardFingerprint, held).
+		UpdateColumns(map[string]interface{}{
+			"balance":      gorm.Expr("balance - ?", amount),
+			"held_balance": gorm.Expr("held_balance - ?", held),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no funds held on card")
+	}
+
+	return nil
+}
+
+// ReleaseFunds gives a held amount back to the available card balance without debiting it
+func ReleaseFunds(tx *gorm.DB, cardFingerprint string, amount int64) error {
+	result := tx.Model(&db.Card{}).
+		Where("card_fingerprint = ? AND held_balance >= ?", cardFingerprint, amount).
+		UpdateColumn("held_balance", gorm.Expr("held_balance - ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("no funds held on card")
+	}
+
+	return nil
+}
+
+// RefundFunds credits the amount back to the card balance
+func RefundFunds(tx *gorm.DB, cardFingerprint string, amount int64) error {
+	result := tx.Model(&db.Card{}).
+		Where("card_fingerprint = ?", cardFingerprint).
+		UpdateColumn("balance", gorm.Expr("balance + ?", amount))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("card not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package bank
+
+import (
+	"checkout-task/logger"
+	db "checkout-task/models/db"
+	"checkout-task/vault"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+var DbConnection *gorm.DB
+
+// Vault encrypts the card numbers the simulator stores
+var Vault *vault.Vault
+
+func InitVault() {
+	v, err := vault.NewFromConfig(Config.VaultKeys, Config.VaultActiveKeyID, Config.VaultFingerprintKey)
+	if err != nil {
+		logger.Fatal("cannot initialise the card vault", zap.Error(err))
+	}
+	if err := v.DisableRetiredKeys(DbConnection); err != nil {
+		logger.Fatal("cannot initialise the card vault", zap.Error(err))
+	}
+	Vault = v
+}
+
+func ConnectDB() {
+	var err error
+	dsn := fmt.Sprintf("user=%s password=%s host=%s port=%s dbname=%s sslmode=disable",
+		Config.DBUserName, Config.DBUserPassword, Config.DBHost, Config.DBPort, Config.DBName)
+
+	DbConnection, err = gorm.Open("postgres", dsn)
+	if err != nil {
+		logger.Fatal("Failed to connect to the Database", zap.Error(err))
+	}
+	logger.Info("Connected Successfully to the Database")
+
+	DbConnection.AutoMigrate(&db.Card{})
+	DbConnection.AutoMigrate(&db.Fraud{})
+	DbConnection.AutoMigrate(&db.BankTransaction{})
+	DbConnection.AutoMigrate(&db.BankTransfer{})
+	DbConnection.AutoMigrate(&db.RetiredVaultKey{})
+
+	// A payout is only paid once, even when it is sent again while the first transfer is being stored
+	if err := DbConnection.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_bank_transfers_approved_payout
+		ON bank_transfers (payout_reference) WHERE status = '` + StatusApproved + `'`).Error; err != nil {
+		logger.Error("Failed to create the index of approved transfers", zap.Error(err))
+	}
+}
+
+// uniqueViolation reports whether the database refused a row for breaking a unique index
+func uniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,21 @@
+package bank
+
+import (
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestUniqueViolation(t *testing.T) {
+	// Test case 1: rows breaking a unique index are reported, wrapped or not
+	duplicate := &pq.Error{Code: "23505", Message: "duplicate key value violates unique constraint"}
+	assert.True(t, uniqueViolation(duplicate))
+	assert.True(t, uniqueViolation(fmt.Errorf("store transfer: %w", duplicate)))
+
+	// Test case 2: other errors are not
+	assert.False(t, uniqueViolation(&pq.Error{Code: "23502"}))
+	assert.False(t, uniqueViolation(errors.New("connection refused")))
+	assert.False(t, uniqueViolation(nil))
+}
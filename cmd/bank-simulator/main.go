@@ -0,0 +1,48 @@
+package main
+
+import (
+	"checkout-task/bank"
+	"checkout-task/logger"
+	"context"
+	"go.uber.org/zap"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// The bank simulator plays the acquiring bank for the gateway. It keeps card balances,
+// fraud rules and its own record of every authorization, capture, void and refund.
+func main() {
+	bank.LoadConfig()
+	bank.ConnectDB()
+	bank.InitVault()
+	bank.LoadScenarios(bank.Config.ScenariosFile)
+	go bank.RotateVaultKeys(10 * time.Minute)
+
+	server := &http.Server{
+		Addr:         bank.Config.ServerHost + ":" + bank.Config.ServerPort,
+		WriteTimeout: time.Second * 30,
+		ReadTimeout:  time.Second * 30,
+		IdleTimeout:  time.Second * 30,
+		Handler:      bank.New(),
+	}
+
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("listen", zap.Error(err))
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+	logger.Info("Shutdown Bank Simulator")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		logger.Fatal("Bank Simulator Shutdown", zap.Error(err))
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"checkout-task/bank"
+	"checkout-task/logger"
+	"checkout-task/services"
+	"go.uber.org/zap"
+)
+
+// encrypt-card-data encrypts the card numbers stored in plaintext before encryption at rest: the
+// payments of the gateway and the cards, frauds and transactions of the bank simulator. Run it once
+// after upgrading, and after loading plaintext seed data such as migrations/payments.sql.
+func main() {
+	services.LoadConfig()
+	services.ConnectDB()
+	services.InitVault()
+
+	if err := services.EncryptCardData(); err != nil {
+		logger.Fatal("Failed to encrypt gateway card data", zap.Error(err))
+	}
+
+	bank.LoadConfig()
+	bank.ConnectDB()
+	bank.InitVault()
+
+	if err := bank.EncryptCardData(); err != nil {
+		logger.Fatal("Failed to encrypt bank simulator card data", zap.Error(err))
+	}
+
+	logger.Info("Card data encrypted")
+}
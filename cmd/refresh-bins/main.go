@@ -0,0 +1,24 @@
+package main
+
+import (
+	"checkout-task/logger"
+	"checkout-task/services"
+	"go.uber.org/zap"
+	"os"
+)
+
+// refresh-bins replaces the BIN table with the content of a BIN file, BIN_FILE unless a path is
+// given. The gateway only loads BIN_FILE by itself while the table is empty.
+func main() {
+	services.LoadConfig()
+	services.ConnectDB()
+
+	path := services.Config.BINFile
+	if len(os.Args) > 1 {
+		path = os.Args[1]
+	}
+
+	if _, err := services.RefreshBINs(path); err != nil {
+		logger.Fatal("Failed to refresh the BIN table", zap.Error(err), zap.String("file", path))
+	}
+}
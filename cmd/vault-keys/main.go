@@ -0,0 +1,110 @@
+package main
+
+import (
+	"checkout-task/bank"
+	"checkout-task/services"
+	"fmt"
+	"os"
+	"sort"
+)
+
+const usage = `usage:
+  vault-keys status          count the rows depending on each configured key
+  vault-keys retire <key id> retire a key no row depends on any more
+  vault-keys fingerprints    recompute the card fingerprints after VAULT_FINGERPRINT_KEY changed`
+
+// vault-keys inspects and retires the keys of the card vault and recomputes card fingerprints. Retiring
+// checks the tables of the gateway and of the bank simulator, a retired key is refused by both from then on.
+func main() {
+	if len(os.Args) < 2 {
+		exit(usage)
+	}
+
+	services.LoadConfig()
+	services.ConnectDB()
+	services.InitVault()
+	bank.LoadConfig()
+	bank.ConnectDB()
+	bank.InitVault()
+
+	switch os.Args[1] {
+	case "status":
+		fmt.Printf("active key: %s\n", services.Vault.ActiveKeyID())
+		for _, keyID := range services.Vault.KeyIDs() {
+			fmt.Printf("key %s:\n", keyID)
+			usage, err := keyUsage(keyID)
+			if err != nil {
+				exit(err.Error())
+			}
+			printUsage(usage)
+		}
+
+	case "retire":
+		if len(os.Args) != 3 {
+			exit(usage)
+		}
+		keyID := os.Args[2]
+
+		// RetireVaultKey checks the rows of the gateway, the bank simulator keeps its own database
+		usage, err := keyUsage(keyID)
+		if err != nil {
+			exit(err.Error())
+		}
+		for _, count := range usage {
+			if count > 0 {
+				fmt.Printf("key %s cannot be retired, rows still depend on it:\n", keyID)
+				printUsage(usage)
+				exit("wait for the re-encryption job to finish, or run encrypt-card-data for rows that were never encrypted")
+			}
+		}
+
+		if err := services.RetireVaultKey(keyID); err != nil {
+			exit(err.Error())
+		}
+		fmt.Printf("key %s retired, remove it from VAULT_KEYS\n", keyID)
+
+	case "fingerprints":
+		if err := services.RefreshFingerprints(); err != nil {
+			exit(err.Error())
+		}
+		if err := bank.RefreshFingerprints(); err != nil {
+			exit(err.Error())
+		}
+		fmt.Println("card fingerprints recomputed")
+
+	default:
+		exit(usage)
+	}
+}
+
+// keyUsage counts by column the rows of the gateway and the bank simulator depending on the key
+func keyUsage(keyID string) (map[string]int, error) {
+	usage, err := services.KeyUsage(keyID)
+	if err != nil {
+		return nil, err
+	}
+	bankUsage, err := bank.KeyUsage(keyID)
+	if err != nil {
+		return nil, err
+	}
+	for column, count := range bankUsage {
+		usage[column] = count
+	}
+	return usage, nil
+}
+
+func printUsage(usage map[string]int) {
+	columns := make([]string, 0, len(usage))
+	for column := range usage {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+	for _, column := range columns {
+		fmt.Printf("  %-35s %d\n", column, usage[column])
+	}
+}
+
+func exit(message string) {
+	fmt.Fprintln(os.Stderr, message)
+	os.Exit(1)
+}
@@ -1,7 +1,24 @@
 package constants
 
+// Outcomes reported by the acquiring bank
 const (
-	SUCCESS    = "Success"
-	FAILURE    = "failure"
-	INPROGRESS = "In-Progress"
+	SUCCESS = "Success"
+	FAILURE = "failure"
+)
+
+// Payment statuses, see services.CanTransition for the allowed moves between them. CaptureRequested and
+// VoidRequested are held while the acquirer is asked, refunds are Pending until it answers. Payments whose
+// authorization got no answer wait in AuthorizationRequested.
+const (
+	PENDING                = "Pending"
+	AUTHORIZATIONREQUESTED = "AuthorizationRequested"
+	AUTHORIZED             = "Authorized"
+	CAPTUREREQUESTED       = "CaptureRequested"
+	VOIDREQUESTED          = "VoidRequested"
+	CAPTURED               = "Captured"
+	PARTIALLYREFUNDED      = "PartiallyRefunded"
+	REFUNDED               = "Refunded"
+	VOIDED                 = "Voided"
+	DECLINED               = "Declined"
+	FAILED                 = "Failed"
 )
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"checkout-task/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"net/http"
+	"time"
+)
+
+// pathMerchant loads the merchant of the ":id" path parameter, answering 404 when there is none
+func pathMerchant(c *gin.Context) (db.Merchant, bool) {
+	merchant, err := services.GetMerchantByMerchantID(c.Param("id"))
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusNotFound, err.Error())
+		return db.Merchant{}, false
+	}
+	return merchant, true
+}
+
+// apiKeyErrorStatus answers 404 for unknown keys and 400 for everything else
+func apiKeyErrorStatus(err error) int {
+	if err == services.ErrAPIKeyNotFound {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// CreateAPIKey creates an API key pair for a merchant.
+// @Summary Create an API key pair for a merchant.
+// @Description Create a publishable and secret key pair in test or live mode. The secret key is only shown in this response.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Merchant ID"
+// @Param apiKeyReq body models.APIKeyRequest true "API Key Request"
+// @Success 201 {object} models.Response
+// @Success 400 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /admin/merchants/{id}/api-keys [post]
+func CreateAPIKey(c *gin.Context) {
+	merchant, ok := pathMerchant(c)
+	if !ok {
+		return
+	}
+
+	var requestBody models.APIKeyRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	apiKey, secretKey, err := services.CreateAPIKey(merchant, requestBody.Mode)
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := &models.Response{
+		StatusCode: http.StatusCreated,
+		Success:    true,
+		Data:       gin.H{"API Key": apiKey, "Secret Key": secretKey},
+	}
+	response.SendResponse(c)
+}
+
+// ListAPIKeys lists the API key pairs of a merchant.
+// @Summary List the API key pairs of a merchant.
+// @Description List every key pair of the merchant, revoked ones included. Secret keys are never returned.
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Merchant ID"
+// @Success 200 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /admin/merchants/{id}/api-keys [get]
+func ListAPIKeys(c *gin.Context) {
+	merchant, ok := pathMerchant(c)
+	if !ok {
+		return
+	}
+
+	apiKeys, err := services.ListAPIKeys(merchant)
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"API Keys": apiKeys})
+}
+
+// RollAPIKey replaces an API key pair with a new one.
+// @Summary Roll an API key pair.
+// @Description Create a new key pair of the same mode. The old pair is revoked, or expires after expires_in_hours when given.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Merchant ID"
+// @Param key_id path string true "API Key ID"
+// @Param rollReq body models.RollAPIKeyRequest false "Roll API Key Request"
+// @Success 201 {object} models.Response
+// @Success 400 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /admin/merchants/{id}/api-keys/{key_id}/roll [post]
+func RollAPIKey(c *gin.Context) {
+	merchant, ok := pathMerchant(c)
+	if !ok {
+		return
+	}
+
+	var requestBody models.RollAPIKeyRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	apiKey, secretKey, err := services.RollAPIKey(merchant, c.Param("key_id"), time.Duration(requestBody.ExpiresInHours)*time.Hour)
+	if err != nil {
+		models.SendErrorResponse(c, apiKeyErrorStatus(err), err.Error())
+		return
+	}
+
+	response := &models.Response{
+		StatusCode: http.StatusCreated,
+		Success:    true,
+		Data:       gin.H{"API Key": apiKey, "Secret Key": secretKey},
+	}
+	response.SendResponse(c)
+}
+
+// RevokeAPIKey revokes an API key pair.
+// @Summary Revoke an API key pair.
+// @Description Revoke a key pair, requests made with it are rejected from now on.
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Merchant ID"
+// @Param key_id path string true "API Key ID"
+// @Success 200 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /admin/merchants/{id}/api-keys/{key_id} [delete]
+func RevokeAPIKey(c *gin.Context) {
+	merchant, ok := pathMerchant(c)
+	if !ok {
+		return
+	}
+
+	apiKey, err := services.RevokeAPIKey(merchant, c.Param("key_id"))
+	if err != nil {
+		models.SendErrorResponse(c, apiKeyErrorStatus(err), err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"API Key": apiKey})
+}
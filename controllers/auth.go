@@ -11,7 +11,7 @@ import (
 
 // GenerateAccessToken generates new access tokens.
 // @Summary Generate new access tokens.
-// @Description Generate new access tokens for the provided email.
+// @Description Generate new access tokens for the merchant registered with the provided email, authenticated by one of its secret API keys.
 // @Tags Tokens
 // @Accept  json
 // @Produce  json
@@ -29,7 +29,7 @@ func GenerateAccessToken(c *gin.Context) {
 	}
 
 	// generate new access tokens
-	accessToken, refreshToken, err := services.GenerateAccessTokens(requestBody.Email)
+	accessToken, refreshToken, err := services.GenerateAccessTokens(requestBody.Email, requestBody.SecretKey)
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
@@ -81,11 +81,25 @@ func Refresh(c *gin.Context) {
 		return
 	}
 
-	accessToken, refreshToken, err := services.GenerateAccessTokens(requestBody.Email)
+	// the new tokens belong to the merchant of the refresh token, whatever email was sent
+	merchant, err := services.GetMerchant(token.MerchantID)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	accessToken, refreshToken, err := services.GenerateMerchantTokens(merchant, token.APIKeyID, token.Mode)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
 	response.StatusCode = http.StatusOK
 	response.Success = true
 	response.Data = gin.H{
-		"Email": requestBody.Email,
+		"Email": merchant.Email,
 		"token": gin.H{
 			"access":  accessToken.GetResponseJson(),
 			"refresh": refreshToken.GetResponseJson()},
@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"checkout-task/models"
+	"checkout-task/services"
+	"checkout-task/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GetBalance returns the funds of the merchant.
+// @Summary Retrieve the balance of the merchant.
+// @Description Return the pending and available funds of the merchant per currency in minor units, from the ledger. Pending funds are captured but not settled yet, available funds are settled and wait for a payout.
+// @Tags Balance
+// @Produce json
+// @Param currency query string false "Only this currency"
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /balance [get]
+func GetBalance(c *gin.Context) {
+	currency := c.Query("currency")
+	if currency != "" && !utils.ValidateCurrency(currency) {
+		models.SendErrorResponse(c, http.StatusBadRequest, "unsupported currency")
+		return
+	}
+
+	balances, err := services.GetBalances(merchantID(c), currency)
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Balances": balances})
+}
@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"checkout-task/models"
+	"checkout-task/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"regexp"
+)
+
+var binParamPattern = regexp.MustCompile("^[0-9]{6,8}$")
+
+// GetBIN looks up the issuer attributes of a BIN.
+// @Summary Look up the issuer attributes of a BIN.
+// @Description Return the brand, issuer, issuing country, card type (credit, debit or prepaid) and whether cards of the BIN are commercial. The longest known BIN the given digits start with is returned.
+// @Tags Cards
+// @Produce json
+// @Param bin path string true "First 6 to 8 digits of the card number"
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Success 404 {object} models.Response
+// @Success 500 {object} models.Response
+// @Router /bins/{bin} [get]
+func GetBIN(c *gin.Context) {
+	if !binParamPattern.MatchString(c.Param("bin")) {
+		models.SendErrorResponse(c, http.StatusBadRequest, "bin must be 6 to 8 digits")
+		return
+	}
+
+	bin, err := services.LookupBIN(c.Param("bin"))
+	if err == services.ErrBINNotFound {
+		models.SendErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusInternalServerError, "cannot look up bin")
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"BIN": bin})
+}
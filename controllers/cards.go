@@ -0,0 +1,44 @@
+package controllers
+
+import (
+	"checkout-task/models"
+	"checkout-task/services"
+	"checkout-task/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"net/http"
+)
+
+// StoreCard stores a card in the vault.
+// @Summary Store a card in the vault.
+// @Description Encrypt the card number in the vault and return a card token, payments can be made with the token instead of the card details.
+// @Tags Cards
+// @Accept json
+// @Produce json
+// @Param cardReq body models.CardRequest true "Card Request"
+// @Success 201 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /cards [post]
+func StoreCard(c *gin.Context) {
+	var cardReq models.CardRequest
+	_ = c.ShouldBindBodyWith(&cardReq, binding.JSON)
+
+	vaultCard, err := services.TokenizeCard(merchantID(c), keyMode(c), cardReq)
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := &models.Response{
+		StatusCode: http.StatusCreated,
+		Success:    true,
+		Data: gin.H{"Card": models.CardResponse{
+			CardToken:        vaultCard.Token,
+			MaskedCardNumber: utils.MaskLast4(vaultCard.Last4),
+			Brand:            vaultCard.Brand,
+			ExpMonth:         vaultCard.ExpMonth,
+			ExpYear:          vaultCard.ExpYear,
+		}},
+	}
+	response.SendResponse(c)
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"checkout-task/models"
+	"checkout-task/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"net/http"
+)
+
+// CreateExport starts an export of the merchant's payments.
+// @Summary Export payments as CSV or NDJSON.
+// @Description Export the payments matching the filters of the list endpoint with masked card numbers. The file is written in the background, poll the export until it is completed and download it from its download_url.
+// @Tags Exports
+// @Accept json
+// @Produce json
+// @Param exportReq body models.ExportRequest true "Export Request"
+// @Success 202 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /exports [post]
+func CreateExport(c *gin.Context) {
+	var exportReq models.ExportRequest
+	_ = c.ShouldBindBodyWith(&exportReq, binding.JSON)
+
+	export, err := services.CreateExport(merchantID(c), keyMode(c), exportReq)
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := &models.Response{
+		StatusCode: http.StatusAccepted,
+		Success:    true,
+		Data:       gin.H{"Export": export},
+	}
+	response.SendResponse(c)
+}
+
+// GetExport reports the status of an export.
+// @Summary Retrieve the status of an export.
+// @Description Return the status and row count of an export, with its download_url once it is completed.
+// @Tags Exports
+// @Produce json
+// @Param id path string true "Export ID"
+// @Success 200 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /exports/{id} [get]
+func GetExport(c *gin.Context) {
+	export, err := services.GetExport(merchantID(c), keyMode(c), c.Param("id"))
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Export": export})
+}
+
+// DownloadExport sends the file of a completed export.
+// @Summary Download an export.
+// @Description Download the CSV or NDJSON file of a completed export.
+// @Tags Exports
+// @Produce octet-stream
+// @Param id path string true "Export ID"
+// @Success 200 {file} file
+// @Success 404 {object} models.Response
+// @Success 409 {object} models.Response
+// @Router /exports/{id}/download [get]
+func DownloadExport(c *gin.Context) {
+	path, name, err := services.ExportFile(merchantID(c), keyMode(c), c.Param("id"))
+	if err == services.ErrExportNotReady {
+		models.SendErrorResponse(c, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+
+	c.FileAttachment(path, name)
+}
@@ -0,0 +1,65 @@
+package controllers
+
+import (
+	"checkout-task/models"
+	"checkout-task/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"net/http"
+)
+
+// merchantID returns the merchant the request was authenticated for
+func merchantID(c *gin.Context) int64 {
+	return c.GetInt64("merchantId")
+}
+
+// keyMode returns the mode, test or live, of the API key the request was authenticated with
+func keyMode(c *gin.Context) string {
+	return c.GetString("apiKeyMode")
+}
+
+// CreateMerchant registers a new merchant.
+// @Summary Register a new merchant.
+// @Description Register a merchant. Access tokens are then generated with the merchant's email and only give access to the merchant's own payments.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param merchantReq body models.MerchantRequest true "Merchant Request"
+// @Success 201 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /admin/merchants [post]
+func CreateMerchant(c *gin.Context) {
+	var requestBody models.MerchantRequest
+	_ = c.ShouldBindBodyWith(&requestBody, binding.JSON)
+
+	merchant, err := services.CreateMerchant(requestBody)
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := &models.Response{
+		StatusCode: http.StatusCreated,
+		Success:    true,
+		Data:       gin.H{"Merchant": merchant},
+	}
+	response.SendResponse(c)
+}
+
+// ListMerchants lists the registered merchants.
+// @Summary List the registered merchants.
+// @Description List every registered merchant, oldest first.
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /admin/merchants [get]
+func ListMerchants(c *gin.Context) {
+	merchants, err := services.ListMerchants()
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Merchants": merchants})
+}
@@ -6,13 +6,9 @@ import (
 	"checkout-task/services"
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
-	"github.com/sony/gobreaker"
 	"net/http"
 )
 
-// create a circuit breaker to handle failures in the payment processing service
-var cb = gobreaker.NewCircuitBreaker(gobreaker.Settings{})
-
 // ProcessPayment handles the processing of a payment request.
 // @Summary Handle the processing of a payment request.
 // @Description Handle the processing of a payment request by validating the payment details, processing the payment and returning the response.
@@ -21,13 +17,16 @@ var cb = gobreaker.NewCircuitBreaker(gobreaker.Settings{})
 // @Produce json
 // @Param paymentReq body models.PaymentRequest true "Payment Request"
 // @Success 201 {object} models.Response
+// @Success 202 {object} models.Response
 // @Success 400 {object} models.Response
 // @Router /payment [post]
 func ProcessPayment(c *gin.Context) {
 	var paymentReq models.PaymentRequest
-	_ = c.ShouldBindBodyWith(&paymentReq, binding.JSON)
+	if err := c.ShouldBindBodyWith(&paymentReq, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": models.BindErrorMessage(err)})
+		return
+	}
 
-	// Validate payment details
 	// Validate payment details
 	if err := models.ValidatePaymentRequest(paymentReq); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -39,31 +38,52 @@ func ProcessPayment(c *gin.Context) {
 		Success:    false,
 	}
 
-	// process the payment using the circuit breaker
-	paymentResponse, err := cb.Execute(func() (interface{}, error) {
-		return services.ProcessPayment(paymentReq)
-	})
+	pr, err := services.ProcessPayment(merchantID(c), keyMode(c), paymentReq)
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
 		return
 	}
 
-	// cast the payment response to the expected type
-	pr, ok := paymentResponse.(models.ProcessPaymentResponse)
-	if !ok {
-		response.Message = "Error processing payment"
+	// The acquirer did not answer the authorization, it is confirmed in the background
+	if pr.Status == constants.AUTHORIZATIONREQUESTED {
+		response.Success = true
+		response.StatusCode = http.StatusAccepted
+		response.Data = gin.H{
+			"Payment Status": pr.Status,
+			"Payment ID":     pr.PaymentIdentifier,
+			"Card Token":     pr.CardToken,
+			"Brand":          pr.Brand,
+			"Message":        "The authorization is being confirmed with the acquirer, the payment is not captured",
+		}
+		response.SendResponse(c)
+		return
+	}
+
+	// The acquirer did not answer the capture, it is confirmed in the background
+	if pr.Status == constants.CAPTUREREQUESTED {
+		response.Success = true
+		response.StatusCode = http.StatusAccepted
+		response.Data = gin.H{
+			"Payment Status": pr.Status,
+			"Payment ID":     pr.PaymentIdentifier,
+			"Card Token":     pr.CardToken,
+			"Brand":          pr.Brand,
+			"Message":        "Payment authorized, the capture is being confirmed with the acquirer",
+		}
 		response.SendResponse(c)
 		return
 	}
 
 	// // Return success response
-	if pr.Status == constants.SUCCESS {
+	if pr.Status == constants.CAPTURED {
 		response.Success = true
 		response.StatusCode = http.StatusCreated
 		response.Data = gin.H{
 			"Payment Status": pr.Status,
 			"Payment ID":     pr.PaymentIdentifier,
+			"Card Token":     pr.CardToken,
+			"Brand":          pr.Brand,
 			"Message":        "Payment processed successfully",
 		}
 		response.SendResponse(c)
@@ -71,12 +91,212 @@ func ProcessPayment(c *gin.Context) {
 		response.StatusCode = http.StatusBadRequest
 		response.Success = false
 		response.Data = gin.H{
-			"Status":  pr.Status,
-			"Message": "Payment failed",
+			"Status":           pr.Status,
+			"Message":          "Payment failed",
+			"decline_code":     pr.DeclineCode,
+			"decline_category": pr.DeclineCategory,
+			"retryable":        pr.Retryable,
+		}
+		response.SendResponse(c)
+	}
+
+}
+
+// AuthorizePayment handles the authorization of a payment request.
+// @Summary Authorize a payment and hold the funds on the card.
+// @Description Validate the payment details and place a hold on the card for the amount. The held funds are settled later through the capture endpoint.
+// @Tags Payments
+// @Accept json
+// @Produce json
+// @Param paymentReq body models.PaymentRequest true "Payment Request"
+// @Success 201 {object} models.Response
+// @Success 202 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /payments/authorize [post]
+func AuthorizePayment(c *gin.Context) {
+	var paymentReq models.PaymentRequest
+	if err := c.ShouldBindBodyWith(&paymentReq, binding.JSON); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": models.BindErrorMessage(err)})
+		return
+	}
+
+	// Validate payment details
+	if err := models.ValidatePaymentRequest(paymentReq); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	pr, err := services.AuthorizePayment(merchantID(c), keyMode(c), paymentReq)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	// The acquirer did not answer, the authorization is confirmed in the background
+	if pr.Status == constants.AUTHORIZATIONREQUESTED {
+		response.Success = true
+		response.StatusCode = http.StatusAccepted
+		response.Data = gin.H{
+			"Payment Status": pr.Status,
+			"Payment ID":     pr.PaymentIdentifier,
+			"Card Token":     pr.CardToken,
+			"Brand":          pr.Brand,
+			"Message":        "The authorization is being confirmed with the acquirer",
+		}
+		response.SendResponse(c)
+		return
+	}
+
+	if pr.Status != constants.AUTHORIZED {
+		response.Data = gin.H{
+			"Status":           pr.Status,
+			"Message":          "Payment authorization failed",
+			"decline_code":     pr.DeclineCode,
+			"decline_category": pr.DeclineCategory,
+			"retryable":        pr.Retryable,
 		}
 		response.SendResponse(c)
+		return
 	}
 
+	response.Success = true
+	response.StatusCode = http.StatusCreated
+	response.Data = gin.H{
+		"Payment Status": pr.Status,
+		"Payment ID":     pr.PaymentIdentifier,
+		"Card Token":     pr.CardToken,
+		"Brand":          pr.Brand,
+		"Message":        "Payment authorized successfully",
+	}
+	response.SendResponse(c)
+}
+
+// CapturePayment handles the capture of a previously authorized payment.
+// @Summary Capture an authorized payment.
+// @Description Settle the funds held on the card by a previously authorized payment.
+// @Tags Payments
+// @Accept json
+// @Produce json
+// @Param id path string true "Payment ID"
+// @Success 200 {object} models.Response
+// @Success 202 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /payments/{id}/capture [post]
+func CapturePayment(c *gin.Context) {
+	paymentID := c.Param("id")
+
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	pr, err := services.CapturePayment(merchantID(c), keyMode(c), paymentID)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	// The acquirer did not answer, the capture is confirmed in the background
+	if pr.Status == constants.CAPTUREREQUESTED {
+		response.Success = true
+		response.StatusCode = http.StatusAccepted
+		response.Data = gin.H{
+			"Payment Status": pr.Status,
+			"Payment ID":     pr.PaymentIdentifier,
+			"Message":        "The capture is being confirmed with the acquirer",
+		}
+		response.SendResponse(c)
+		return
+	}
+
+	if pr.Status != constants.CAPTURED {
+		response.Data = gin.H{
+			"Status":           pr.Status,
+			"Message":          "Payment capture failed",
+			"decline_code":     pr.DeclineCode,
+			"decline_category": pr.DeclineCategory,
+			"retryable":        pr.Retryable,
+		}
+		response.SendResponse(c)
+		return
+	}
+
+	response.Success = true
+	response.StatusCode = http.StatusOK
+	response.Data = gin.H{
+		"Payment Status": pr.Status,
+		"Payment ID":     pr.PaymentIdentifier,
+		"Message":        "Payment captured successfully",
+	}
+	response.SendResponse(c)
+}
+
+// VoidPayment handles the void of an authorization that was never captured.
+// @Summary Void an authorized payment.
+// @Description Release the funds held on the card by an authorized payment. Captured payments cannot be voided and have to be refunded instead.
+// @Tags Payments
+// @Accept json
+// @Produce json
+// @Param id path string true "Payment ID"
+// @Success 200 {object} models.Response
+// @Success 202 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /payments/{id}/void [post]
+func VoidPayment(c *gin.Context) {
+	paymentID := c.Param("id")
+
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	pr, err := services.VoidPayment(merchantID(c), keyMode(c), paymentID)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	// The acquirer did not answer, the void is confirmed in the background
+	if pr.Status == constants.VOIDREQUESTED {
+		response.Success = true
+		response.StatusCode = http.StatusAccepted
+		response.Data = gin.H{
+			"Payment Status": pr.Status,
+			"Payment ID":     pr.PaymentIdentifier,
+			"Message":        "The void is being confirmed with the acquirer",
+		}
+		response.SendResponse(c)
+		return
+	}
+
+	if pr.Status != constants.VOIDED {
+		response.Data = gin.H{
+			"Status":           pr.Status,
+			"Message":          "Payment void failed",
+			"decline_code":     pr.DeclineCode,
+			"decline_category": pr.DeclineCategory,
+			"retryable":        pr.Retryable,
+		}
+		response.SendResponse(c)
+		return
+	}
+
+	response.Success = true
+	response.StatusCode = http.StatusOK
+	response.Data = gin.H{
+		"Payment Status": pr.Status,
+		"Payment ID":     pr.PaymentIdentifier,
+		"Message":        "Payment voided successfully",
+	}
+	response.SendResponse(c)
 }
 
 // GetPaymentDetails retrieves payment details for a specified payment.
@@ -97,24 +317,13 @@ func GetPaymentDetails(c *gin.Context) {
 		Success:    false,
 	}
 
-	// retrieve the previous made payment details
-	paymentDetails, err := cb.Execute(func() (interface{}, error) {
-		return services.RetrievePayment(paymentID)
-	})
+	paymentsInfo, err := services.RetrievePayment(merchantID(c), keyMode(c), paymentID)
 	if err != nil {
 		response.Message = err.Error()
 		response.SendResponse(c)
 		return
 	}
 
-	// cast the payment response to the expected type
-	paymentsInfo, ok := paymentDetails.(models.PaymentResponse)
-	if !ok {
-		response.Message = "Error processing payment"
-		response.SendResponse(c)
-		return
-	}
-
 	response.StatusCode = http.StatusOK
 	response.Success = true
 	response.Data = gin.H{
@@ -122,3 +331,89 @@ func GetPaymentDetails(c *gin.Context) {
 	}
 	response.SendResponse(c)
 }
+
+// ListPayments lists the payments of the merchant.
+// @Summary List the payments of the merchant.
+// @Description List payments newest first, or oldest first with order=asc, filtered by status, currency, amount range (minor units), created date range (RFC 3339, created_to excluded), card last4, brand and merchant reference. Pages hold up to limit payments (20 by default, at most 100), pass the Next Cursor of a page as cursor to fetch the next one.
+// @Tags Payments
+// @Produce  json
+// @Param status query string false "Payment status"
+// @Param currency query string false "Currency"
+// @Param min_amount query int false "Minimum amount in minor units"
+// @Param max_amount query int false "Maximum amount in minor units"
+// @Param created_from query string false "Created at or after, RFC 3339"
+// @Param created_to query string false "Created before, RFC 3339"
+// @Param last4 query string false "Last four digits of the card number"
+// @Param brand query string false "Card brand"
+// @Param reference query string false "Merchant reference"
+// @Param order query string false "asc or desc"
+// @Param limit query int false "Page size"
+// @Param cursor query string false "Next Cursor of the previous page"
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /payments [get]
+func ListPayments(c *gin.Context) {
+	var listRequest models.PaymentListRequest
+	_ = c.ShouldBindQuery(&listRequest)
+
+	list, err := services.ListPayments(merchantID(c), keyMode(c), listRequest)
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{
+		"Payments":    list.Payments,
+		"Has More":    list.HasMore,
+		"Next Cursor": list.NextCursor,
+	})
+}
+
+// GetPaymentEvents retrieves the status history of a payment.
+// @Summary Retrieve the status history of a payment.
+// @Description Retrieve every status transition of a payment with the time and reason it happened, oldest first.
+// @Tags Payments
+// @Accept  json
+// @Produce  json
+// @Param id path string true "Payment ID"
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /payments/{id}/events [get]
+func GetPaymentEvents(c *gin.Context) {
+	paymentID := c.Param("id")
+
+	events, err := services.RetrievePaymentEvents(merchantID(c), keyMode(c), paymentID)
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{
+		"Payment ID":     paymentID,
+		"Payment Events": events,
+	})
+}
+
+// GetPaymentFees retrieves the breakdown of the fee charged for a payment.
+// @Summary Retrieve the fees of a payment.
+// @Description Return the fee charged when the payment was captured: the pricing plan and rule applied, the rate in basis points of the amount, the fixed fee and what the merchant is paid, in minor units.
+// @Tags Payments
+// @Produce  json
+// @Param id path string true "Payment ID"
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Success 409 {object} models.Response
+// @Router /payments/{id}/fees [get]
+func GetPaymentFees(c *gin.Context) {
+	fees, err := services.GetPaymentFees(merchantID(c), keyMode(c), c.Param("id"))
+	if err == services.ErrPaymentNotCaptured {
+		models.SendErrorResponse(c, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Payment Fees": fees})
+}
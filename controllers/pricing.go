@@ -0,0 +1,175 @@
+package controllers
+
+import (
+	"checkout-task/models"
+	"checkout-task/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"net/http"
+)
+
+// pricingErrorStatus answers 404 for unknown pricing plans and 400 for everything else
+func pricingErrorStatus(err error) int {
+	if err == services.ErrPricingPlanNotFound {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// CreatePricingPlan adds a pricing plan.
+// @Summary Add a pricing plan.
+// @Description Store a pricing plan with its rules. Rates are in basis points of the amount, fixed fees in minor units, neither can be negative. A plan created with is_default charges every merchant without a plan of its own, in place of the previous default plan.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param planReq body models.PricingPlanRequest true "Pricing Plan Request"
+// @Success 201 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /admin/pricing-plans [post]
+func CreatePricingPlan(c *gin.Context) {
+	var planReq models.PricingPlanRequest
+	_ = c.ShouldBindBodyWith(&planReq, binding.JSON)
+
+	plan, err := services.CreatePricingPlan(planReq)
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := &models.Response{
+		StatusCode: http.StatusCreated,
+		Success:    true,
+		Data:       gin.H{"Pricing Plan": plan},
+	}
+	response.SendResponse(c)
+}
+
+// ListPricingPlans lists the pricing plans.
+// @Summary List the pricing plans.
+// @Description List every pricing plan with its rules, oldest first.
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /admin/pricing-plans [get]
+func ListPricingPlans(c *gin.Context) {
+	plans, err := services.ListPricingPlans()
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Pricing Plans": plans})
+}
+
+// ReplacePricingPlanRules replaces the rules of a pricing plan.
+// @Summary Replace the rules of a pricing plan.
+// @Description Replace every rule of the plan with the rules of the request. Payments captured afterwards are charged by the new rules, fees already charged do not change.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param plan_id path string true "Plan ID"
+// @Param rulesReq body models.PricingRulesRequest true "Pricing Rules Request"
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /admin/pricing-plans/{plan_id}/rules [put]
+func ReplacePricingPlanRules(c *gin.Context) {
+	var rulesReq models.PricingRulesRequest
+	_ = c.ShouldBindBodyWith(&rulesReq, binding.JSON)
+
+	plan, err := services.ReplacePricingPlanRules(c.Param("plan_id"), rulesReq.Rules)
+	if err != nil {
+		models.SendErrorResponse(c, pricingErrorStatus(err), err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Pricing Plan": plan})
+}
+
+// GetMerchantPricing shows how a merchant is charged.
+// @Summary Retrieve the pricing of a merchant.
+// @Description Return the pricing plan of the merchant, empty for the default plan, and the rules overriding it.
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Merchant ID"
+// @Success 200 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /admin/merchants/{id}/pricing [get]
+func GetMerchantPricing(c *gin.Context) {
+	merchant, ok := pathMerchant(c)
+	if !ok {
+		return
+	}
+
+	pricing, err := services.GetMerchantPricing(merchant)
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Pricing": pricing})
+}
+
+// SetMerchantPricingPlan moves a merchant to a pricing plan.
+// @Summary Change the pricing plan of a merchant.
+// @Description Charge the merchant by the plan with plan_id, or by the default plan when plan_id is empty.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Merchant ID"
+// @Param planReq body models.MerchantPricingPlanRequest true "Merchant Pricing Plan Request"
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /admin/merchants/{id}/pricing/plan [put]
+func SetMerchantPricingPlan(c *gin.Context) {
+	merchant, ok := pathMerchant(c)
+	if !ok {
+		return
+	}
+
+	var planReq models.MerchantPricingPlanRequest
+	if err := c.ShouldBindBodyWith(&planReq, binding.JSON); err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, models.BindErrorMessage(err))
+		return
+	}
+
+	pricing, err := services.SetMerchantPricingPlan(merchant, planReq.PlanID)
+	if err != nil {
+		models.SendErrorResponse(c, pricingErrorStatus(err), err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Pricing": pricing})
+}
+
+// ReplaceMerchantPricingOverrides replaces the rules overriding the plan of a merchant.
+// @Summary Replace the pricing overrides of a merchant.
+// @Description Replace every rule overriding the plan of the merchant with the rules of the request, an empty list removes the overrides.
+// @Tags Admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Merchant ID"
+// @Param rulesReq body models.PricingRulesRequest true "Pricing Rules Request"
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /admin/merchants/{id}/pricing/overrides [put]
+func ReplaceMerchantPricingOverrides(c *gin.Context) {
+	merchant, ok := pathMerchant(c)
+	if !ok {
+		return
+	}
+
+	var rulesReq models.PricingRulesRequest
+	_ = c.ShouldBindBodyWith(&rulesReq, binding.JSON)
+
+	pricing, err := services.ReplaceMerchantPricingOverrides(merchant, rulesReq.Rules)
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Pricing": pricing})
+}
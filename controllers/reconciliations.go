@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"checkout-task/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+// CreateReconciliation reconciles a settlement file of the acquirer.
+// @Summary Reconcile an acquirer settlement file.
+// @Description Match the captures of the acquirer's settlement file for a day to the payments by acquirer reference and amount. The run is stored and answered with its discrepancies: captures missing on our side, payments captured that day missing at the bank and amount mismatches.
+// @Tags Admin
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Settlement file (CSV)"
+// @Param date formData string true "Settlement date, YYYY-MM-DD"
+// @Success 201 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /admin/reconciliations [post]
+func CreateReconciliation(c *gin.Context) {
+	date, err := time.Parse("2006-01-02", c.PostForm("date"))
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, "date must be YYYY-MM-DD")
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, "file is required")
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, "file cannot be read")
+		return
+	}
+	defer file.Close()
+
+	report, err := services.ReconcileSettlementFile(date, fileHeader.Filename, file)
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := &models.Response{
+		StatusCode: http.StatusCreated,
+		Success:    true,
+		Data:       gin.H{"Reconciliation": report},
+	}
+	response.SendResponse(c)
+}
+
+// ListReconciliations lists the reconciliation runs.
+// @Summary List the reconciliation runs.
+// @Description List the latest 100 reconciliation runs with their counts of matched lines and discrepancies, newest first.
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /admin/reconciliations [get]
+func ListReconciliations(c *gin.Context) {
+	runs, err := services.ListReconciliationRuns()
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Reconciliations": runs})
+}
+
+// GetReconciliation reports a reconciliation run.
+// @Summary Report a reconciliation run.
+// @Description Return a reconciliation run with its discrepancies, or with the items in a status.
+// @Tags Admin
+// @Produce json
+// @Param id path string true "Run ID"
+// @Param status query string false "matched, missing_on_our_side, missing_at_bank or amount_mismatch"
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /admin/reconciliations/{id} [get]
+func GetReconciliation(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", db.ReconciliationMatched, db.ReconciliationMissingOnOurSide, db.ReconciliationMissingAtBank, db.ReconciliationAmountMismatch:
+	default:
+		models.SendErrorResponse(c, http.StatusBadRequest, "status must be matched, missing_on_our_side, missing_at_bank or amount_mismatch")
+		return
+	}
+
+	report, err := services.GetReconciliationReport(c.Param("id"), status)
+	if err == services.ErrReconciliationRunNotFound {
+		models.SendErrorResponse(c, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Reconciliation": report})
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"checkout-task/constants"
+	"checkout-task/models"
+	"checkout-task/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"net/http"
+)
+
+// RefundPayment handles a full or partial refund of a captured payment.
+// @Summary Refund a captured payment.
+// @Description Refund part or all of a captured payment back to the card. Several partial refunds can be made until the captured amount is used up; omitting the amount refunds whatever is left.
+// @Tags Refunds
+// @Accept json
+// @Produce json
+// @Param id path string true "Payment ID"
+// @Param refundReq body models.RefundRequest false "Refund Request"
+// @Success 201 {object} models.Response
+// @Success 202 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /payments/{id}/refunds [post]
+func RefundPayment(c *gin.Context) {
+	paymentID := c.Param("id")
+
+	var refundReq models.RefundRequest
+	_ = c.ShouldBindBodyWith(&refundReq, binding.JSON)
+
+	response := &models.Response{
+		StatusCode: http.StatusBadRequest,
+		Success:    false,
+	}
+
+	rr, err := services.RefundPayment(merchantID(c), keyMode(c), paymentID, refundReq.Amount)
+	if err != nil {
+		response.Message = err.Error()
+		response.SendResponse(c)
+		return
+	}
+
+	// The acquirer did not answer, the refund is confirmed in the background
+	if rr.Status == constants.PENDING {
+		response.Success = true
+		response.StatusCode = http.StatusAccepted
+		response.Data = gin.H{
+			"Refund ID":     rr.RefundID,
+			"Payment ID":    rr.PaymentIdentifier,
+			"Amount":        rr.Amount,
+			"Currency":      rr.Currency,
+			"Refund Status": rr.Status,
+			"Message":       "The refund is being confirmed with the acquirer",
+		}
+		response.SendResponse(c)
+		return
+	}
+
+	if rr.Status != constants.SUCCESS {
+		response.Data = gin.H{
+			"Refund ID": rr.RefundID,
+			"Status":    rr.Status,
+			"Message":   "Refund failed",
+		}
+		response.SendResponse(c)
+		return
+	}
+
+	response.Success = true
+	response.StatusCode = http.StatusCreated
+	response.Data = gin.H{
+		"Refund ID":     rr.RefundID,
+		"Payment ID":    rr.PaymentIdentifier,
+		"Amount":        rr.Amount,
+		"Currency":      rr.Currency,
+		"Refund Status": rr.Status,
+		"Message":       "Refund processed successfully",
+	}
+	response.SendResponse(c)
+}
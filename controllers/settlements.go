@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"checkout-task/models"
+	"checkout-task/services"
+	"checkout-task/utils"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+func settlementErrorStatus(err error) int {
+	if err == services.ErrSettlementBatchNotFound {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// ListSettlements lists the settlement batches of the merchant.
+// @Summary List the settlement batches.
+// @Description List the latest 100 settlement batches of the merchant with their payouts, newest first. A batch settles, once a day, the payments captured and refunds made in a currency before midnight UTC.
+// @Tags Settlements
+// @Produce json
+// @Param currency query string false "Only this currency"
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /settlements [get]
+func ListSettlements(c *gin.Context) {
+	currency := c.Query("currency")
+	if currency != "" && !utils.ValidateCurrency(currency) {
+		models.SendErrorResponse(c, http.StatusBadRequest, "unsupported currency")
+		return
+	}
+
+	batches, err := services.ListSettlementBatches(merchantID(c), currency)
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Settlement Batches": batches})
+}
+
+// GetSettlement retrieves a settlement batch of the merchant.
+// @Summary Retrieve a settlement batch.
+// @Description Return the totals of a settlement batch and its payout: the status of the transfer and when the merchant was paid.
+// @Tags Settlements
+// @Produce json
+// @Param id path string true "Batch ID"
+// @Success 200 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /settlements/{id} [get]
+func GetSettlement(c *gin.Context) {
+	batch, err := services.GetSettlementBatch(merchantID(c), c.Param("id"))
+	if err != nil {
+		models.SendErrorResponse(c, settlementErrorStatus(err), err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Settlement Batch": batch})
+}
+
+// ListSettlementLines lists the line items of a settlement batch.
+// @Summary List the line items of a settlement batch.
+// @Description List the payments and refunds settled in a batch with their amount, fee and net in minor units.
+// @Tags Settlements
+// @Produce json
+// @Param id path string true "Batch ID"
+// @Success 200 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /settlements/{id}/lines [get]
+func ListSettlementLines(c *gin.Context) {
+	lines, err := services.ListSettlementLines(merchantID(c), c.Param("id"))
+	if err != nil {
+		models.SendErrorResponse(c, settlementErrorStatus(err), err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Settlement Lines": lines})
+}
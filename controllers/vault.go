@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"checkout-task/models"
+	"checkout-task/services"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// GetVaultStatus reports the keys of the card vault.
+// @Summary Report the keys of the card vault.
+// @Description List the configured keys with the number of rows depending on each, and how many rows still wait to be re-encrypted under the active key.
+// @Tags Admin
+// @Produce json
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /admin/vault/keys [get]
+func GetVaultStatus(c *gin.Context) {
+	status, err := services.VaultKeysStatus()
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Vault": status})
+}
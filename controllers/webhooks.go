@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"checkout-task/services"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"net/http"
+)
+
+// webhookErrorStatus answers 404 for unknown endpoints and deliveries and 400 for everything else
+func webhookErrorStatus(err error) int {
+	if err == services.ErrWebhookEndpointNotFound || err == services.ErrWebhookDeliveryNotFound {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+// CreateWebhookEndpoint adds a webhook endpoint for the merchant.
+// @Summary Add a webhook endpoint.
+// @Description Register a URL receiving the merchant's events, all of them unless events lists the event types to send. Events are signed with the secret returned in the response.
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param endpointReq body models.WebhookEndpointRequest true "Webhook Endpoint Request"
+// @Success 201 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /webhooks/endpoints [post]
+func CreateWebhookEndpoint(c *gin.Context) {
+	var endpointReq models.WebhookEndpointRequest
+	_ = c.ShouldBindBodyWith(&endpointReq, binding.JSON)
+
+	endpoint, secret, err := services.CreateWebhookEndpoint(merchantID(c), keyMode(c), endpointReq)
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response := &models.Response{
+		StatusCode: http.StatusCreated,
+		Success:    true,
+		Data: gin.H{
+			"Webhook Endpoint": endpoint,
+			"Secret":           secret,
+		},
+	}
+	response.SendResponse(c)
+}
+
+// ListWebhookEndpoints lists the webhook endpoints of the merchant.
+// @Summary List the webhook endpoints.
+// @Tags Webhooks
+// @Produce json
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Router /webhooks/endpoints [get]
+func ListWebhookEndpoints(c *gin.Context) {
+	endpoints, err := services.ListWebhookEndpoints(merchantID(c), keyMode(c))
+	if err != nil {
+		models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Webhook Endpoints": endpoints})
+}
+
+// GetWebhookEndpoint retrieves a webhook endpoint of the merchant.
+// @Summary Retrieve a webhook endpoint.
+// @Description Return a webhook endpoint with the secret its events are signed with.
+// @Tags Webhooks
+// @Produce json
+// @Param id path string true "Endpoint ID"
+// @Success 200 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /webhooks/endpoints/{id} [get]
+func GetWebhookEndpoint(c *gin.Context) {
+	endpoint, secret, err := services.GetWebhookEndpoint(merchantID(c), keyMode(c), c.Param("id"))
+	if err != nil {
+		models.SendErrorResponse(c, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{
+		"Webhook Endpoint": endpoint,
+		"Secret":           secret,
+	})
+}
+
+// UpdateWebhookEndpoint changes a webhook endpoint of the merchant.
+// @Summary Update a webhook endpoint.
+// @Description Change the URL, description, event types or enabled flag of an endpoint, fields left out keep their value. Deliveries to disabled endpoints are dead-lettered.
+// @Tags Webhooks
+// @Accept json
+// @Produce json
+// @Param id path string true "Endpoint ID"
+// @Param updateReq body models.WebhookEndpointUpdateRequest true "Webhook Endpoint Update Request"
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /webhooks/endpoints/{id} [patch]
+func UpdateWebhookEndpoint(c *gin.Context) {
+	var updateReq models.WebhookEndpointUpdateRequest
+	_ = c.ShouldBindBodyWith(&updateReq, binding.JSON)
+
+	endpoint, err := services.UpdateWebhookEndpoint(merchantID(c), keyMode(c), c.Param("id"), updateReq)
+	if err != nil {
+		models.SendErrorResponse(c, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Webhook Endpoint": endpoint})
+}
+
+// DeleteWebhookEndpoint removes a webhook endpoint of the merchant.
+// @Summary Delete a webhook endpoint.
+// @Description Remove an endpoint, its deliveries still waiting are dead-lettered.
+// @Tags Webhooks
+// @Produce json
+// @Param id path string true "Endpoint ID"
+// @Success 200 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /webhooks/endpoints/{id} [delete]
+func DeleteWebhookEndpoint(c *gin.Context) {
+	if err := services.DeleteWebhookEndpoint(merchantID(c), keyMode(c), c.Param("id")); err != nil {
+		models.SendErrorResponse(c, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Message": "Webhook endpoint deleted"})
+}
+
+// ListWebhookDeliveries lists the latest deliveries to a webhook endpoint.
+// @Summary List the deliveries to a webhook endpoint.
+// @Description List the latest 100 deliveries to an endpoint, newest first, optionally only those in a status.
+// @Tags Webhooks
+// @Produce json
+// @Param id path string true "Endpoint ID"
+// @Param status query string false "pending, succeeded or dead"
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /webhooks/endpoints/{id}/deliveries [get]
+func ListWebhookDeliveries(c *gin.Context) {
+	status := c.Query("status")
+	if status != "" && status != db.DeliveryStatusPending && status != db.DeliveryStatusSucceeded && status != db.DeliveryStatusDead {
+		models.SendErrorResponse(c, http.StatusBadRequest, "status must be pending, succeeded or dead")
+		return
+	}
+
+	deliveries, err := services.ListWebhookDeliveries(merchantID(c), keyMode(c), c.Param("id"), status)
+	if err != nil {
+		models.SendErrorResponse(c, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Webhook Deliveries": deliveries})
+}
+
+// GetWebhookDelivery retrieves a webhook delivery with the log of its attempts.
+// @Summary Retrieve a webhook delivery.
+// @Description Return a delivery with every attempt made: the answer of the endpoint, the error and how long it took.
+// @Tags Webhooks
+// @Produce json
+// @Param id path string true "Delivery ID"
+// @Success 200 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /webhooks/deliveries/{id} [get]
+func GetWebhookDelivery(c *gin.Context) {
+	delivery, err := services.GetWebhookDelivery(merchantID(c), keyMode(c), c.Param("id"))
+	if err != nil {
+		models.SendErrorResponse(c, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Webhook Delivery": delivery})
+}
+
+// RedeliverWebhook sends a webhook delivery again.
+// @Summary Redeliver a webhook.
+// @Description Send a delivery again straight away, including dead-lettered ones. The delivery is marked succeeded when the endpoint accepts it and keeps its status otherwise.
+// @Tags Webhooks
+// @Produce json
+// @Param id path string true "Delivery ID"
+// @Success 200 {object} models.Response
+// @Success 400 {object} models.Response
+// @Success 404 {object} models.Response
+// @Router /webhooks/deliveries/{id}/redeliver [post]
+func RedeliverWebhook(c *gin.Context) {
+	delivery, err := services.RedeliverWebhook(merchantID(c), keyMode(c), c.Param("id"))
+	if err != nil {
+		models.SendErrorResponse(c, webhookErrorStatus(err), err.Error())
+		return
+	}
+
+	models.SendResponseData(c, gin.H{"Webhook Delivery": delivery})
+}
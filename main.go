@@ -16,11 +16,22 @@ func main() {
 
 	services.LoadConfig()
 	services.ConnectDB()
+	services.InitAcquirer()
+	services.InitVault()
+	services.LoadBINs()
 
 	if services.Config.UseRedis {
 		services.CheckRedisConnection()
 	}
 
+	go services.PurgeExpiredIdempotencyKeys(time.Hour)
+	go services.RotateVaultKeys(10 * time.Minute)
+	go services.ProcessExports(5 * time.Second)
+	go services.RecoverBankOperations(30 * time.Second)
+	go services.RelayOutbox(time.Second)
+	go services.DeliverWebhooks(5 * time.Second)
+	go services.ProcessSettlements(5 * time.Minute)
+
 	routes.InitGin()
 	router := routes.New()
 
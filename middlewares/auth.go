@@ -4,9 +4,11 @@ import (
 	"checkout-task/models"
 	db "checkout-task/models/db"
 	"checkout-task/services"
+	"crypto/subtle"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -21,6 +23,60 @@ func JWTMiddleware() gin.HandlerFunc {
 
 		c.Set("userIdHex", tokenModel.ID)
 		c.Set("userId", tokenModel.ID)
+		c.Set("merchantId", tokenModel.MerchantID)
+		c.Set("apiKeyMode", tokenModel.Mode)
+
+		c.Next()
+	}
+}
+
+// AuthMiddleware authenticates merchants with a secret API key in the Authorization header,
+// requests without one fall back to the access token of JWTMiddleware. Either way the request works
+// in the mode of the key, test keys only see and make test payments.
+func AuthMiddleware() gin.HandlerFunc {
+	jwtMiddleware := JWTMiddleware()
+	return func(c *gin.Context) {
+		authorization := c.GetHeader("Authorization")
+		if authorization == "" {
+			jwtMiddleware(c)
+			return
+		}
+
+		secretKey := strings.TrimSpace(strings.TrimPrefix(authorization, "Bearer "))
+		apiKey, err := services.VerifySecretKey(secretKey)
+		if err != nil {
+			models.SendErrorResponse(c, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		c.Set("apiKeyMode", apiKey.Mode)
+		c.Set("merchantId", apiKey.MerchantID)
+
+		c.Next()
+	}
+}
+
+// LiveModeMiddleware rejects requests made with test keys, must run after AuthMiddleware. Balances and
+// settlements only exist for live payments.
+func LiveModeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("apiKeyMode") != db.APIKeyModeLive {
+			models.SendErrorResponse(c, http.StatusForbidden, "only available with a live key")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// AdminMiddleware guards the admin API with the key configured in ADMIN_API_KEY
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Admin-Key")
+		if subtle.ConstantTimeCompare([]byte(key), []byte(services.Config.AdminAPIKey)) != 1 {
+			models.SendErrorResponse(c, http.StatusUnauthorized, "not valid admin key")
+			return
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,82 @@
+package middlewares
+
+import (
+	"bytes"
+	"checkout-task/models"
+	"checkout-task/services"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/gin-gonic/gin"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// responseRecorder keeps a copy of everything written to the response
+type responseRecorder struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// IdempotencyMiddleware replays the stored response when a request is retried with the same
+// Idempotency-Key header. Must run after the authentication middleware, keys are scoped per merchant and mode.
+func IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, "cannot read request body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewBuffer(body))
+
+		hash := sha256.New()
+		hash.Write([]byte(c.Request.Method + " " + c.Request.URL.Path + "\n"))
+		hash.Write(body)
+
+		scope := strconv.FormatInt(c.GetInt64("merchantId"), 10) + ":" + c.GetString("apiKeyMode")
+		record, err := services.BeginIdempotentRequest(scope, key, hex.EncodeToString(hash.Sum(nil)))
+		if err == services.ErrIdempotencyKeyReused || err == services.ErrIdempotencyKeyInProgress {
+			models.SendErrorResponse(c, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			models.SendErrorResponse(c, http.StatusInternalServerError, "cannot check idempotency key")
+			return
+		}
+
+		// Replay the stored response of the original request
+		if record.ResponseCode != 0 {
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(record.ResponseCode, "application/json; charset=utf-8", record.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = recorder
+
+		// A panicking handler releases the key like any other server error before recovery responds
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				services.CompleteIdempotentRequest(record, http.StatusInternalServerError, nil)
+				panic(recovered)
+			}
+		}()
+		c.Next()
+
+		services.CompleteIdempotentRequest(record, recorder.Status(), recorder.body.Bytes())
+	}
+}
@@ -0,0 +1,58 @@
+package validators
+
+import (
+	"checkout-task/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"io"
+	"net/http"
+)
+
+func MerchantValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var merchantRequest models.MerchantRequest
+		_ = c.ShouldBindBodyWith(&merchantRequest, binding.JSON)
+
+		if err := merchantRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func APIKeyValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var apiKeyRequest models.APIKeyRequest
+		_ = c.ShouldBindBodyWith(&apiKeyRequest, binding.JSON)
+
+		if err := apiKeyRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func RollAPIKeyValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		// An empty body revokes the rolled key straight away
+		var rollRequest models.RollAPIKeyRequest
+		if err := c.ShouldBindBodyWith(&rollRequest, binding.JSON); err != nil && err != io.EOF {
+			models.SendErrorResponse(c, http.StatusBadRequest, models.BindErrorMessage(err))
+			return
+		}
+
+		if err := rollRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
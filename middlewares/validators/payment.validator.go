@@ -0,0 +1,82 @@
+package validators
+
+import (
+	"checkout-task/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"io"
+	"net/http"
+)
+
+func RefundValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		// An empty body refunds everything that is left
+		var refundRequest models.RefundRequest
+		if err := c.ShouldBindBodyWith(&refundRequest, binding.JSON); err != nil && err != io.EOF {
+			models.SendErrorResponse(c, http.StatusBadRequest, models.BindErrorMessage(err))
+			return
+		}
+
+		if err := refundRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func CardValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var cardRequest models.CardRequest
+		if err := c.ShouldBindBodyWith(&cardRequest, binding.JSON); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, models.BindErrorMessage(err))
+			return
+		}
+
+		if err := models.ValidateCardRequest(cardRequest); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func PaymentListValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var listRequest models.PaymentListRequest
+		if err := c.ShouldBindQuery(&listRequest); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, "invalid query parameters, amounts must be whole numbers and dates RFC 3339")
+			return
+		}
+
+		if err := listRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func ExportValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var exportRequest models.ExportRequest
+		if err := c.ShouldBindBodyWith(&exportRequest, binding.JSON); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, models.BindErrorMessage(err))
+			return
+		}
+
+		if err := exportRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
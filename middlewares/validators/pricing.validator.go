@@ -0,0 +1,44 @@
+package validators
+
+import (
+	"checkout-task/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"net/http"
+)
+
+func PricingPlanValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var planRequest models.PricingPlanRequest
+		if err := c.ShouldBindBodyWith(&planRequest, binding.JSON); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, models.BindErrorMessage(err))
+			return
+		}
+
+		if err := planRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func PricingRulesValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var rulesRequest models.PricingRulesRequest
+		if err := c.ShouldBindBodyWith(&rulesRequest, binding.JSON); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, models.BindErrorMessage(err))
+			return
+		}
+
+		if err := rulesRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,44 @@
+package validators
+
+import (
+	"checkout-task/models"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"net/http"
+)
+
+func WebhookEndpointValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var endpointRequest models.WebhookEndpointRequest
+		if err := c.ShouldBindBodyWith(&endpointRequest, binding.JSON); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, models.BindErrorMessage(err))
+			return
+		}
+
+		if err := endpointRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func WebhookEndpointUpdateValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		var updateRequest models.WebhookEndpointUpdateRequest
+		if err := c.ShouldBindBodyWith(&updateRequest, binding.JSON); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, models.BindErrorMessage(err))
+			return
+		}
+
+		if err := updateRequest.Validate(); err != nil {
+			models.SendErrorResponse(c, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,9 @@
+-- Payments and tokens created before merchant accounts belong to a single legacy merchant.
+-- Run once after upgrading; tokens issued before the upgrade are not bound to a merchant and must be regenerated.
+INSERT INTO merchants (merchant_id, name, email, created_at)
+VALUES ('00000000-0000-0000-0000-000000000001', 'Legacy merchant', 'legacy@payment-gateway.local', NOW())
+ON CONFLICT DO NOTHING;
+
+UPDATE payments
+SET merchant_id = (SELECT id FROM merchants WHERE email = 'legacy@payment-gateway.local')
+WHERE merchant_id = 0;
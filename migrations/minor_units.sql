@@ -0,0 +1,23 @@
+-- Convert stored amounts from decimal major units to integer minor units.
+-- Cards carry no currency and are assumed to hold two decimal places.
+CREATE OR REPLACE FUNCTION pg_temp.currency_factor(currency TEXT) RETURNS NUMERIC AS $$
+    SELECT CASE
+        WHEN currency IN ('CLP', 'ISK', 'JPY', 'KRW', 'VND') THEN 1
+        WHEN currency IN ('BHD', 'JOD', 'KWD', 'OMR', 'TND') THEN 1000
+        ELSE 100
+    END;
+$$ LANGUAGE SQL IMMUTABLE;
+
+ALTER TABLE payments
+    ALTER COLUMN amount TYPE BIGINT USING ROUND(amount::NUMERIC * pg_temp.currency_factor(currency)),
+    ALTER COLUMN refunded_amount TYPE BIGINT USING ROUND(refunded_amount::NUMERIC * pg_temp.currency_factor(currency));
+
+ALTER TABLE refunds
+    ALTER COLUMN amount TYPE BIGINT USING ROUND(amount::NUMERIC * pg_temp.currency_factor(currency));
+
+ALTER TABLE frauds
+    ALTER COLUMN amount TYPE BIGINT USING ROUND(amount::NUMERIC * pg_temp.currency_factor(currency));
+
+ALTER TABLE cards
+    ALTER COLUMN balance TYPE BIGINT USING ROUND(balance::NUMERIC * 100),
+    ALTER COLUMN held_balance TYPE BIGINT USING ROUND(held_balance::NUMERIC * 100);
@@ -0,0 +1,9 @@
+-- Move payments stored before the payment state machine onto its statuses.
+-- Approved payments were debited straight away, the ones left In-Progress had been declined.
+UPDATE payments SET status = 'Captured' WHERE status = 'Success';
+UPDATE payments SET status = 'Declined' WHERE status = 'In-Progress';
+
+INSERT INTO payment_events (payment_id, from_status, to_status, reason, created_at)
+SELECT id, '', status, 'migrated from legacy status', NOW()
+FROM payments
+WHERE id NOT IN (SELECT payment_id FROM payment_events);
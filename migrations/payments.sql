@@ -1,14 +1,15 @@
+-- Balances and amounts are in minor units, e.g. cents
 INSERT INTO cards (card_number, balance) VALUES
- ('1234567890123456', 100),
- ('2345678901234567', 200),
- ('3456789012345670', 300),
- ('4567890123456789', 400),
- ('5678901234567890', 500);
+ ('4111111111111111', 10000),
+ ('5555555555554444', 20000),
+ ('378282246310005', 30000),
+ ('6011111111111117', 40000),
+ ('3530111333300000', 50000);
 
 
 INSERT INTO frauds (card_number, amount, currency) VALUES
-('1234567890123456', 0, 'USD'),
-('2345678901234567', 0, 'EUR'),
-('3456789012345670', 0, 'GBP'),
-('4567890123456789', 125, 'CAD'),
-('5678901234567890', 150, 'AUD');
\ No newline at end of file
+('4111111111111111', 0, 'USD'),
+('5555555555554444', 0, 'EUR'),
+('378282246310005', 0, 'GBP'),
+('6011111111111117', 12500, 'CAD'),
+('3530111333300000', 15000, 'AUD');
\ No newline at end of file
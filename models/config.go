@@ -20,6 +20,47 @@ type EnvConfig struct {
 	JWTAccessExpirationMinutes int    `mapstructure:"JWT_ACCESS_EXPIRATION_MINUTES"`
 	JWTRefreshExpirationDays   int    `mapstructure:"JWT_REFRESH_EXPIRATION_DAYS"`
 	Mode                       string `mapstructure:"MODE"`
+	AdminAPIKey                string `mapstructure:"ADMIN_API_KEY"`
+	IdempotencyKeyTTLHours     int    `mapstructure:"IDEMPOTENCY_KEY_TTL_HOURS"`
+	BankURL                    string `mapstructure:"BANK_URL"`
+	TestBankURL                string `mapstructure:"TEST_BANK_URL"`
+	BankTimeoutMillis          int    `mapstructure:"BANK_TIMEOUT_MS"`
+	VaultKeys                  string `mapstructure:"VAULT_KEYS"`
+	VaultActiveKeyID           string `mapstructure:"VAULT_ACTIVE_KEY_ID"`
+	VaultFingerprintKey        string `mapstructure:"VAULT_FINGERPRINT_KEY"`
+	BINFile                    string `mapstructure:"BIN_FILE"`
+	ExportDir                  string `mapstructure:"EXPORT_DIR"`
+}
+
+// BankSimulatorConfig configures cmd/bank-simulator, it shares the database settings of the gateway
+type BankSimulatorConfig struct {
+	DBHost         string `mapstructure:"POSTGRES_HOST"`
+	DBUserName     string `mapstructure:"POSTGRES_USER"`
+	DBUserPassword string `mapstructure:"POSTGRES_PASSWORD"`
+	DBName         string `mapstructure:"POSTGRES_DB"`
+	DBPort         string `mapstructure:"POSTGRES_PORT"`
+	ServerHost     string `mapstructure:"BANK_SIMULATOR_HOST"`
+	ServerPort     string `mapstructure:"BANK_SIMULATOR_PORT"`
+	ScenariosFile  string `mapstructure:"BANK_SCENARIOS_FILE"`
+	Mode           string `mapstructure:"MODE"`
+	// The vault keys protect the card numbers in cards, frauds and bank_transactions
+	VaultKeys           string `mapstructure:"VAULT_KEYS"`
+	VaultActiveKeyID    string `mapstructure:"VAULT_ACTIVE_KEY_ID"`
+	VaultFingerprintKey string `mapstructure:"VAULT_FINGERPRINT_KEY"`
+}
+
+func (config *BankSimulatorConfig) Validate() error {
+	return validation.ValidateStruct(config,
+		validation.Field(&config.DBPort, is.Port),
+		validation.Field(&config.DBHost, validation.Required),
+		validation.Field(&config.DBUserPassword, validation.Required),
+		validation.Field(&config.DBName, validation.Required),
+		validation.Field(&config.ServerPort, is.Port),
+		validation.Field(&config.Mode, validation.In("debug", "release")),
+		validation.Field(&config.VaultKeys, validation.Required),
+		validation.Field(&config.VaultActiveKeyID, validation.Required),
+		validation.Field(&config.VaultFingerprintKey, validation.Required, is.Base64),
+	)
 }
 
 func (config *EnvConfig) Validate() error {
@@ -36,5 +77,15 @@ func (config *EnvConfig) Validate() error {
 		validation.Field(&config.JWTRefreshExpirationDays, validation.Required),
 
 		validation.Field(&config.Mode, validation.In("debug", "release")),
+		validation.Field(&config.AdminAPIKey, validation.Required, validation.Length(16, 0)),
+		validation.Field(&config.IdempotencyKeyTTLHours, validation.Required, validation.Min(1)),
+		validation.Field(&config.BankURL, validation.Required, is.RequestURL),
+		validation.Field(&config.TestBankURL, validation.Required, is.RequestURL),
+		validation.Field(&config.BankTimeoutMillis, validation.Required, validation.Min(1)),
+		validation.Field(&config.BINFile, validation.Required),
+		validation.Field(&config.ExportDir, validation.Required),
+		validation.Field(&config.VaultKeys, validation.Required),
+		validation.Field(&config.VaultActiveKeyID, validation.Required),
+		validation.Field(&config.VaultFingerprintKey, validation.Required, is.Base64),
 	)
 }
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// Modes of API keys, payments, cards, webhook endpoints and exports. Test keys only see test data and
+// their payments only reach the bank simulator.
+const (
+	APIKeyModeTest = "test"
+	APIKeyModeLive = "live"
+
+	PublishableKeyPrefix = "pk"
+	SecretKeyPrefix      = "sk"
+)
+
+// APIKey represents a row in the "api_keys" table, a publishable and secret key pair of a merchant.
+// The secret key is only known as its SHA-256 hash, SecretLast4 helps merchants tell keys apart.
+type APIKey struct {
+	ID             int64      `json:"-" gorm:"column:id;primary_key"`
+	KeyID          string     `json:"key_id" gorm:"column:key_id;not null;unique_index"`
+	MerchantID     int64      `json:"-" gorm:"column:merchant_id;not null;index"`
+	Mode           string     `json:"mode" gorm:"column:mode;not null"`
+	PublishableKey string     `json:"publishable_key" gorm:"column:publishable_key;not null;unique_index"`
+	SecretHash     string     `json:"-" gorm:"column:secret_hash;not null;unique_index"`
+	SecretLast4    string     `json:"secret_last4" gorm:"column:secret_last4;not null"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" gorm:"column:expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at,omitempty" gorm:"column:revoked_at"`
+	LastUsedAt     *time.Time `json:"last_used_at,omitempty" gorm:"column:last_used_at"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"column:created_at"`
+}
+
+// Active reports whether the key pair can still be used to authenticate
+func (key APIKey) Active(now time.Time) bool {
+	if key.RevokedAt != nil {
+		return false
+	}
+	return key.ExpiresAt == nil || now.Before(*key.ExpiresAt)
+}
+
+// TableName specifies the table name for the APIKey struct
+func (APIKey) TableName() string {
+	return "api_keys"
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// BankTransaction represents a row in the "bank_transactions" table kept by the bank simulator.
+// Authorizations carry the lifecycle of the hold, captures, voids and refunds point back at
+// their authorization through AuthorizationReference and are found by the gateway's OperationReference.
+// CardNumber is encrypted.
+type BankTransaction struct {
+	ID                     int64     `json:"id" gorm:"column:id;primary_key"`
+	Reference              string    `json:"reference" gorm:"column:reference;not null;unique_index"`
+	Type                   string    `json:"type" gorm:"column:type;not null"`
+	AuthorizationReference string    `json:"authorization_reference" gorm:"column:authorization_reference;index"`
+	OperationReference     string    `json:"operation_reference" gorm:"column:operation_reference;index"`
+	MerchantReference      string    `json:"merchant_reference" gorm:"column:merchant_reference;index"`
+	StatementDescriptor    string    `json:"statement_descriptor" gorm:"column:statement_descriptor"`
+	CardNumber             string    `json:"-" gorm:"column:card_number;not null"`
+	CardFingerprint        string    `json:"-" gorm:"column:card_fingerprint;index"`
+	Amount                 int64     `json:"amount" gorm:"column:amount;not null"`
+	CapturedAmount         int64     `json:"captured_amount" gorm:"column:captured_amount;not null;default:0"`
+	RefundedAmount         int64     `json:"refunded_amount" gorm:"column:refunded_amount;not null;default:0"`
+	Currency               string    `json:"currency" gorm:"column:currency;not null"`
+	Status                 string    `json:"status" gorm:"column:status;not null"`
+	DeclineCode            string    `json:"decline_code" gorm:"column:decline_code"`
+	DeclineReason          string    `json:"decline_reason" gorm:"column:decline_reason"`
+	CreatedAt              time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the BankTransaction struct
+func (BankTransaction) TableName() string {
+	return "bank_transactions"
+}
+
+// BankTransfer represents a row in the "bank_transfers" table kept by the bank simulator, a payout of the
+// gateway to a merchant's bank account. PayoutReference is the gateway's payout, a payout sent again
+// returns the approved transfer made the first time.
+type BankTransfer struct {
+	ID              int64     `json:"id" gorm:"column:id;primary_key"`
+	Reference       string    `json:"reference" gorm:"column:reference;not null;unique_index"`
+	PayoutReference string    `json:"payout_reference" gorm:"column:payout_reference;not null;index"`
+	Beneficiary     string    `json:"beneficiary" gorm:"column:beneficiary;not null"`
+	Amount          int64     `json:"amount" gorm:"column:amount;not null"`
+	Currency        string    `json:"currency" gorm:"column:currency;not null"`
+	Status          string    `json:"status" gorm:"column:status;not null"`
+	DeclineCode     string    `json:"decline_code" gorm:"column:decline_code"`
+	DeclineReason   string    `json:"decline_reason" gorm:"column:decline_reason"`
+	CreatedAt       time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the BankTransfer struct
+func (BankTransfer) TableName() string {
+	return "bank_transfers"
+}
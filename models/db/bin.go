@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Card types of a BIN, the funding source of the card
+const (
+	CardTypeCredit  = "credit"
+	CardTypeDebit   = "debit"
+	CardTypePrepaid = "prepaid"
+)
+
+// BIN represents a row in the "bins" table, the issuer attributes of the cards starting with a
+// 6 or 8 digit bank identification number. The table is loaded from BIN_FILE.
+type BIN struct {
+	ID         int64     `json:"-" gorm:"column:id;primary_key"`
+	BIN        string    `json:"bin" gorm:"column:bin;not null;unique_index"`
+	Brand      string    `json:"brand" gorm:"column:brand;not null"`
+	Issuer     string    `json:"issuer" gorm:"column:issuer"`
+	Country    string    `json:"country" gorm:"column:country;not null"`
+	CardType   string    `json:"card_type" gorm:"column:card_type;not null"`
+	Commercial bool      `json:"commercial" gorm:"column:commercial;not null;default:false"`
+	UpdatedAt  time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+// TableName specifies the table name for the BIN struct
+func (BIN) TableName() string {
+	return "bins"
+}
@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Export statuses, exports are written by services.ProcessExports
+const (
+	ExportStatusPending   = "pending"
+	ExportStatusRunning   = "running"
+	ExportStatusCompleted = "completed"
+	ExportStatusFailed    = "failed"
+)
+
+// Export file formats
+const (
+	ExportFormatCSV    = "csv"
+	ExportFormatNDJSON = "ndjson"
+)
+
+// Export represents a row in the "exports" table, a file of the merchant's payments matching Filters,
+// the JSON of a models.PaymentListRequest, made in Mode. The file is kept on local disk under EXPORT_DIR.
+type Export struct {
+	ID          int64      `json:"-" gorm:"column:id;primary_key"`
+	ExportID    string     `json:"export_id" gorm:"column:export_id;not null;unique_index"`
+	MerchantID  int64      `json:"-" gorm:"column:merchant_id;not null;index"`
+	Mode        string     `json:"mode" gorm:"column:mode;not null;default:'live'"`
+	Format      string     `json:"format" gorm:"column:format;not null"`
+	Filters     string     `json:"-" gorm:"column:filters;type:text;not null"`
+	Status      string     `json:"status" gorm:"column:status;not null;index"`
+	RowCount    int64      `json:"row_count" gorm:"column:row_count;not null;default:0"`
+	FilePath    string     `json:"-" gorm:"column:file_path"`
+	Error       string     `json:"error,omitempty" gorm:"column:error"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"column:created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" gorm:"column:completed_at"`
+}
+
+// TableName specifies the table name for the Export struct
+func (Export) TableName() string {
+	return "exports"
+}
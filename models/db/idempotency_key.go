@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// IdempotencyKey represents a row in the "idempotency_keys" table.
+// ResponseCode stays zero while the first request with the key is still being processed.
+type IdempotencyKey struct {
+	ID           int64     `json:"id" gorm:"column:id;primary_key"`
+	Scope        string    `json:"scope" gorm:"column:scope;not null;unique_index:idx_idempotency_keys_scope_key"`
+	Key          string    `json:"key" gorm:"column:idempotency_key;not null;unique_index:idx_idempotency_keys_scope_key"`
+	RequestHash  string    `json:"request_hash" gorm:"column:request_hash;not null"`
+	ResponseCode int       `json:"response_code" gorm:"column:response_code;not null;default:0"`
+	ResponseBody []byte    `json:"-" gorm:"column:response_body"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"column:expires_at;not null;index"`
+	CreatedAt    time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the IdempotencyKey struct
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
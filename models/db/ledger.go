@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// Ledger account types, every merchant has one account of each type per currency. Merchant pending holds
+// captured funds not settled yet, merchant available the settled funds waiting for a payout, gateway fees
+// the fees charged to the merchant and refunds the refunds to pay back through the acquirer. Acquirer
+// clearing is the money the acquirer owes the gateway for the merchant's payments.
+const (
+	AccountMerchantPending   = "merchant_pending"
+	AccountMerchantAvailable = "merchant_available"
+	AccountGatewayFees       = "gateway_fees"
+	AccountRefunds           = "refunds"
+	AccountAcquirerClearing  = "acquirer_clearing"
+)
+
+// Ledger transaction kinds, reversals give the funds of a failed payout back to the merchant
+const (
+	LedgerCapture    = "capture"
+	LedgerRefund     = "refund"
+	LedgerFee        = "fee"
+	LedgerSettlement = "settlement"
+	LedgerPayout     = "payout"
+	LedgerReversal   = "payout_reversal"
+)
+
+// Ledger entry directions
+const (
+	Debit  = "debit"
+	Credit = "credit"
+)
+
+// LedgerAccount represents a row in the "ledger_accounts" table
+type LedgerAccount struct {
+	ID         int64     `json:"-" gorm:"column:id;primary_key"`
+	MerchantID int64     `json:"-" gorm:"column:merchant_id;not null;unique_index:idx_ledger_accounts_key"`
+	Type       string    `json:"type" gorm:"column:type;not null;unique_index:idx_ledger_accounts_key"`
+	Currency   string    `json:"currency" gorm:"column:currency;not null;unique_index:idx_ledger_accounts_key"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the LedgerAccount struct
+func (LedgerAccount) TableName() string {
+	return "ledger_accounts"
+}
+
+// LedgerTransaction represents a row in the "ledger_transactions" table, a journal entry made of balanced
+// ledger entries. Source is what posted it, such as the payment or batch, and is unique per kind so nothing is
+// posted twice. Reference is the payment, refund or payout the transaction is about.
+type LedgerTransaction struct {
+	ID            int64     `json:"-" gorm:"column:id;primary_key"`
+	TransactionID string    `json:"transaction_id" gorm:"column:transaction_id;not null;unique_index"`
+	MerchantID    int64     `json:"-" gorm:"column:merchant_id;not null;index"`
+	Kind          string    `json:"kind" gorm:"column:kind;not null;unique_index:idx_ledger_transactions_source"`
+	Source        string    `json:"source" gorm:"column:source;not null;unique_index:idx_ledger_transactions_source"`
+	Reference     string    `json:"reference" gorm:"column:reference;not null;index"`
+	Currency      string    `json:"currency" gorm:"column:currency;not null"`
+	CreatedAt     time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the LedgerTransaction struct
+func (LedgerTransaction) TableName() string {
+	return "ledger_transactions"
+}
+
+// LedgerEntry represents a row in the "ledger_entries" table, a debit or credit of an account in minor units
+type LedgerEntry struct {
+	ID            int64     `json:"-" gorm:"column:id;primary_key"`
+	TransactionID int64     `json:"-" gorm:"column:transaction_id;not null;index"`
+	AccountID     int64     `json:"-" gorm:"column:account_id;not null;index"`
+	Direction     string    `json:"direction" gorm:"column:direction;not null"`
+	Amount        int64     `json:"amount" gorm:"column:amount;not null"`
+	CreatedAt     time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the LedgerEntry struct
+func (LedgerEntry) TableName() string {
+	return "ledger_entries"
+}
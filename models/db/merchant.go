@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Merchant represents a row in the "merchants" table. Tokens, API credentials and payments belong to one merchant.
+// PricingPlanID is the plan the merchant is charged by, 0 for the default plan.
+type Merchant struct {
+	ID            int64     `json:"-" gorm:"column:id;primary_key"`
+	MerchantID    string    `json:"merchant_id" gorm:"column:merchant_id;not null;unique_index"`
+	Name          string    `json:"name" gorm:"column:name;not null"`
+	Email         string    `json:"email" gorm:"column:email;not null;unique_index"`
+	PricingPlanID int64     `json:"-" gorm:"column:pricing_plan_id;not null;default:0"`
+	CreatedAt     time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the Merchant struct
+func (Merchant) TableName() string {
+	return "merchants"
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// Outbox event types of payment changes that merchants are not sent webhooks for
+const (
+	EventPaymentCreated           = "payment.created"
+	EventPaymentPartiallyRefunded = "payment.partially_refunded"
+	EventPaymentRefunded          = "payment.refunded"
+)
+
+// OutboxEvent represents a row in the "outbox_events" table. Every payment change writes one in the same
+// transaction, and the relay publishes it to the consumers afterwards. AggregateID is the payment the event
+// belongs to, events of a payment are published in order. Payload is the JSON data of the event.
+type OutboxEvent struct {
+	ID            int64      `json:"-" gorm:"column:id;primary_key"`
+	EventID       string     `json:"event_id" gorm:"column:event_id;not null;unique_index"`
+	MerchantID    int64      `json:"-" gorm:"column:merchant_id;not null;index"`
+	Type          string     `json:"type" gorm:"column:type;not null"`
+	AggregateID   string     `json:"aggregate_id" gorm:"column:aggregate_id;not null;index"`
+	Payload       string     `json:"-" gorm:"column:payload;type:text;not null"`
+	Attempts      int        `json:"attempts" gorm:"column:attempts;not null;default:0"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"column:next_attempt_at;not null;index:idx_outbox_events_due"`
+	LastError     string     `json:"last_error,omitempty" gorm:"column:last_error"`
+	PublishedAt   *time.Time `json:"published_at,omitempty" gorm:"column:published_at;index:idx_outbox_events_due"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the OutboxEvent struct
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+// OutboxReceipt represents a row in the "outbox_receipts" table. It is written in the same transaction as
+// the work of the consumer, so an event retried after a failure is not handled twice by consumers that
+// already took it.
+type OutboxReceipt struct {
+	ID        int64     `json:"-" gorm:"column:id;primary_key"`
+	EventID   int64     `json:"-" gorm:"column:event_id;not null;unique_index:idx_outbox_receipts_event_consumer"`
+	Consumer  string    `json:"consumer" gorm:"column:consumer;not null;unique_index:idx_outbox_receipts_event_consumer"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the OutboxReceipt struct
+func (OutboxReceipt) TableName() string {
+	return "outbox_receipts"
+}
+
+// PaymentStat represents a row in the "payment_stats" table, kept by the analytics consumer of the outbox:
+// how many events of a type a merchant had per day and currency, and their amount in minor units
+type PaymentStat struct {
+	ID         int64     `json:"-" gorm:"column:id;primary_key"`
+	MerchantID int64     `json:"-" gorm:"column:merchant_id;not null;unique_index:idx_payment_stats_key"`
+	Day        time.Time `json:"day" gorm:"column:day;type:date;not null;unique_index:idx_payment_stats_key"`
+	Currency   string    `json:"currency" gorm:"column:currency;not null;unique_index:idx_payment_stats_key"`
+	EventType  string    `json:"event_type" gorm:"column:event_type;not null;unique_index:idx_payment_stats_key"`
+	Count      int64     `json:"count" gorm:"column:count;not null;default:0"`
+	Amount     int64     `json:"amount" gorm:"column:amount;not null;default:0"`
+}
+
+// TableName specifies the table name for the PaymentStat struct
+func (PaymentStat) TableName() string {
+	return "payment_stats"
+}
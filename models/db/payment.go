@@ -2,29 +2,66 @@ package models
 
 import (
 	"gorm.io/gorm"
+	"time"
 )
 
+// Payment represents a row in the "payments" table. Cards are referenced by their vault token, CardNumber
+// only holds the encrypted card number of payments made before the vault. The CVV is never stored.
+// Payments are listed per merchant by creation time, hence the index on both.
+// Fee is charged to the merchant when the payment is captured, FeeRate (basis points), FeeVariable, FeeFixed,
+// FeePlan and CardRegion keep how it was computed.
+// Captured payments are settled once, in the batch of SettlementBatchID.
+// Mode is the mode of the API key the payment was made with, test payments are never settled.
 type Payment struct {
-	ID         int64   `json:"id" gorm:"column:id;primary_key"`
-	PaymentID  string  `json:"payment_id" gorm:"column:payment_id;not null"`
-	CardNumber string  `gorm:"column:card_number;not null"`
-	ExpMonth   int     `gorm:"column:exp_month;not null"`
-	ExpYear    int     `gorm:"column:exp_year;not null"`
-	Amount     float64 `gorm:"column:amount;not null"`
-	Currency   string  `gorm:"column:currency;not null"`
-	CVV        int     `gorm:"column:cvv;not null"`
-	Status     string  `json:"status"`
+	ID          int64  `json:"id" gorm:"column:id;primary_key"`
+	MerchantID  int64  `json:"merchant_id" gorm:"column:merchant_id;not null;default:0;index:idx_payments_merchant_created"`
+	Mode        string `json:"mode" gorm:"column:mode;not null;default:'live'"`
+	PaymentID   string `json:"payment_id" gorm:"column:payment_id;not null"`
+	Reference   string `gorm:"column:reference;index"`
+	Description string `gorm:"column:description;type:text"`
+	// Metadata holds the JSON object of the merchant's key/value pairs
+	Metadata            string     `gorm:"column:metadata;type:text"`
+	StatementDescriptor string     `gorm:"column:statement_descriptor"`
+	CardNumber          string     `gorm:"column:card_number;not null"`
+	CardToken           string     `gorm:"column:card_token;index"`
+	CardLast4           string     `gorm:"column:card_last4"`
+	Brand               string     `gorm:"column:brand;index"`
+	CardBIN             string     `gorm:"column:card_bin"`
+	IssuerCountry       string     `gorm:"column:issuer_country"`
+	CardType            string     `gorm:"column:card_type"`
+	Commercial          bool       `gorm:"column:commercial;not null;default:false"`
+	ExpMonth            int        `gorm:"column:exp_month;not null"`
+	ExpYear             int        `gorm:"column:exp_year;not null"`
+	Amount              int64      `gorm:"column:amount;not null"`
+	RefundedAmount      int64      `gorm:"column:refunded_amount;not null;default:0"`
+	Currency            string     `gorm:"column:currency;not null"`
+	Fee                 int64      `gorm:"column:fee;not null;default:0"`
+	FeeRate             int64      `gorm:"column:fee_rate_bps;not null;default:0"`
+	FeeVariable         int64      `gorm:"column:fee_variable;not null;default:0"`
+	FeeFixed            int64      `gorm:"column:fee_fixed;not null;default:0"`
+	FeePlan             string     `gorm:"column:fee_plan"`
+	CardRegion          string     `gorm:"column:card_region"`
+	CapturedAt          *time.Time `gorm:"column:captured_at"`
+	SettlementBatchID   int64      `gorm:"column:settlement_batch_id;not null;default:0;index"`
+	Status              string     `json:"status"`
+	AcquirerReference   string     `gorm:"column:acquirer_reference;index"`
+	DeclineCode         string     `gorm:"column:decline_code"`
+	DeclineCategory     string     `gorm:"column:decline_category"`
+	CreatedAt           time.Time  `gorm:"column:created_at;index:idx_payments_merchant_created"`
+	UpdatedAt           time.Time  `gorm:"column:updated_at;index"`
 }
 
 func (Payment) TableName() string {
 	return "payments"
 }
 
-// Card represents a row in the "cards" table
+// Card represents a row in the "cards" table. CardNumber is encrypted, cards are looked up by CardFingerprint.
 type Card struct {
 	gorm.Model
-	CardNumber string  `gorm:"column:card_number;not null"`
-	Balance    float64 `gorm:"column:balance;not null"`
+	CardNumber      string `gorm:"column:card_number;not null"`
+	CardFingerprint string `gorm:"column:card_fingerprint;index"`
+	Balance         int64  `gorm:"column:balance;not null"`
+	HeldBalance     int64  `gorm:"column:held_balance;not null;default:0"`
 }
 
 // TableName specifies the table name for the Card struct
@@ -32,12 +69,13 @@ func (Card) TableName() string {
 	return "cards"
 }
 
-// Fraud represents a row in the "frauds" table
+// Fraud represents a row in the "frauds" table. CardNumber is encrypted, frauds are looked up by CardFingerprint.
 type Fraud struct {
 	gorm.Model
-	CardNumber string  `gorm:"column:card_number;not null"`
-	Amount     float64 `gorm:"column:amount;not null"`
-	Currency   string  `gorm:"column:currency;not null"`
+	CardNumber      string `gorm:"column:card_number;not null"`
+	CardFingerprint string `gorm:"column:card_fingerprint;index"`
+	Amount          int64  `gorm:"column:amount;not null"`
+	Currency        string `gorm:"column:currency;not null"`
 }
 
 // TableName specifies the table name for the Fraud struct
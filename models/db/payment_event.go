@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// PaymentEvent represents a row in the "payment_events" table, one per status transition of a payment
+type PaymentEvent struct {
+	ID         int64     `json:"id" gorm:"column:id;primary_key"`
+	PaymentID  int64     `json:"-" gorm:"column:payment_id;not null;index"`
+	FromStatus string    `json:"from_status" gorm:"column:from_status"`
+	ToStatus   string    `json:"to_status" gorm:"column:to_status;not null"`
+	Reason     string    `json:"reason" gorm:"column:reason"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the PaymentEvent struct
+func (PaymentEvent) TableName() string {
+	return "payment_events"
+}
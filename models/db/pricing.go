@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// Card regions fees can vary by, from the issuing country of the card
+const (
+	CardRegionEEA           = "eea"
+	CardRegionUK            = "uk"
+	CardRegionNorthAmerica  = "north_america"
+	CardRegionInternational = "international"
+)
+
+// PricingPlan represents a row in the "pricing_plans" table. Merchants without a plan of their own are
+// charged by the default plan.
+type PricingPlan struct {
+	ID        int64     `json:"-" gorm:"column:id;primary_key"`
+	PlanID    string    `json:"plan_id" gorm:"column:plan_id;not null;unique_index"`
+	Name      string    `json:"name" gorm:"column:name;not null"`
+	IsDefault bool      `json:"is_default" gorm:"column:is_default;not null;default:false"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the PricingPlan struct
+func (PricingPlan) TableName() string {
+	return "pricing_plans"
+}
+
+// PricingRule represents a row in the "pricing_rules" table, the fee of the payments it matches: a rate in
+// basis points of the amount plus a fixed fee in minor units of the payment currency. Empty brand, card
+// region and currency match any payment. Rules belong to a plan, or to a merchant when they override the
+// plan of that merchant.
+type PricingRule struct {
+	ID           int64     `json:"-" gorm:"column:id;primary_key"`
+	PlanID       int64     `json:"-" gorm:"column:plan_id;not null;default:0;index"`
+	MerchantID   int64     `json:"-" gorm:"column:merchant_id;not null;default:0;index"`
+	Brand        string    `json:"brand" gorm:"column:brand;not null;default:''"`
+	CardRegion   string    `json:"card_region" gorm:"column:card_region;not null;default:''"`
+	Currency     string    `json:"currency" gorm:"column:currency;not null;default:''"`
+	RateBasisPts int64     `json:"rate_bps" gorm:"column:rate_bps;not null;default:0"`
+	FixedFee     int64     `json:"fixed_fee" gorm:"column:fixed_fee;not null;default:0"`
+	CreatedAt    time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the PricingRule struct
+func (PricingRule) TableName() string {
+	return "pricing_rules"
+}
@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// Reconciliation item statuses. Missing on our side are captures the acquirer settled that match no payment,
+// missing at the bank are payments captured on the day the acquirer did not settle.
+const (
+	ReconciliationMatched          = "matched"
+	ReconciliationMissingOnOurSide = "missing_on_our_side"
+	ReconciliationMissingAtBank    = "missing_at_bank"
+	ReconciliationAmountMismatch   = "amount_mismatch"
+)
+
+// ReconciliationRun represents a row in the "reconciliation_runs" table, the check of an acquirer settlement
+// file against the payments captured on its settlement date
+type ReconciliationRun struct {
+	ID               int64     `json:"-" gorm:"column:id;primary_key"`
+	RunID            string    `json:"run_id" gorm:"column:run_id;not null;unique_index"`
+	SettlementDate   time.Time `json:"settlement_date" gorm:"column:settlement_date;type:date;not null;index"`
+	FileName         string    `json:"file_name" gorm:"column:file_name"`
+	LineCount        int       `json:"line_count" gorm:"column:line_count;not null;default:0"`
+	Matched          int       `json:"matched" gorm:"column:matched;not null;default:0"`
+	MissingOnOurSide int       `json:"missing_on_our_side" gorm:"column:missing_on_our_side;not null;default:0"`
+	MissingAtBank    int       `json:"missing_at_bank" gorm:"column:missing_at_bank;not null;default:0"`
+	AmountMismatches int       `json:"amount_mismatches" gorm:"column:amount_mismatches;not null;default:0"`
+	CreatedAt        time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the ReconciliationRun struct
+func (ReconciliationRun) TableName() string {
+	return "reconciliation_runs"
+}
+
+// ReconciliationItem represents a row in the "reconciliation_items" table, a line of the settlement file or a
+// payment missing from it. Amounts are in minor units, BankAmount is 0 for payments missing at the bank and
+// PaymentAmount for captures missing on our side.
+type ReconciliationItem struct {
+	ID                int64     `json:"-" gorm:"column:id;primary_key"`
+	RunID             int64     `json:"-" gorm:"column:run_id;not null;index"`
+	Status            string    `json:"status" gorm:"column:status;not null;index"`
+	AcquirerReference string    `json:"acquirer_reference" gorm:"column:acquirer_reference;not null"`
+	BankReference     string    `json:"bank_reference,omitempty" gorm:"column:bank_reference"`
+	PaymentID         string    `json:"payment_id,omitempty" gorm:"column:payment_id"`
+	MerchantID        int64     `json:"-" gorm:"column:merchant_id;not null;default:0"`
+	Currency          string    `json:"currency" gorm:"column:currency"`
+	BankAmount        int64     `json:"bank_amount" gorm:"column:bank_amount;not null;default:0"`
+	PaymentAmount     int64     `json:"payment_amount" gorm:"column:payment_amount;not null;default:0"`
+	CreatedAt         time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the ReconciliationItem struct
+func (ReconciliationItem) TableName() string {
+	return "reconciliation_items"
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Refund represents a row in the "refunds" table, one per refund made against a payment. Succeeded refunds
+// are settled once, in the batch of SettlementBatchID.
+type Refund struct {
+	ID                int64     `json:"id" gorm:"column:id;primary_key"`
+	RefundID          string    `json:"refund_id" gorm:"column:refund_id;not null;unique_index"`
+	PaymentID         int64     `json:"-" gorm:"column:payment_id;not null;index"`
+	Amount            int64     `json:"amount" gorm:"column:amount;not null"`
+	Currency          string    `json:"currency" gorm:"column:currency;not null"`
+	Status            string    `json:"status" gorm:"column:status;not null"`
+	AcquirerReference string    `json:"acquirer_reference" gorm:"column:acquirer_reference"`
+	SettlementBatchID int64     `json:"-" gorm:"column:settlement_batch_id;not null;default:0;index"`
+	CreatedAt         time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the Refund struct
+func (Refund) TableName() string {
+	return "refunds"
+}
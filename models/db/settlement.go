@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// Payout statuses. Pending payouts are sent to the bank until it pays them, payouts the bank keeps
+// declining fail and their amount goes back to the merchant's available funds.
+const (
+	PayoutStatusPending = "pending"
+	PayoutStatusPaid    = "paid"
+	PayoutStatusFailed  = "failed"
+)
+
+// Settlement line types
+const (
+	SettlementLinePayment = "payment"
+	SettlementLineRefund  = "refund"
+)
+
+// SettlementBatch represents a row in the "settlement_batches" table, the payments captured and refunds made
+// by a merchant in a currency before the cutoff of a settlement date and not settled before. Amounts are in
+// minor units, Net is Gross less Refunds and Fees.
+type SettlementBatch struct {
+	ID             int64     `json:"-" gorm:"column:id;primary_key"`
+	BatchID        string    `json:"batch_id" gorm:"column:batch_id;not null;unique_index"`
+	MerchantID     int64     `json:"-" gorm:"column:merchant_id;not null;unique_index:idx_settlement_batches_key"`
+	Currency       string    `json:"currency" gorm:"column:currency;not null;unique_index:idx_settlement_batches_key"`
+	SettlementDate time.Time `json:"settlement_date" gorm:"column:settlement_date;type:date;not null;unique_index:idx_settlement_batches_key"`
+	CutoffAt       time.Time `json:"cutoff_at" gorm:"column:cutoff_at;not null"`
+	PaymentCount   int       `json:"payment_count" gorm:"column:payment_count;not null;default:0"`
+	RefundCount    int       `json:"refund_count" gorm:"column:refund_count;not null;default:0"`
+	Gross          int64     `json:"gross" gorm:"column:gross;not null;default:0"`
+	Refunds        int64     `json:"refunds" gorm:"column:refunds;not null;default:0"`
+	Fees           int64     `json:"fees" gorm:"column:fees;not null;default:0"`
+	Net            int64     `json:"net" gorm:"column:net;not null;default:0"`
+	CreatedAt      time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the SettlementBatch struct
+func (SettlementBatch) TableName() string {
+	return "settlement_batches"
+}
+
+// SettlementLine represents a row in the "settlement_lines" table, a captured payment or a refund settled
+// in a batch. Net is what the line adds to the batch, negative for refunds.
+type SettlementLine struct {
+	ID        int64     `json:"-" gorm:"column:id;primary_key"`
+	BatchID   int64     `json:"-" gorm:"column:batch_id;not null;index"`
+	Type      string    `json:"type" gorm:"column:type;not null"`
+	PaymentID string    `json:"payment_id" gorm:"column:payment_id;not null"`
+	RefundID  string    `json:"refund_id,omitempty" gorm:"column:refund_id"`
+	Amount    int64     `json:"amount" gorm:"column:amount;not null"`
+	Fee       int64     `json:"fee" gorm:"column:fee;not null;default:0"`
+	Net       int64     `json:"net" gorm:"column:net;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the SettlementLine struct
+func (SettlementLine) TableName() string {
+	return "settlement_lines"
+}
+
+// Payout represents a row in the "payouts" table, the transfer of a merchant's available funds to its bank
+// account after a settlement
+type Payout struct {
+	ID            int64      `json:"-" gorm:"column:id;primary_key"`
+	PayoutID      string     `json:"payout_id" gorm:"column:payout_id;not null;unique_index"`
+	MerchantID    int64      `json:"-" gorm:"column:merchant_id;not null;index"`
+	BatchID       int64      `json:"-" gorm:"column:batch_id;not null;unique_index"`
+	Amount        int64      `json:"amount" gorm:"column:amount;not null"`
+	Currency      string     `json:"currency" gorm:"column:currency;not null"`
+	Status        string     `json:"status" gorm:"column:status;not null;index:idx_payouts_due"`
+	BankReference string     `json:"bank_reference,omitempty" gorm:"column:bank_reference"`
+	Attempts      int        `json:"attempts" gorm:"column:attempts;not null;default:0"`
+	NextAttemptAt time.Time  `json:"-" gorm:"column:next_attempt_at;not null;index:idx_payouts_due"`
+	LastError     string     `json:"last_error,omitempty" gorm:"column:last_error"`
+	PaidAt        *time.Time `json:"paid_at,omitempty" gorm:"column:paid_at"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the Payout struct
+func (Payout) TableName() string {
+	return "payouts"
+}
@@ -17,9 +17,14 @@ type UserClaims struct {
 	Type  string `json:"type"`
 }
 
+// Token is an access or refresh token of a merchant. APIKeyID is the key it was issued with,
+// the token stops working once that key is revoked or expires, and works in the Mode of the key.
 type Token struct {
 	ID          int64     `json:"id" gorm:"column:id;primary_key"`
 	Token       string    `json:"token" bson:"token"`
+	MerchantID  int64     `json:"merchant_id" bson:"merchant_id" gorm:"column:merchant_id;not null;default:0;index"`
+	APIKeyID    int64     `json:"-" gorm:"column:api_key_id;not null;default:0"`
+	Mode        string    `json:"-" gorm:"column:mode;not null;default:'live'"`
 	Type        string    `json:"type" bson:"type"`
 	ExpiresAt   time.Time `json:"expires_at" bson:"expires_at"`
 	Blacklisted bool      `json:"blacklisted" bson:"blacklisted"`
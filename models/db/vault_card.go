@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// VaultCard represents a row in the "vault_cards" table, a card stored in the vault for a merchant.
+// The card number is only kept encrypted, payments refer to the card by its Token. Cards are stored
+// separately in test and live Mode.
+type VaultCard struct {
+	ID                  int64     `json:"-" gorm:"column:id;primary_key"`
+	Token               string    `json:"card_token" gorm:"column:token;not null;unique_index"`
+	MerchantID          int64     `json:"-" gorm:"column:merchant_id;not null;unique_index:idx_vault_cards_merchant_mode_fingerprint"`
+	Mode                string    `json:"mode" gorm:"column:mode;not null;default:'live';unique_index:idx_vault_cards_merchant_mode_fingerprint"`
+	Fingerprint         string    `json:"-" gorm:"column:fingerprint;not null;unique_index:idx_vault_cards_merchant_mode_fingerprint"`
+	EncryptedCardNumber string    `json:"-" gorm:"column:encrypted_card_number;not null"`
+	Last4               string    `json:"last4" gorm:"column:last4;not null"`
+	Brand               string    `json:"brand" gorm:"column:brand"`
+	ExpMonth            int       `json:"expiry_month" gorm:"column:exp_month;not null"`
+	ExpYear             int       `json:"expiry_year" gorm:"column:exp_year;not null"`
+	CreatedAt           time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the VaultCard struct
+func (VaultCard) TableName() string {
+	return "vault_cards"
+}
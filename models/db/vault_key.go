@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// RetiredVaultKey represents a row in the "retired_vault_keys" table. Retired keys no longer seal
+// any stored value and are refused by the vault even while they are still configured.
+type RetiredVaultKey struct {
+	ID        int64     `json:"-" gorm:"column:id;primary_key"`
+	KeyID     string    `json:"key_id" gorm:"column:key_id;not null;unique_index"`
+	RetiredAt time.Time `json:"retired_at" gorm:"column:retired_at;not null"`
+}
+
+// TableName specifies the table name for the RetiredVaultKey struct
+func (RetiredVaultKey) TableName() string {
+	return "retired_vault_keys"
+}
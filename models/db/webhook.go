@@ -0,0 +1,105 @@
+package models
+
+import "time"
+
+// Webhook event types sent to merchants
+const (
+	EventPaymentAuthorized = "payment.authorized"
+	EventPaymentCaptured   = "payment.captured"
+	EventPaymentDeclined   = "payment.declined"
+	EventPaymentFailed     = "payment.failed"
+	EventPaymentVoided     = "payment.voided"
+	EventRefundSucceeded   = "refund.succeeded"
+	EventRefundFailed      = "refund.failed"
+)
+
+// WebhookEventTypes lists every event type an endpoint can subscribe to
+var WebhookEventTypes = []string{
+	EventPaymentAuthorized, EventPaymentCaptured, EventPaymentDeclined, EventPaymentFailed,
+	EventPaymentVoided, EventRefundSucceeded, EventRefundFailed,
+}
+
+// Webhook delivery statuses. Failed deliveries are retried with backoff until they succeed or run
+// out of attempts and are dead-lettered.
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusSucceeded = "succeeded"
+	DeliveryStatusDead      = "dead"
+)
+
+// WebhookEndpoint represents a row in the "webhook_endpoints" table, a URL of the merchant receiving
+// events. EventTypes is a comma separated list, empty for every event. Secret signs the events and is
+// encrypted by the vault. Endpoints only receive the events of payments in their Mode.
+type WebhookEndpoint struct {
+	ID          int64     `json:"-" gorm:"column:id;primary_key"`
+	EndpointID  string    `json:"endpoint_id" gorm:"column:endpoint_id;not null;unique_index"`
+	MerchantID  int64     `json:"-" gorm:"column:merchant_id;not null;index"`
+	Mode        string    `json:"mode" gorm:"column:mode;not null;default:'live'"`
+	URL         string    `json:"url" gorm:"column:url;not null"`
+	Description string    `json:"description" gorm:"column:description"`
+	EventTypes  string    `json:"-" gorm:"column:event_types;not null;default:''"`
+	Enabled     bool      `json:"enabled" gorm:"column:enabled;not null;default:true"`
+	Secret      string    `json:"-" gorm:"column:secret;not null"`
+	CreatedAt   time.Time `json:"created_at" gorm:"column:created_at"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"column:updated_at"`
+}
+
+// TableName specifies the table name for the WebhookEndpoint struct
+func (WebhookEndpoint) TableName() string {
+	return "webhook_endpoints"
+}
+
+// WebhookEvent represents a row in the "webhook_events" table, an event of a merchant as sent to its
+// endpoints. Payload is the JSON body of the requests.
+type WebhookEvent struct {
+	ID         int64     `json:"-" gorm:"column:id;primary_key"`
+	EventID    string    `json:"event_id" gorm:"column:event_id;not null;unique_index"`
+	MerchantID int64     `json:"-" gorm:"column:merchant_id;not null;index"`
+	Type       string    `json:"type" gorm:"column:type;not null"`
+	Payload    string    `json:"-" gorm:"column:payload;type:text;not null"`
+	CreatedAt  time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the WebhookEvent struct
+func (WebhookEvent) TableName() string {
+	return "webhook_events"
+}
+
+// WebhookDelivery represents a row in the "webhook_deliveries" table, an event on its way to one endpoint.
+// Mode is the mode of the endpoint.
+type WebhookDelivery struct {
+	ID            int64      `json:"-" gorm:"column:id;primary_key"`
+	DeliveryID    string     `json:"delivery_id" gorm:"column:delivery_id;not null;unique_index"`
+	MerchantID    int64      `json:"-" gorm:"column:merchant_id;not null;index"`
+	Mode          string     `json:"-" gorm:"column:mode;not null;default:'live'"`
+	EventID       int64      `json:"-" gorm:"column:event_id;not null;index"`
+	EndpointID    int64      `json:"-" gorm:"column:endpoint_id;not null;index"`
+	Status        string     `json:"status" gorm:"column:status;not null;index:idx_webhook_deliveries_due"`
+	Attempts      int        `json:"attempts" gorm:"column:attempts;not null;default:0"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"column:next_attempt_at;not null;index:idx_webhook_deliveries_due"`
+	LastAttemptAt *time.Time `json:"last_attempt_at,omitempty" gorm:"column:last_attempt_at"`
+	LastError     string     `json:"last_error,omitempty" gorm:"column:last_error"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the WebhookDelivery struct
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
+// WebhookAttempt represents a row in the "webhook_attempts" table, the log of one request of a delivery.
+// Only the status of the answer is kept, never its body.
+type WebhookAttempt struct {
+	ID             int64     `json:"-" gorm:"column:id;primary_key"`
+	DeliveryID     int64     `json:"-" gorm:"column:delivery_id;not null;index"`
+	ResponseStatus int       `json:"response_status" gorm:"column:response_status"`
+	Error          string    `json:"error,omitempty" gorm:"column:error"`
+	DurationMillis int64     `json:"duration_ms" gorm:"column:duration_ms"`
+	Manual         bool      `json:"manual" gorm:"column:manual;not null;default:false"`
+	CreatedAt      time.Time `json:"created_at" gorm:"column:created_at"`
+}
+
+// TableName specifies the table name for the WebhookAttempt struct
+func (WebhookAttempt) TableName() string {
+	return "webhook_attempts"
+}
@@ -1,7 +1,11 @@
 package models
 
 import (
+	"checkout-task/constants"
+	db "checkout-task/models/db"
 	"checkout-task/utils"
+	"encoding/json"
+	"fmt"
 	validation "github.com/go-ozzo/ozzo-validation"
 	"github.com/go-ozzo/ozzo-validation/is"
 	"github.com/pkg/errors"
@@ -9,15 +13,19 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+// AuthRequest asks for access tokens of the merchant with the email, proven by one of its secret API keys
 type AuthRequest struct {
-	Email string `json:"email"`
+	Email     string `json:"email"`
+	SecretKey string `json:"secret_key"`
 }
 
 func (a AuthRequest) Validate() error {
 	return validation.ValidateStruct(&a,
 		validation.Field(&a.Email, validation.Required, is.Email),
+		validation.Field(&a.SecretKey, validation.Required),
 	)
 }
 
@@ -36,56 +44,515 @@ func (a RefreshRequest) Validate() error {
 	)
 }
 
+type MerchantRequest struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func (a MerchantRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Name, validation.Required, validation.Length(1, 255)),
+		validation.Field(&a.Email, validation.Required, is.Email),
+	)
+}
+
+type APIKeyRequest struct {
+	Mode string `json:"mode"`
+}
+
+func (a APIKeyRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Mode, validation.Required, validation.In("test", "live")),
+	)
+}
+
+// RollAPIKeyRequest sets how long the rolled key keeps working, at most a week
+type RollAPIKeyRequest struct {
+	ExpiresInHours int `json:"expires_in_hours"`
+}
+
+func (a RollAPIKeyRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.ExpiresInHours, validation.Min(0), validation.Max(168)),
+	)
+}
+
+// WebhookEndpointRequest creates a webhook endpoint. Events lists the event types to send, all of them when empty.
+type WebhookEndpointRequest struct {
+	URL         string   `json:"url"`
+	Description string   `json:"description"`
+	Events      []string `json:"events"`
+}
+
+func (a WebhookEndpointRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.URL, validation.Required, is.URL, validation.Length(0, 2048), validation.By(webhookURLScheme)),
+		validation.Field(&a.Description, validation.Length(0, 255)),
+		validation.Field(&a.Events, validation.By(webhookEventTypes)),
+	)
+}
+
+// WebhookEndpointUpdateRequest changes the fields of a webhook endpoint that are sent
+type WebhookEndpointUpdateRequest struct {
+	URL         *string   `json:"url"`
+	Description *string   `json:"description"`
+	Events      *[]string `json:"events"`
+	Enabled     *bool     `json:"enabled"`
+}
+
+func (a WebhookEndpointUpdateRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.URL, validation.NilOrNotEmpty, is.URL, validation.Length(0, 2048), validation.By(webhookURLScheme)),
+		validation.Field(&a.Description, validation.Length(0, 255)),
+		validation.Field(&a.Events, validation.By(webhookEventTypes)),
+	)
+}
+
+func webhookURLScheme(value interface{}) error {
+	value, _ = validation.Indirect(value)
+	url, _ := value.(string)
+	if url != "" && !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return errors.New("must be an http or https URL")
+	}
+	return nil
+}
+
+func webhookEventTypes(value interface{}) error {
+	value, _ = validation.Indirect(value)
+	events, _ := value.([]string)
+	for _, event := range events {
+		known := false
+		for _, eventType := range db.WebhookEventTypes {
+			known = known || event == eventType
+		}
+		if !known {
+			return fmt.Errorf("unknown event type %s", event)
+		}
+	}
+	return nil
+}
+
+// WebhookEndpointResponse shows a webhook endpoint with the event types it receives
+type WebhookEndpointResponse struct {
+	db.WebhookEndpoint
+	Events []string `json:"events"`
+}
+
+// WebhookDeliveryResponse shows a delivery with its event, Attempts is the log of its requests
+type WebhookDeliveryResponse struct {
+	db.WebhookDelivery
+	EndpointID string              `json:"endpoint_id"`
+	EventID    string              `json:"event_id"`
+	EventType  string              `json:"event_type"`
+	AttemptLog []db.WebhookAttempt `json:"attempt_log,omitempty"`
+}
+
+// VaultStatus reports the keys of the card vault and the progress of re-encrypting under the active key
+type VaultStatus struct {
+	ActiveKeyID     string           `json:"active_key_id"`
+	PendingRotation int              `json:"pending_rotation"`
+	Keys            []VaultKeyStatus `json:"keys"`
+}
+
+// VaultKeyStatus counts by column the rows that depend on a key
+type VaultKeyStatus struct {
+	KeyID  string         `json:"key_id"`
+	Active bool           `json:"active"`
+	Rows   map[string]int `json:"rows"`
+}
+
 type PaymentResponse struct {
-	PaymentID        string  `json:"payment_id"`
-	MaskedCardNumber string  `json:"masked_card_number"`
-	Amount           float64 `json:"amount"`
-	Currency         string  `json:"currency"`
-	Status           string  `json:"status"`
+	PaymentID           string            `json:"payment_id"`
+	Mode                string            `json:"mode"`
+	Reference           string            `json:"reference,omitempty"`
+	Description         string            `json:"description,omitempty"`
+	Metadata            map[string]string `json:"metadata,omitempty"`
+	StatementDescriptor string            `json:"statement_descriptor,omitempty"`
+	CardToken           string            `json:"card_token,omitempty"`
+	MaskedCardNumber    string            `json:"masked_card_number"`
+	Brand               string            `json:"brand,omitempty"`
+	IssuerCountry       string            `json:"issuer_country,omitempty"`
+	CardType            string            `json:"card_type,omitempty"`
+	Commercial          bool              `json:"commercial"`
+	Amount              int64             `json:"amount"`
+	RefundedAmount      int64             `json:"refunded_amount"`
+	Fee                 int64             `json:"fee"`
+	Currency            string            `json:"currency"`
+	Status              string            `json:"status"`
+	DeclineCode         string            `json:"decline_code,omitempty"`
+	DeclineCategory     string            `json:"decline_category,omitempty"`
+	Retryable           bool              `json:"retryable"`
+	CreatedAt           time.Time         `json:"created_at"`
+	UpdatedAt           time.Time         `json:"updated_at"`
+}
+
+// PaymentListRequest filters and pages the payments of a merchant. Amounts are in minor units,
+// dates in RFC 3339. Cursor is the NextCursor of the previous page. Exports take the same filters
+// as JSON, without paging.
+type PaymentListRequest struct {
+	Status      string    `form:"status" json:"status,omitempty"`
+	Currency    string    `form:"currency" json:"currency,omitempty"`
+	MinAmount   int64     `form:"min_amount" json:"min_amount,omitempty"`
+	MaxAmount   int64     `form:"max_amount" json:"max_amount,omitempty"`
+	CreatedFrom time.Time `form:"created_from" time_format:"2006-01-02T15:04:05Z07:00" json:"created_from"`
+	CreatedTo   time.Time `form:"created_to" time_format:"2006-01-02T15:04:05Z07:00" json:"created_to"`
+	Last4       string    `form:"last4" json:"last4,omitempty"`
+	Brand       string    `form:"brand" json:"brand,omitempty"`
+	Reference   string    `form:"reference" json:"reference,omitempty"`
+	Order       string    `form:"order" json:"order,omitempty"`
+	Limit       int       `form:"limit" json:"-"`
+	Cursor      string    `form:"cursor" json:"-"`
+}
+
+func (a PaymentListRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Status, validation.In(
+			constants.PENDING, constants.AUTHORIZATIONREQUESTED, constants.AUTHORIZED, constants.CAPTUREREQUESTED, constants.VOIDREQUESTED, constants.CAPTURED, constants.PARTIALLYREFUNDED,
+			constants.REFUNDED, constants.VOIDED, constants.DECLINED, constants.FAILED,
+		)),
+		validation.Field(&a.Currency, validation.By(func(value interface{}) error {
+			if currency := value.(string); currency != "" && !utils.ValidateCurrency(currency) {
+				return errors.New("unsupported currency")
+			}
+			return nil
+		})),
+		validation.Field(&a.MinAmount, validation.Min(int64(0))),
+		validation.Field(&a.MaxAmount, validation.Min(a.MinAmount)),
+		validation.Field(&a.CreatedTo, validation.By(func(value interface{}) error {
+			if createdTo := value.(time.Time); !createdTo.IsZero() && createdTo.Before(a.CreatedFrom) {
+				return errors.New("must not be before created_from")
+			}
+			return nil
+		})),
+		validation.Field(&a.Last4, validation.Match(regexp.MustCompile("^[0-9]{4}$"))),
+		validation.Field(&a.Brand, validation.Length(0, 32)),
+		validation.Field(&a.Reference, validation.Length(0, MaxReferenceLength)),
+		validation.Field(&a.Order, validation.In("asc", "desc")),
+		validation.Field(&a.Limit, validation.Min(0), validation.Max(100)),
+	)
 }
 
+// PaymentList is a page of payments, NextCursor fetches the next one while HasMore is set
+type PaymentList struct {
+	Payments   []PaymentResponse `json:"payments"`
+	HasMore    bool              `json:"has_more"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// ExportRequest exports the payments matching Filters as CSV or NDJSON
+type ExportRequest struct {
+	Format  string             `json:"format"`
+	Filters PaymentListRequest `json:"filters"`
+}
+
+func (a ExportRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Format, validation.Required, validation.In("csv", "ndjson")),
+		validation.Field(&a.Filters),
+	)
+}
+
+// ExportResponse reports the progress of an export, DownloadURL is set once the file is complete
+type ExportResponse struct {
+	ExportID    string     `json:"export_id"`
+	Format      string     `json:"format"`
+	Status      string     `json:"status"`
+	RowCount    int64      `json:"row_count"`
+	DownloadURL string     `json:"download_url,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// PaymentRequest pays either with the card details or with the CardToken of a card stored in the vault
 type PaymentRequest struct {
-	CardNumber string  `json:"card_number"`
-	ExpMonth   int     `json:"expiry_month"`
-	ExpYear    int     `json:"expiry_year"`
-	Amount     float64 `json:"amount"`
-	Currency   string  `json:"currency"`
-	CVV        int     `json:"cvv"`
+	Reference           string            `json:"reference"`
+	Description         string            `json:"description"`
+	Metadata            map[string]string `json:"metadata"`
+	StatementDescriptor string            `json:"statement_descriptor"`
+	CardToken           string            `json:"card_token"`
+	CardNumber          string            `json:"card_number"`
+	ExpMonth            int               `json:"expiry_month"`
+	ExpYear             int               `json:"expiry_year"`
+	Amount              int64             `json:"amount"`
+	Currency            string            `json:"currency"`
+	CVV                 int               `json:"cvv"`
+}
+
+// CardRequest stores a card in the vault
+type CardRequest struct {
+	CardNumber string `json:"card_number"`
+	ExpMonth   int    `json:"expiry_month"`
+	ExpYear    int    `json:"expiry_year"`
+}
+
+type CardResponse struct {
+	CardToken        string `json:"card_token"`
+	MaskedCardNumber string `json:"masked_card_number"`
+	Brand            string `json:"brand,omitempty"`
+	ExpMonth         int    `json:"expiry_month"`
+	ExpYear          int    `json:"expiry_year"`
 }
 
 type ProcessPaymentResponse struct {
 	PaymentIdentifier string
+	CardToken         string
+	Brand             string
 	Status            string
+	DeclineCode       string
+	DeclineCategory   string
+	Retryable         bool
 }
 
-func ValidatePaymentRequest(request PaymentRequest) error {
-	// Validate card number format
-	if len(strings.ReplaceAll(request.CardNumber, " ", "")) != 16 {
-		return errors.New("invalid card number")
+// RefundRequest refunds part of a captured payment, or whatever is left of it when Amount is omitted
+type RefundRequest struct {
+	Amount int64 `json:"amount"`
+}
+
+func (a RefundRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Amount, validation.Min(int64(0))),
+	)
+}
+
+type RefundResponse struct {
+	RefundID          string
+	PaymentIdentifier string
+	Amount            int64
+	Currency          string
+	Status            string
+}
+
+// PaymentFeesResponse breaks down the fee charged for a payment: the rate of the pricing rule in basis points
+// of the amount plus its fixed fee, all amounts in minor units. Net is what the merchant is paid.
+type PaymentFeesResponse struct {
+	PaymentID   string `json:"payment_id"`
+	Amount      int64  `json:"amount"`
+	Currency    string `json:"currency"`
+	Brand       string `json:"brand,omitempty"`
+	CardRegion  string `json:"card_region"`
+	Plan        string `json:"plan,omitempty"`
+	RateBps     int64  `json:"rate_bps"`
+	VariableFee int64  `json:"variable_fee"`
+	FixedFee    int64  `json:"fixed_fee"`
+	Fee         int64  `json:"fee"`
+	Net         int64  `json:"net"`
+}
+
+// PricingRuleRequest is a rule of a pricing plan or of a merchant overriding its plan: a rate in basis points
+// of the amount, at most 100%, plus a fixed fee in minor units of its currency. Empty brand, card region and
+// currency match any payment, only rules with a currency can charge a fixed fee.
+type PricingRuleRequest struct {
+	Brand        string `json:"brand"`
+	CardRegion   string `json:"card_region"`
+	Currency     string `json:"currency"`
+	RateBasisPts int64  `json:"rate_bps"`
+	FixedFee     int64  `json:"fixed_fee"`
+}
+
+func (a PricingRuleRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Brand, validation.In(utils.BrandVisa, utils.BrandMastercard, utils.BrandAmex, utils.BrandDiscover,
+			utils.BrandJCB, utils.BrandDiners, utils.BrandUnionPay, utils.BrandMaestro)),
+		validation.Field(&a.CardRegion, validation.In(db.CardRegionEEA, db.CardRegionUK, db.CardRegionNorthAmerica, db.CardRegionInternational)),
+		validation.Field(&a.Currency, validation.By(pricingCurrency)),
+		validation.Field(&a.RateBasisPts, validation.Min(0), validation.Max(10000)),
+		validation.Field(&a.FixedFee, validation.Min(0), validation.By(func(value interface{}) error {
+			if fixedFee, _ := value.(int64); fixedFee > 0 && a.Currency == "" {
+				return errors.New("requires a currency, fixed fees are in minor units of the currency")
+			}
+			return nil
+		})),
+	)
+}
+
+func pricingCurrency(value interface{}) error {
+	currency, _ := value.(string)
+	if currency != "" && !utils.ValidateCurrency(currency) {
+		return errors.New("unsupported currency")
 	}
+	return nil
+}
 
-	// Validate expiry date
-	now := time.Now()
-	expDate := time.Date(request.ExpYear, time.Month(request.ExpMonth), 1, 0, 0, 0, 0, time.UTC)
-	if expDate.Before(now) {
-		return errors.New("expired card")
+// PricingPlanRequest creates a pricing plan with its rules. A new default plan replaces the previous one.
+type PricingPlanRequest struct {
+	PlanID    string               `json:"plan_id"`
+	Name      string               `json:"name"`
+	IsDefault bool                 `json:"is_default"`
+	Rules     []PricingRuleRequest `json:"rules"`
+}
+
+func (a PricingPlanRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.PlanID, validation.Required, validation.Match(regexp.MustCompile("^[a-z0-9_-]{1,64}$")).Error("must be lowercase letters, digits, _ and -")),
+		validation.Field(&a.Name, validation.Required, validation.Length(1, 255)),
+		validation.Field(&a.Rules),
+	)
+}
+
+// PricingRulesRequest replaces every rule of a pricing plan, or every override of a merchant
+type PricingRulesRequest struct {
+	Rules []PricingRuleRequest `json:"rules"`
+}
+
+func (a PricingRulesRequest) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.Rules),
+	)
+}
+
+// MerchantPricingPlanRequest moves a merchant to a pricing plan, back to the default plan when PlanID is empty
+type MerchantPricingPlanRequest struct {
+	PlanID string `json:"plan_id"`
+}
+
+// PricingPlanResponse shows a pricing plan with its rules
+type PricingPlanResponse struct {
+	db.PricingPlan
+	Rules []db.PricingRule `json:"rules"`
+}
+
+// MerchantPricingResponse shows how a merchant is charged: its plan, empty for the default plan, and the
+// rules overriding it
+type MerchantPricingResponse struct {
+	PlanID    string           `json:"plan_id"`
+	Overrides []db.PricingRule `json:"overrides"`
+}
+
+// BalanceResponse shows the funds of a merchant in a currency, in minor units. Pending funds are captured
+// but not settled yet, available funds are settled and wait for a payout.
+type BalanceResponse struct {
+	Currency  string `json:"currency"`
+	Pending   int64  `json:"pending"`
+	Available int64  `json:"available"`
+}
+
+// SettlementBatchResponse shows a settlement batch with the payout it led to, batches refunds outweighed
+// have none
+type SettlementBatchResponse struct {
+	db.SettlementBatch
+	Payout *db.Payout `json:"payout,omitempty"`
+}
+
+// ReconciliationReport shows a reconciliation run with its items, the discrepancies unless asked otherwise
+type ReconciliationReport struct {
+	Run   db.ReconciliationRun    `json:"run"`
+	Items []db.ReconciliationItem `json:"items"`
+}
+
+// BindErrorMessage explains to the merchant why a request body could not be decoded
+func BindErrorMessage(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field == "amount" {
+		return "amount must be a whole number of minor units of the currency"
 	}
+	return "invalid request body"
+}
 
-	// Validate amount
-	if request.Amount <= 0 || request.Amount > 1000 {
-		return errors.New("invalid amount")
+var cardTokenPattern = regexp.MustCompile("^tok_[0-9a-f]{32}$")
+
+func ValidatePaymentRequest(request PaymentRequest) error {
+	if request.CardToken != "" {
+		// Stored cards are paid without the card details, the CVV is optional
+		if request.CardNumber != "" {
+			return errors.New("send either card_token or card_number, not both")
+		}
+		if !cardTokenPattern.MatchString(request.CardToken) {
+			return errors.New("invalid card token")
+		}
+	} else if err := ValidateCardRequest(CardRequest{
+		CardNumber: request.CardNumber,
+		ExpMonth:   request.ExpMonth,
+		ExpYear:    request.ExpYear,
+	}); err != nil {
+		return err
+	}
+
+	// Validate the merchant's own details of the payment
+	if err := validatePaymentDetails(request); err != nil {
+		return err
 	}
 
 	// Validate currency
-	supportedCurrencies := []string{"USD", "EUR", "GBP"}
-	if !utils.Contains(supportedCurrencies, request.Currency) {
+	if !utils.ValidateCurrency(request.Currency) {
 		return errors.New("unsupported currency")
 	}
 
-	// Validate CVV
-	if len(strconv.Itoa(request.CVV)) != 3 && len(strconv.Itoa(request.CVV)) != 4 {
+	// Validate amount, given in minor units of the currency and capped per currency
+	if request.Amount <= 0 || request.Amount > utils.MaxPaymentAmount(request.Currency) {
+		return errors.New("invalid amount")
+	}
+
+	// Validate CVV, the brand of stored cards is only known once the token is looked up
+	if request.CardToken != "" {
+		if request.CVV != 0 && len(strconv.Itoa(request.CVV)) != 3 && len(strconv.Itoa(request.CVV)) != 4 {
+			return errors.New("invalid CVV")
+		}
+		return nil
+	}
+	if !utils.ValidateCVV(request.CardNumber, request.CVV) {
 		return errors.New("invalid CVV")
 	}
 
 	return nil
 }
+
+// Limits of the merchant's details of a payment
+const (
+	MaxReferenceLength     = 255
+	MaxDescriptionLength   = 1000
+	MaxMetadataKeys        = 50
+	MaxMetadataKeyLength   = 40
+	MaxMetadataValueLength = 500
+)
+
+// statementDescriptorPattern allows what card networks print on statements: 5 to 22 Latin letters, digits,
+// spaces and simple punctuation
+var statementDescriptorPattern = regexp.MustCompile(`^[A-Za-z0-9 .,&/#+-]{5,22}$`)
+
+func validatePaymentDetails(request PaymentRequest) error {
+	if utf8.RuneCountInString(request.Reference) > MaxReferenceLength {
+		return fmt.Errorf("reference must be at most %d characters", MaxReferenceLength)
+	}
+	if utf8.RuneCountInString(request.Description) > MaxDescriptionLength {
+		return fmt.Errorf("description must be at most %d characters", MaxDescriptionLength)
+	}
+
+	if len(request.Metadata) > MaxMetadataKeys {
+		return fmt.Errorf("metadata must have at most %d keys", MaxMetadataKeys)
+	}
+	for key, value := range request.Metadata {
+		if key == "" || utf8.RuneCountInString(key) > MaxMetadataKeyLength {
+			return fmt.Errorf("metadata keys must be 1 to %d characters", MaxMetadataKeyLength)
+		}
+		if utf8.RuneCountInString(value) > MaxMetadataValueLength {
+			return fmt.Errorf("metadata value of %s must be at most %d characters", key, MaxMetadataValueLength)
+		}
+	}
+
+	// Statement descriptors need at least one letter
+	if request.StatementDescriptor != "" {
+		if !statementDescriptorPattern.MatchString(request.StatementDescriptor) ||
+			!strings.ContainsAny(strings.ToLower(request.StatementDescriptor), "abcdefghijklmnopqrstuvwxyz") {
+			return errors.New("statement_descriptor must be 5 to 22 Latin letters, digits, spaces or .,&/#+- with at least one letter")
+		}
+	}
+
+	return nil
+}
+
+func ValidateCardRequest(request CardRequest) error {
+	// Validate card number format, brand and checksum
+	if !utils.ValidateCardNumber(request.CardNumber) {
+		return errors.New("invalid card number")
+	}
+
+	// Validate expiry date
+	now := time.Now()
+	expDate := time.Date(request.ExpYear, time.Month(request.ExpMonth), 1, 0, 0, 0, 0, time.UTC)
+	if expDate.Before(now) {
+		return errors.New("expired card")
+	}
+
+	return nil
+}
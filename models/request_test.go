@@ -0,0 +1,61 @@
+package models
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestValidatePaymentDetails(t *testing.T) {
+	// Test case 1: the merchant's details are optional
+	assert.NoError(t, validatePaymentDetails(PaymentRequest{}))
+	assert.NoError(t, validatePaymentDetails(PaymentRequest{
+		Reference:           "order-1001",
+		Description:         "2 tickets",
+		Metadata:            map[string]string{"customer_id": "42", "channel": ""},
+		StatementDescriptor: "ACME SHOP #12",
+	}))
+
+	// Test case 2: sizes are limited
+	assert.Error(t, validatePaymentDetails(PaymentRequest{Reference: strings.Repeat("r", MaxReferenceLength+1)}))
+	assert.Error(t, validatePaymentDetails(PaymentRequest{Description: strings.Repeat("d", MaxDescriptionLength+1)}))
+	assert.Error(t, validatePaymentDetails(PaymentRequest{Metadata: map[string]string{"": "value"}}))
+	assert.Error(t, validatePaymentDetails(PaymentRequest{Metadata: map[string]string{strings.Repeat("k", MaxMetadataKeyLength+1): "value"}}))
+	assert.Error(t, validatePaymentDetails(PaymentRequest{Metadata: map[string]string{"key": strings.Repeat("v", MaxMetadataValueLength+1)}}))
+
+	metadata := map[string]string{}
+	for i := 0; i <= MaxMetadataKeys; i++ {
+		metadata[strings.Repeat("k", i+1)] = "value"
+	}
+	assert.Error(t, validatePaymentDetails(PaymentRequest{Metadata: metadata}))
+
+	// Test case 3: statement descriptors must be printable on a statement
+	for _, descriptor := range []string{"ACME", "ACME SHOP AND MORE STUFF", "ACME <SHOP>", "12345", "ÄCME SHOP"} {
+		assert.Error(t, validatePaymentDetails(PaymentRequest{StatementDescriptor: descriptor}), descriptor)
+	}
+}
+
+func TestPricingRuleRequest(t *testing.T) {
+	// Test case 1: rules may be limited to a brand, card region and currency
+	assert.NoError(t, PricingRuleRequest{Currency: "EUR", RateBasisPts: 290, FixedFee: 25}.Validate())
+	assert.NoError(t, PricingRuleRequest{Brand: "amex", CardRegion: "eea", Currency: "EUR", RateBasisPts: 350}.Validate())
+
+	// Test case 2: rates and fixed fees cannot be negative, rates are at most 100%
+	assert.Error(t, PricingRuleRequest{RateBasisPts: -1}.Validate())
+	assert.Error(t, PricingRuleRequest{FixedFee: -30}.Validate())
+	assert.Error(t, PricingRuleRequest{RateBasisPts: 10001}.Validate())
+
+	// Test case 3: unknown brands, regions and currencies never match a payment
+	assert.Error(t, PricingRuleRequest{Brand: "visa-electron"}.Validate())
+	assert.Error(t, PricingRuleRequest{CardRegion: "asia"}.Validate())
+	assert.Error(t, PricingRuleRequest{Currency: "XXX"}.Validate())
+
+	// Test case 4: fixed fees need a currency
+	assert.Error(t, PricingRuleRequest{RateBasisPts: 290, FixedFee: 30}.Validate())
+	assert.NoError(t, PricingRuleRequest{RateBasisPts: 290}.Validate())
+
+	// Test case 5: the rules of a plan are checked one by one
+	assert.Error(t, PricingPlanRequest{PlanID: "enterprise", Name: "Enterprise", Rules: []PricingRuleRequest{{RateBasisPts: 100}, {FixedFee: -1}}}.Validate())
+	assert.Error(t, PricingRulesRequest{Rules: []PricingRuleRequest{{RateBasisPts: -5}}}.Validate())
+	assert.Error(t, PricingPlanRequest{PlanID: "Enterprise Plan", Name: "Enterprise"}.Validate())
+}
@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"checkout-task/controllers"
+	"checkout-task/middlewares"
+	"checkout-task/middlewares/validators"
+	"github.com/gin-gonic/gin"
+)
+
+func AdminRoute(router *gin.RouterGroup) {
+	admin := router.Group("/admin", middlewares.AdminMiddleware())
+	{
+		admin.POST(
+			"/merchants",
+			validators.MerchantValidator(),
+			controllers.CreateMerchant,
+		)
+		admin.GET(
+			"/merchants",
+			controllers.ListMerchants,
+		)
+		admin.POST(
+			"/merchants/:id/api-keys",
+			validators.APIKeyValidator(),
+			controllers.CreateAPIKey,
+		)
+		admin.GET(
+			"/merchants/:id/api-keys",
+			controllers.ListAPIKeys,
+		)
+		admin.POST(
+			"/merchants/:id/api-keys/:key_id/roll",
+			validators.RollAPIKeyValidator(),
+			controllers.RollAPIKey,
+		)
+		admin.DELETE(
+			"/merchants/:id/api-keys/:key_id",
+			controllers.RevokeAPIKey,
+		)
+		admin.GET(
+			"/merchants/:id/pricing",
+			controllers.GetMerchantPricing,
+		)
+		admin.PUT(
+			"/merchants/:id/pricing/plan",
+			controllers.SetMerchantPricingPlan,
+		)
+		admin.PUT(
+			"/merchants/:id/pricing/overrides",
+			validators.PricingRulesValidator(),
+			controllers.ReplaceMerchantPricingOverrides,
+		)
+		admin.POST(
+			"/pricing-plans",
+			validators.PricingPlanValidator(),
+			controllers.CreatePricingPlan,
+		)
+		admin.GET(
+			"/pricing-plans",
+			controllers.ListPricingPlans,
+		)
+		admin.PUT(
+			"/pricing-plans/:plan_id/rules",
+			validators.PricingRulesValidator(),
+			controllers.ReplacePricingPlanRules,
+		)
+		admin.GET(
+			"/vault/keys",
+			controllers.GetVaultStatus,
+		)
+		admin.POST(
+			"/reconciliations",
+			controllers.CreateReconciliation,
+		)
+		admin.GET(
+			"/reconciliations",
+			controllers.ListReconciliations,
+		)
+		admin.GET(
+			"/reconciliations/:id",
+			controllers.GetReconciliation,
+		)
+	}
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"checkout-task/controllers"
+	"checkout-task/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+func Balance(router *gin.RouterGroup) {
+	router.GET(
+		"/balance",
+		middlewares.AuthMiddleware(),
+		middlewares.LiveModeMiddleware(),
+		controllers.GetBalance,
+	)
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"checkout-task/controllers"
+	"checkout-task/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+func BINs(router *gin.RouterGroup) {
+	bins := router.Group("/bins")
+	{
+		bins.GET(
+			"/:bin",
+			middlewares.AuthMiddleware(),
+			controllers.GetBIN,
+		)
+	}
+}
@@ -0,0 +1,20 @@
+package routes
+
+import (
+	"checkout-task/controllers"
+	"checkout-task/middlewares"
+	"checkout-task/middlewares/validators"
+	"github.com/gin-gonic/gin"
+)
+
+func Cards(router *gin.RouterGroup) {
+	cards := router.Group("/cards")
+	{
+		cards.POST(
+			"",
+			middlewares.AuthMiddleware(),
+			validators.CardValidator(),
+			controllers.StoreCard,
+		)
+	}
+}
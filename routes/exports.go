@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"checkout-task/controllers"
+	"checkout-task/middlewares"
+	"checkout-task/middlewares/validators"
+	"github.com/gin-gonic/gin"
+)
+
+func Exports(router *gin.RouterGroup) {
+	exports := router.Group("/exports")
+	{
+		exports.POST(
+			"",
+			middlewares.AuthMiddleware(),
+			validators.ExportValidator(),
+			controllers.CreateExport,
+		)
+		exports.GET(
+			"/:id",
+			middlewares.AuthMiddleware(),
+			controllers.GetExport,
+		)
+		exports.GET(
+			"/:id/download",
+			middlewares.AuthMiddleware(),
+			controllers.DownloadExport,
+		)
+	}
+}
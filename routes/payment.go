@@ -3,6 +3,7 @@ package routes
 import (
 	"checkout-task/controllers"
 	"checkout-task/middlewares"
+	"checkout-task/middlewares/validators"
 	"github.com/gin-gonic/gin"
 )
 
@@ -11,13 +12,59 @@ func Payments(router *gin.RouterGroup) {
 	{
 		auth.POST(
 			"/process-payment",
-			middlewares.JWTMiddleware(),
+			middlewares.AuthMiddleware(),
+			middlewares.IdempotencyMiddleware(),
 			controllers.ProcessPayment,
 		)
 		auth.GET(
 			"/get-payment",
-			middlewares.JWTMiddleware(),
+			middlewares.AuthMiddleware(),
 			controllers.GetPaymentDetails,
 		)
 	}
+
+	payments := router.Group("/payments")
+	{
+		payments.GET(
+			"",
+			middlewares.AuthMiddleware(),
+			validators.PaymentListValidator(),
+			controllers.ListPayments,
+		)
+		payments.POST(
+			"/authorize",
+			middlewares.AuthMiddleware(),
+			middlewares.IdempotencyMiddleware(),
+			controllers.AuthorizePayment,
+		)
+		payments.POST(
+			"/:id/capture",
+			middlewares.AuthMiddleware(),
+			middlewares.IdempotencyMiddleware(),
+			controllers.CapturePayment,
+		)
+		payments.GET(
+			"/:id/events",
+			middlewares.AuthMiddleware(),
+			controllers.GetPaymentEvents,
+		)
+		payments.GET(
+			"/:id/fees",
+			middlewares.AuthMiddleware(),
+			controllers.GetPaymentFees,
+		)
+		payments.POST(
+			"/:id/void",
+			middlewares.AuthMiddleware(),
+			middlewares.IdempotencyMiddleware(),
+			controllers.VoidPayment,
+		)
+		payments.POST(
+			"/:id/refunds",
+			middlewares.AuthMiddleware(),
+			middlewares.IdempotencyMiddleware(),
+			validators.RefundValidator(),
+			controllers.RefundPayment,
+		)
+	}
 }
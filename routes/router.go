@@ -26,7 +26,14 @@ func New() *gin.Engine {
 	v1 := r.Group("/v1")
 	{
 		AuthRoute(v1)
+		AdminRoute(v1)
 		Payments(v1)
+		Cards(v1)
+		BINs(v1)
+		Exports(v1)
+		Webhooks(v1)
+		Balance(v1)
+		Settlements(v1)
 
 	}
 
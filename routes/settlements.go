@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"checkout-task/controllers"
+	"checkout-task/middlewares"
+	"github.com/gin-gonic/gin"
+)
+
+func Settlements(router *gin.RouterGroup) {
+	settlements := router.Group("/settlements")
+	{
+		settlements.GET(
+			"",
+			middlewares.AuthMiddleware(),
+			middlewares.LiveModeMiddleware(),
+			controllers.ListSettlements,
+		)
+		settlements.GET(
+			"/:id",
+			middlewares.AuthMiddleware(),
+			middlewares.LiveModeMiddleware(),
+			controllers.GetSettlement,
+		)
+		settlements.GET(
+			"/:id/lines",
+			middlewares.AuthMiddleware(),
+			middlewares.LiveModeMiddleware(),
+			controllers.ListSettlementLines,
+		)
+	}
+}
@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"checkout-task/controllers"
+	"checkout-task/middlewares"
+	"checkout-task/middlewares/validators"
+	"github.com/gin-gonic/gin"
+)
+
+func Webhooks(router *gin.RouterGroup) {
+	endpoints := router.Group("/webhooks/endpoints")
+	{
+		endpoints.POST(
+			"",
+			middlewares.AuthMiddleware(),
+			validators.WebhookEndpointValidator(),
+			controllers.CreateWebhookEndpoint,
+		)
+		endpoints.GET(
+			"",
+			middlewares.AuthMiddleware(),
+			controllers.ListWebhookEndpoints,
+		)
+		endpoints.GET(
+			"/:id",
+			middlewares.AuthMiddleware(),
+			controllers.GetWebhookEndpoint,
+		)
+		endpoints.PATCH(
+			"/:id",
+			middlewares.AuthMiddleware(),
+			validators.WebhookEndpointUpdateValidator(),
+			controllers.UpdateWebhookEndpoint,
+		)
+		endpoints.DELETE(
+			"/:id",
+			middlewares.AuthMiddleware(),
+			controllers.DeleteWebhookEndpoint,
+		)
+		endpoints.GET(
+			"/:id/deliveries",
+			middlewares.AuthMiddleware(),
+			controllers.ListWebhookDeliveries,
+		)
+	}
+
+	deliveries := router.Group("/webhooks/deliveries")
+	{
+		deliveries.GET(
+			"/:id",
+			middlewares.AuthMiddleware(),
+			controllers.GetWebhookDelivery,
+		)
+		deliveries.POST(
+			"/:id/redeliver",
+			middlewares.AuthMiddleware(),
+			controllers.RedeliverWebhook,
+		)
+	}
+}
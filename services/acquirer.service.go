@@ -0,0 +1,28 @@
+package services
+
+import (
+	"checkout-task/acquirer"
+	db "checkout-task/models/db"
+	"time"
+)
+
+// Bank is the acquiring bank live payments are forwarded to, TestBank the bank simulator test payments go to.
+// Each has a circuit breaker of its own, so a failing simulator does not stop live payments.
+var (
+	Bank     acquirer.Acquirer
+	TestBank acquirer.Acquirer
+)
+
+func InitAcquirer() {
+	timeout := time.Duration(Config.BankTimeoutMillis) * time.Millisecond
+	Bank = acquirer.NewCircuitBreaker("bank", acquirer.NewHTTPAcquirer(Config.BankURL, timeout))
+	TestBank = acquirer.NewCircuitBreaker("test bank", acquirer.NewHTTPAcquirer(Config.TestBankURL, timeout))
+}
+
+// acquirerFor returns the bank payments in the mode are forwarded to, test payments never reach the live bank
+func acquirerFor(mode string) acquirer.Acquirer {
+	if mode == db.APIKeyModeLive {
+		return Bank
+	}
+	return TestBank
+}
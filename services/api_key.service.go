@@ -0,0 +1,170 @@
+package services
+
+import (
+	"checkout-task/logger"
+	db "checkout-task/models/db"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// generateKey returns a random key such as "sk_test_<48 hex characters>"
+func generateKey(prefix string, mode string) (string, error) {
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return prefix + "_" + mode + "_" + hex.EncodeToString(random), nil
+}
+
+// hashAPIKey hashes a secret key, keys are random enough for a plain SHA-256 to be safe
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// newAPIKey generates a key pair for the merchant, the secret key is returned only here
+func newAPIKey(merchantID int64, mode string) (db.APIKey, string, error) {
+	publishableKey, err := generateKey(db.PublishableKeyPrefix, mode)
+	if err != nil {
+		return db.APIKey{}, "", err
+	}
+	secretKey, err := generateKey(db.SecretKeyPrefix, mode)
+	if err != nil {
+		return db.APIKey{}, "", err
+	}
+
+	return db.APIKey{
+		KeyID:          uuid.New().String(),
+		MerchantID:     merchantID,
+		Mode:           mode,
+		PublishableKey: publishableKey,
+		SecretHash:     hashAPIKey(secretKey),
+		SecretLast4:    secretKey[len(secretKey)-4:],
+	}, secretKey, nil
+}
+
+// CreateAPIKey creates a publishable and secret key pair for the merchant
+func CreateAPIKey(merchant db.Merchant, mode string) (db.APIKey, string, error) {
+	apiKey, secretKey, err := newAPIKey(merchant.ID, mode)
+	if err != nil {
+		logger.Error("failed to generate api key", zap.Error(err))
+		return db.APIKey{}, "", fmt.Errorf("failed to generate api key")
+	}
+
+	if err := DbConnection.Create(&apiKey).Error; err != nil {
+		logger.Error("failed to store api key", zap.Error(err))
+		return db.APIKey{}, "", fmt.Errorf("failed to store api key")
+	}
+
+	return apiKey, secretKey, nil
+}
+
+// ListAPIKeys returns every key pair of the merchant, revoked ones included, oldest first
+func ListAPIKeys(merchant db.Merchant) ([]db.APIKey, error) {
+	var apiKeys []db.APIKey
+	if err := DbConnection.Where("merchant_id = ?", merchant.ID).Order("id asc").Find(&apiKeys).Error; err != nil {
+		logger.Error("failed to retrieve api keys", zap.Error(err))
+		return nil, fmt.Errorf("failed to retrieve api keys")
+	}
+	return apiKeys, nil
+}
+
+// RollAPIKey replaces a key pair with a new one of the same mode. The old pair keeps working
+// for expiresIn so integrations can switch over, a zero duration revokes it straight away.
+func RollAPIKey(merchant db.Merchant, keyID string, expiresIn time.Duration) (db.APIKey, string, error) {
+	tx := DbConnection.Begin()
+
+	var oldKey db.APIKey
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("key_id = ? AND merchant_id = ?", keyID, merchant.ID).First(&oldKey).Error; err != nil {
+		tx.Rollback()
+		return db.APIKey{}, "", ErrAPIKeyNotFound
+	}
+
+	now := time.Now()
+	if !oldKey.Active(now) {
+		tx.Rollback()
+		return db.APIKey{}, "", fmt.Errorf("api key is no longer active and cannot be rolled")
+	}
+
+	update := map[string]interface{}{"revoked_at": now}
+	if expiresIn > 0 {
+		update = map[string]interface{}{"expires_at": now.Add(expiresIn)}
+	}
+	if err := tx.Model(&oldKey).UpdateColumns(update).Error; err != nil {
+		tx.Rollback()
+		logger.Error("failed to update api key", zap.Error(err))
+		return db.APIKey{}, "", fmt.Errorf("failed to roll api key")
+	}
+
+	newKey, secretKey, err := newAPIKey(merchant.ID, oldKey.Mode)
+	if err != nil {
+		tx.Rollback()
+		logger.Error("failed to generate api key", zap.Error(err))
+		return db.APIKey{}, "", fmt.Errorf("failed to roll api key")
+	}
+	if err := tx.Create(&newKey).Error; err != nil {
+		tx.Rollback()
+		logger.Error("failed to store api key", zap.Error(err))
+		return db.APIKey{}, "", fmt.Errorf("failed to roll api key")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		logger.Error("failed to store api key", zap.Error(err))
+		return db.APIKey{}, "", fmt.Errorf("failed to roll api key")
+	}
+
+	return newKey, secretKey, nil
+}
+
+// RevokeAPIKey stops a key pair from authenticating any further request
+func RevokeAPIKey(merchant db.Merchant, keyID string) (db.APIKey, error) {
+	var apiKey db.APIKey
+	if err := DbConnection.Where("key_id = ? AND merchant_id = ?", keyID, merchant.ID).First(&apiKey).Error; err != nil {
+		return db.APIKey{}, ErrAPIKeyNotFound
+	}
+
+	if apiKey.RevokedAt == nil {
+		now := time.Now()
+		if err := DbConnection.Model(&apiKey).UpdateColumn("revoked_at", now).Error; err != nil {
+			logger.Error("failed to revoke api key", zap.Error(err))
+			return db.APIKey{}, fmt.Errorf("failed to revoke api key")
+		}
+		apiKey.RevokedAt = &now
+	}
+
+	return apiKey, nil
+}
+
+// VerifySecretKey finds the active key pair a secret key belongs to
+func VerifySecretKey(secretKey string) (*db.APIKey, error) {
+	if strings.HasPrefix(secretKey, db.PublishableKeyPrefix+"_") {
+		return &db.APIKey{}, errors.New("publishable keys cannot be used for this request, use the secret key")
+	}
+	if !strings.HasPrefix(secretKey, db.SecretKeyPrefix+"_") {
+		return &db.APIKey{}, errors.New("not valid api key")
+	}
+
+	var apiKey db.APIKey
+	if err := DbConnection.Where("secret_hash = ?", hashAPIKey(secretKey)).First(&apiKey).Error; err != nil {
+		return &db.APIKey{}, errors.New("not valid api key")
+	}
+
+	now := time.Now()
+	if !apiKey.Active(now) {
+		return &db.APIKey{}, errors.New("api key has been revoked")
+	}
+
+	if err := DbConnection.Model(&apiKey).UpdateColumn("last_used_at", now).Error; err != nil {
+		logger.Error("failed to record api key usage", zap.Error(err))
+	}
+	return &apiKey, nil
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	db "checkout-task/models/db"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewAPIKey(t *testing.T) {
+	apiKey, secretKey, err := newAPIKey(1, db.APIKeyModeTest)
+	assert.NoError(t, err)
+
+	// Test case 1: keys carry their kind and mode
+	assert.True(t, strings.HasPrefix(apiKey.PublishableKey, "pk_test_"))
+	assert.True(t, strings.HasPrefix(secretKey, "sk_test_"))
+
+	// Test case 2: only the hash of the secret key is kept
+	assert.NotContains(t, apiKey.SecretHash, secretKey)
+	assert.Equal(t, hashAPIKey(secretKey), apiKey.SecretHash)
+	assert.Equal(t, secretKey[len(secretKey)-4:], apiKey.SecretLast4)
+
+	// Test case 3: every pair is different
+	_, otherSecretKey, err := newAPIKey(1, db.APIKeyModeTest)
+	assert.NoError(t, err)
+	assert.NotEqual(t, secretKey, otherSecretKey)
+}
+
+func TestAPIKeyActive(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Minute), now.Add(time.Minute)
+
+	assert.Equal(t, true, db.APIKey{}.Active(now))
+	assert.Equal(t, true, db.APIKey{ExpiresAt: &future}.Active(now))
+	assert.Equal(t, false, db.APIKey{ExpiresAt: &past}.Active(now))
+	assert.Equal(t, false, db.APIKey{RevokedAt: &past}.Active(now))
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"checkout-task/logger"
+	db "checkout-task/models/db"
+	"checkout-task/utils"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var ErrBINNotFound = errors.New("bin not found")
+
+var binPattern = regexp.MustCompile("^[0-9]{6}([0-9]{2})?$")
+
+// binColumns are the columns of the BIN file, in this order
+var binColumns = []string{"bin", "brand", "issuer", "country", "card_type", "commercial"}
+
+var countryPattern = regexp.MustCompile("^[A-Z]{2}$")
+
+// ParseBINs reads a BIN file: a CSV header with binColumns, then one 6 or 8 digit BIN per row
+// with its brand, issuer, ISO 3166 alpha-2 country, card type and whether it is a commercial card
+func ParseBINs(r io.Reader) ([]db.BIN, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(binColumns)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("bin file: %w", err)
+	}
+	if strings.Join(header, ",") != strings.Join(binColumns, ",") {
+		return nil, fmt.Errorf("bin file: header must be %s", strings.Join(binColumns, ","))
+	}
+
+	var bins []db.BIN
+	seen := map[string]bool{}
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bin file: %w", err)
+		}
+
+		bin := db.BIN{
+			BIN:      record[0],
+			Brand:    record[1],
+			Issuer:   record[2],
+			Country:  strings.ToUpper(record[3]),
+			CardType: strings.ToLower(record[4]),
+		}
+		if !binPattern.MatchString(bin.BIN) {
+			return nil, fmt.Errorf("bin file line %d: bin must be 6 or 8 digits", line)
+		}
+		if seen[bin.BIN] {
+			return nil, fmt.Errorf("bin file line %d: bin %s is listed twice", line, bin.BIN)
+		}
+		seen[bin.BIN] = true
+		if bin.Brand == "" {
+			bin.Brand = utils.DetectBrand(bin.BIN)
+		}
+		if !countryPattern.MatchString(bin.Country) {
+			return nil, fmt.Errorf("bin file line %d: country must be an ISO 3166 alpha-2 code", line)
+		}
+		if bin.CardType != db.CardTypeCredit && bin.CardType != db.CardTypeDebit && bin.CardType != db.CardTypePrepaid {
+			return nil, fmt.Errorf("bin file line %d: card_type must be credit, debit or prepaid", line)
+		}
+		if bin.Commercial, err = strconv.ParseBool(record[5]); err != nil {
+			return nil, fmt.Errorf("bin file line %d: commercial must be true or false", line)
+		}
+
+		bins = append(bins, bin)
+	}
+
+	return bins, nil
+}
+
+// RefreshBINs replaces the BIN table with the content of the BIN file, in one transaction so
+// lookups never see a partly loaded table
+func RefreshBINs(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	bins, err := ParseBINs(file)
+	if err != nil {
+		return 0, err
+	}
+
+	tx := DbConnection.Begin()
+	if err := tx.Delete(&db.BIN{}).Error; err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	for i := range bins {
+		if err := tx.Create(&bins[i]).Error; err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return 0, err
+	}
+
+	logger.Info("BIN table refreshed", zap.String("file", path), zap.Int("count", len(bins)))
+	return len(bins), nil
+}
+
+// LoadBINs fills an empty BIN table from BIN_FILE, a table loaded before is only replaced by refresh-bins
+func LoadBINs() {
+	var count int
+	if err := DbConnection.Model(&db.BIN{}).Count(&count).Error; err != nil {
+		logger.Error("failed to count BINs", zap.Error(err))
+		return
+	}
+	if count > 0 {
+		return
+	}
+	if _, err := RefreshBINs(Config.BINFile); err != nil {
+		logger.Error("failed to load the BIN table", zap.Error(err), zap.String("file", Config.BINFile))
+	}
+}
+
+// LookupBIN returns the attributes of the longest BIN the card number starts with, an 8 digit BIN
+// wins over the 6 digit BIN containing it. Only unknown BINs are ErrBINNotFound.
+func LookupBIN(cardNumber string) (db.BIN, error) {
+	cardNumber = utils.NormalizeCardNumber(cardNumber)
+	if len(cardNumber) < 6 {
+		return db.BIN{}, ErrBINNotFound
+	}
+	prefixes := []string{cardNumber[:6]}
+	if len(cardNumber) >= 8 {
+		prefixes = append(prefixes, cardNumber[:8])
+	}
+
+	var bin db.BIN
+	if err := DbConnection.Where("bin IN (?)", prefixes).Order("length(bin) DESC").First(&bin).Error; err != nil {
+		if gorm.IsRecordNotFoundError(err) {
+			return db.BIN{}, ErrBINNotFound
+		}
+		logger.Error("failed to look up the card BIN", zap.Error(err))
+		return db.BIN{}, fmt.Errorf("failed to look up bin")
+	}
+	return bin, nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	db "checkout-task/models/db"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseBINs(t *testing.T) {
+	// Test case 1: rows are read with their attributes, a missing brand is detected from the BIN
+	bins, err := ParseBINs(strings.NewReader("bin,brand,issuer,country,card_type,commercial\n" +
+		"424242,visa,Simulated Bank,US,credit,false\n" +
+		"37144963,,Simulated Amex,us,Credit,true\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, []db.BIN{
+		{BIN: "424242", Brand: "visa", Issuer: "Simulated Bank", Country: "US", CardType: db.CardTypeCredit},
+		{BIN: "37144963", Brand: "amex", Issuer: "Simulated Amex", Country: "US", CardType: db.CardTypeCredit, Commercial: true},
+	}, bins)
+
+	// Test case 2: invalid rows are reported with their line
+	for _, row := range []string{
+		"42424,visa,Simulated Bank,US,credit,false",
+		"4242424,visa,Simulated Bank,US,credit,false",
+		"424242,visa,Simulated Bank,USA,credit,false",
+		"424242,visa,Simulated Bank,US,charge,false",
+		"424242,visa,Simulated Bank,US,credit,maybe",
+		"424242,visa,Simulated Bank,US,credit",
+	} {
+		_, err := ParseBINs(strings.NewReader("bin,brand,issuer,country,card_type,commercial\n" + row + "\n"))
+		assert.Error(t, err, row)
+	}
+
+	// Test case 3: a BIN listed twice and a wrong header are rejected
+	_, err = ParseBINs(strings.NewReader("bin,brand,issuer,country,card_type,commercial\n" +
+		"424242,visa,Simulated Bank,US,credit,false\n" +
+		"424242,visa,Simulated Bank,US,debit,false\n"))
+	assert.Error(t, err)
+	_, err = ParseBINs(strings.NewReader("bin,country\n424242,US\n"))
+	assert.Error(t, err)
+}
+
+func TestParseBINFile(t *testing.T) {
+	file, err := os.Open("../data/bins.csv")
+	assert.NoError(t, err)
+	defer file.Close()
+
+	bins, err := ParseBINs(file)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, bins)
+}
@@ -12,6 +12,12 @@ func LoadConfig() {
 	v.AutomaticEnv()
 	v.SetDefault("SERVER_PORT", "8080")
 	v.SetDefault("MODE", "debug")
+	v.SetDefault("IDEMPOTENCY_KEY_TTL_HOURS", 24)
+	v.SetDefault("BANK_URL", "http://localhost:8081")
+	v.SetDefault("TEST_BANK_URL", "http://localhost:8081")
+	v.SetDefault("BANK_TIMEOUT_MS", 5000)
+	v.SetDefault("BIN_FILE", "data/bins.csv")
+	v.SetDefault("EXPORT_DIR", "exports")
 	v.SetConfigType("dotenv")
 	v.SetConfigName(".env.local")
 	v.AddConfigPath("./")
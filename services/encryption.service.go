@@ -0,0 +1,197 @@
+package services
+
+import (
+	"checkout-task/logger"
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"checkout-task/vault"
+	"fmt"
+	"go.uber.org/zap"
+	"time"
+)
+
+// encryptionBatchSize is how many rows are encrypted per transaction
+const encryptionBatchSize = 500
+
+// EncryptedColumns are the columns of the gateway sealed by the vault
+var EncryptedColumns = []vault.Column{
+	{Table: "payments", Name: "card_number"},
+	{Table: "vault_cards", Name: "encrypted_card_number"},
+	{Table: "webhook_endpoints", Name: "secret"},
+}
+
+// RotateVaultKeys moves card data sealed under older keys to the active key, looking for
+// such rows again every interval
+func RotateVaultKeys(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for {
+		if err := Vault.RotateColumns(DbConnection, EncryptedColumns, encryptionBatchSize); err != nil {
+			logger.Error("failed to re-encrypt card data", zap.Error(err))
+		}
+		<-ticker.C
+	}
+}
+
+// VaultKeysStatus reports for every configured key how many rows of the gateway depend on it,
+// and how many rows are still waiting to be re-encrypted under the active key
+func VaultKeysStatus() (models.VaultStatus, error) {
+	status := models.VaultStatus{ActiveKeyID: Vault.ActiveKeyID()}
+	for _, column := range EncryptedColumns {
+		pending, err := Vault.Pending(DbConnection, column)
+		if err != nil {
+			logger.Error("failed to count rows to re-encrypt", zap.Error(err))
+			return models.VaultStatus{}, fmt.Errorf("failed to retrieve vault status")
+		}
+		status.PendingRotation += pending
+	}
+
+	for _, keyID := range Vault.KeyIDs() {
+		usage, err := KeyUsage(keyID)
+		if err != nil {
+			logger.Error("failed to count rows by key", zap.Error(err))
+			return models.VaultStatus{}, fmt.Errorf("failed to retrieve vault status")
+		}
+		status.Keys = append(status.Keys, models.VaultKeyStatus{
+			KeyID:  keyID,
+			Active: keyID == Vault.ActiveKeyID(),
+			Rows:   usage,
+		})
+	}
+
+	return status, nil
+}
+
+// KeyUsage counts by column the rows of the gateway that depend on the key
+func KeyUsage(keyID string) (map[string]int, error) {
+	usage := map[string]int{}
+	for _, column := range EncryptedColumns {
+		count, err := vault.CountByKey(DbConnection, column, keyID)
+		if err != nil {
+			return nil, err
+		}
+		usage[column.String()] = count
+	}
+	return usage, nil
+}
+
+// RefreshFingerprints recomputes the fingerprints of the vault cards with the configured fingerprint key
+func RefreshFingerprints() error {
+	column := vault.FingerprintColumn{Table: "vault_cards", CardNumber: "encrypted_card_number", Fingerprint: "fingerprint"}
+	changed, err := Vault.Refingerprint(DbConnection, column, encryptionBatchSize)
+	if err != nil {
+		return err
+	}
+	logger.Info("Recomputed card fingerprints", zap.String("table", column.Table), zap.Int("rows", changed))
+	return nil
+}
+
+// RetireVaultKey records that no row of the gateway depends on the key any more, the vault refuses it from then on.
+// Keys still sealing a row are refused.
+func RetireVaultKey(keyID string) error {
+	if keyID == Vault.ActiveKeyID() {
+		return fmt.Errorf("key %s is the active key, rotate to another key first", keyID)
+	}
+
+	usage, err := KeyUsage(keyID)
+	if err != nil {
+		return err
+	}
+	for column, count := range usage {
+		if count > 0 {
+			return fmt.Errorf("key %s cannot be retired, %d rows of %s still depend on it", keyID, count, column)
+		}
+	}
+
+	retiredKey := db.RetiredVaultKey{KeyID: keyID, RetiredAt: time.Now()}
+	return DbConnection.Where(db.RetiredVaultKey{KeyID: keyID}).FirstOrCreate(&retiredKey).Error
+}
+
+// EncryptCardData encrypts the card numbers payments stored in plaintext before the vault and moves
+// vault cards sealed directly under the key to envelope encryption. It can be run again safely.
+func EncryptCardData() error {
+	payments, err := encryptPayments()
+	if err != nil {
+		return err
+	}
+	logger.Info("Encrypted payment card numbers", zap.Int("rows", payments))
+
+	vaultCards, err := encryptVaultCards()
+	if err != nil {
+		return err
+	}
+	logger.Info("Encrypted vault card numbers", zap.Int("rows", vaultCards))
+
+	return nil
+}
+
+func encryptPayments() (int, error) {
+	total := 0
+	for {
+		var payments []db.Payment
+		if err := DbConnection.Where("card_number <> '' AND card_number NOT LIKE 'env:%'").
+			Order("id asc").Limit(encryptionBatchSize).Find(&payments).Error; err != nil {
+			return total, fmt.Errorf("cannot read payments: %w", err)
+		}
+		if len(payments) == 0 {
+			return total, nil
+		}
+
+		tx := DbConnection.Begin()
+		for _, payment := range payments {
+			encrypted, err := Vault.Encrypt(payment.CardNumber)
+			if err != nil {
+				tx.Rollback()
+				return total, fmt.Errorf("cannot encrypt payment %d: %w", payment.ID, err)
+			}
+
+			update := map[string]interface{}{"card_number": encrypted}
+			if payment.CardLast4 == "" && len(payment.CardNumber) > 4 {
+				update["card_last4"] = payment.CardNumber[len(payment.CardNumber)-4:]
+			}
+			if err := tx.Model(&db.Payment{}).Where("id = ?", payment.ID).UpdateColumns(update).Error; err != nil {
+				tx.Rollback()
+				return total, fmt.Errorf("cannot update payment %d: %w", payment.ID, err)
+			}
+		}
+		if err := tx.Commit().Error; err != nil {
+			return total, err
+		}
+		total += len(payments)
+	}
+}
+
+func encryptVaultCards() (int, error) {
+	total := 0
+	for {
+		var vaultCards []db.VaultCard
+		if err := DbConnection.Where("encrypted_card_number NOT LIKE 'env:%'").
+			Order("id asc").Limit(encryptionBatchSize).Find(&vaultCards).Error; err != nil {
+			return total, fmt.Errorf("cannot read vault cards: %w", err)
+		}
+		if len(vaultCards) == 0 {
+			return total, nil
+		}
+
+		tx := DbConnection.Begin()
+		for _, vaultCard := range vaultCards {
+			cardNumber, err := Vault.Decrypt(vaultCard.EncryptedCardNumber)
+			if err != nil {
+				tx.Rollback()
+				return total, fmt.Errorf("cannot decrypt vault card %d: %w", vaultCard.ID, err)
+			}
+			encrypted, err := Vault.Encrypt(cardNumber)
+			if err != nil {
+				tx.Rollback()
+				return total, fmt.Errorf("cannot encrypt vault card %d: %w", vaultCard.ID, err)
+			}
+			if err := tx.Model(&db.VaultCard{}).Where("id = ?", vaultCard.ID).UpdateColumn("encrypted_card_number", encrypted).Error; err != nil {
+				tx.Rollback()
+				return total, fmt.Errorf("cannot update vault card %d: %w", vaultCard.ID, err)
+			}
+		}
+		if err := tx.Commit().Error; err != nil {
+			return total, err
+		}
+		total += len(vaultCards)
+	}
+}
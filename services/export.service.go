@@ -0,0 +1,278 @@
+package services
+
+import (
+	"bufio"
+	"checkout-task/logger"
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrExportNotFound = errors.New("export not found")
+	ErrExportNotReady = errors.New("export is not completed")
+)
+
+// exportColumns are the columns of CSV exports, amounts are in minor units and card numbers masked
+var exportColumns = []string{
+	"payment_id", "reference", "description", "created_at", "updated_at", "status", "amount", "refunded_amount", "currency",
+	"masked_card_number", "brand", "issuer_country", "card_type", "commercial", "decline_code", "decline_category",
+}
+
+// exportWriter writes payments to an export file one at a time
+type exportWriter interface {
+	Write(payment models.PaymentResponse) error
+	Flush() error
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvExportWriter) Write(payment models.PaymentResponse) error {
+	return w.writer.Write([]string{
+		payment.PaymentID,
+		payment.Reference,
+		payment.Description,
+		payment.CreatedAt.UTC().Format(time.RFC3339),
+		payment.UpdatedAt.UTC().Format(time.RFC3339),
+		payment.Status,
+		strconv.FormatInt(payment.Amount, 10),
+		strconv.FormatInt(payment.RefundedAmount, 10),
+		payment.Currency,
+		payment.MaskedCardNumber,
+		payment.Brand,
+		payment.IssuerCountry,
+		payment.CardType,
+		strconv.FormatBool(payment.Commercial),
+		payment.DeclineCode,
+		payment.DeclineCategory,
+	})
+}
+
+func (w *csvExportWriter) Flush() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonExportWriter) Write(payment models.PaymentResponse) error {
+	return w.encoder.Encode(payment)
+}
+
+func (w *ndjsonExportWriter) Flush() error {
+	return nil
+}
+
+// newExportWriter returns the writer of the format, CSV files start with their header
+func newExportWriter(format string, out io.Writer) (exportWriter, error) {
+	if format == db.ExportFormatNDJSON {
+		return &ndjsonExportWriter{encoder: json.NewEncoder(out)}, nil
+	}
+	writer := csv.NewWriter(out)
+	if err := writer.Write(exportColumns); err != nil {
+		return nil, err
+	}
+	return &csvExportWriter{writer: writer}, nil
+}
+
+// CreateExport records an export of the merchant's payments in the mode, the file is written in the background by ProcessExports
+func CreateExport(merchantID int64, mode string, request models.ExportRequest) (models.ExportResponse, error) {
+	filters, err := json.Marshal(request.Filters)
+	if err != nil {
+		return models.ExportResponse{}, err
+	}
+
+	export := db.Export{
+		ExportID:   uuid.New().String(),
+		MerchantID: merchantID,
+		Mode:       mode,
+		Format:     request.Format,
+		Filters:    string(filters),
+		Status:     db.ExportStatusPending,
+	}
+	if err := DbConnection.Create(&export).Error; err != nil {
+		logger.Error("failed to store export", zap.Error(err))
+		return models.ExportResponse{}, fmt.Errorf("failed to create export")
+	}
+
+	return exportResponse(export), nil
+}
+
+// GetExport returns an export of the merchant made in the mode
+func GetExport(merchantID int64, mode string, exportID string) (models.ExportResponse, error) {
+	var export db.Export
+	if err := DbConnection.Where("export_id = ? AND merchant_id = ? AND mode = ?", exportID, merchantID, mode).First(&export).Error; err != nil {
+		return models.ExportResponse{}, ErrExportNotFound
+	}
+	return exportResponse(export), nil
+}
+
+// ExportFile returns the path and file name of a completed export of the merchant made in the mode
+func ExportFile(merchantID int64, mode string, exportID string) (string, string, error) {
+	var export db.Export
+	if err := DbConnection.Where("export_id = ? AND merchant_id = ? AND mode = ?", exportID, merchantID, mode).First(&export).Error; err != nil {
+		return "", "", ErrExportNotFound
+	}
+	if export.Status != db.ExportStatusCompleted {
+		return "", "", ErrExportNotReady
+	}
+	return export.FilePath, "payments-" + export.ExportID + "." + export.Format, nil
+}
+
+func exportResponse(export db.Export) models.ExportResponse {
+	response := models.ExportResponse{
+		ExportID:    export.ExportID,
+		Format:      export.Format,
+		Status:      export.Status,
+		RowCount:    export.RowCount,
+		Error:       export.Error,
+		CreatedAt:   export.CreatedAt,
+		CompletedAt: export.CompletedAt,
+	}
+	if export.Status == db.ExportStatusCompleted {
+		response.DownloadURL = "/v1/exports/" + export.ExportID + "/download"
+	}
+	return response
+}
+
+// ProcessExports writes the pending exports every interval. Exports that were running when the
+// gateway stopped are started over.
+func ProcessExports(interval time.Duration) {
+	if err := DbConnection.Model(&db.Export{}).Where("status = ?", db.ExportStatusRunning).
+		UpdateColumn("status", db.ExportStatusPending).Error; err != nil {
+		logger.Error("failed to restart running exports", zap.Error(err))
+	}
+
+	ticker := time.NewTicker(interval)
+	for {
+		for {
+			export, ok := claimExport()
+			if !ok {
+				break
+			}
+			runExport(export)
+		}
+		<-ticker.C
+	}
+}
+
+// claimExport moves the oldest pending export to running, unless another worker claimed it first
+func claimExport() (db.Export, bool) {
+	var export db.Export
+	if err := DbConnection.Where("status = ?", db.ExportStatusPending).Order("id asc").First(&export).Error; err != nil {
+		return db.Export{}, false
+	}
+
+	result := DbConnection.Model(&db.Export{}).
+		Where("id = ? AND status = ?", export.ID, db.ExportStatusPending).
+		UpdateColumn("status", db.ExportStatusRunning)
+	if result.Error != nil {
+		logger.Error("failed to claim export", zap.Error(result.Error), zap.String("export_id", export.ExportID))
+		return db.Export{}, false
+	}
+	if result.RowsAffected == 0 {
+		return claimExport()
+	}
+
+	export.Status = db.ExportStatusRunning
+	return export, true
+}
+
+// runExport writes the file of an export and records the outcome
+func runExport(export db.Export) {
+	path := filepath.Join(Config.ExportDir, export.ExportID+"."+export.Format)
+	rowCount, err := writeExport(export, path)
+
+	now := time.Now()
+	update := map[string]interface{}{
+		"status":       db.ExportStatusCompleted,
+		"row_count":    rowCount,
+		"file_path":    path,
+		"completed_at": now,
+	}
+	if err != nil {
+		logger.Error("failed to write export", zap.Error(err), zap.String("export_id", export.ExportID))
+		update["status"] = db.ExportStatusFailed
+		update["file_path"] = ""
+		update["error"] = "failed to write export"
+	}
+
+	if err := DbConnection.Model(&db.Export{}).Where("id = ?", export.ID).UpdateColumns(update).Error; err != nil {
+		logger.Error("failed to store export status", zap.Error(err), zap.String("export_id", export.ExportID))
+		return
+	}
+	logger.Info("Export finished", zap.String("export_id", export.ExportID), zap.Any("status", update["status"]), zap.Int64("rows", rowCount))
+}
+
+// writeExport streams the payments of the export into path row by row, so exports of any size use
+// little memory. The file only appears under path once it is complete.
+func writeExport(export db.Export, path string) (int64, error) {
+	var filters models.PaymentListRequest
+	if err := json.Unmarshal([]byte(export.Filters), &filters); err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return 0, err
+	}
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o640)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmpPath)
+	defer file.Close()
+
+	buffered := bufio.NewWriter(file)
+	writer, err := newExportWriter(export.Format, buffered)
+	if err != nil {
+		return 0, err
+	}
+
+	orderBy, _ := paymentOrder(filters.Order)
+	rows, err := filterPayments(DbConnection.Model(&db.Payment{}), export.MerchantID, export.Mode, filters).Order(orderBy).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var rowCount int64
+	for rows.Next() {
+		var payment db.Payment
+		if err := DbConnection.ScanRows(rows, &payment); err != nil {
+			return rowCount, err
+		}
+		if err := writer.Write(paymentResponse(payment)); err != nil {
+			return rowCount, err
+		}
+		rowCount++
+	}
+	if err := rows.Err(); err != nil {
+		return rowCount, err
+	}
+
+	if err := writer.Flush(); err != nil {
+		return rowCount, err
+	}
+	if err := buffered.Flush(); err != nil {
+		return rowCount, err
+	}
+	if err := file.Close(); err != nil {
+		return rowCount, err
+	}
+	return rowCount, os.Rename(tmpPath, path)
+}
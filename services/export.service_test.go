@@ -0,0 +1,57 @@
+package services
+
+import (
+	"bytes"
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportWriters(t *testing.T) {
+	payment := models.PaymentResponse{
+		PaymentID:        "de5f78db-c618-433b-b3f5-23c8a2519ea6",
+		Reference:        "order, 1001",
+		Description:      "2 tickets",
+		MaskedCardNumber: "**** **** **** 4242",
+		Brand:            "visa",
+		Amount:           3400,
+		Currency:         "USD",
+		Status:           "Captured",
+		CreatedAt:        time.Date(2026, 10, 18, 9, 12, 44, 0, time.UTC),
+		UpdatedAt:        time.Date(2026, 10, 18, 9, 12, 45, 0, time.UTC),
+	}
+
+	// Test case 1: CSV files start with a header and quote values where needed
+	var out bytes.Buffer
+	writer, err := newExportWriter(db.ExportFormatCSV, &out)
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Write(payment))
+	assert.NoError(t, writer.Flush())
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, strings.Join(exportColumns, ","), lines[0])
+	assert.Equal(t, `de5f78db-c618-433b-b3f5-23c8a2519ea6,"order, 1001",2 tickets,2026-10-18T09:12:44Z,2026-10-18T09:12:45Z,Captured,3400,0,USD,**** **** **** 4242,visa,,,false,,`, lines[1])
+
+	// Test case 2: NDJSON files hold one payment per line
+	out.Reset()
+	writer, err = newExportWriter(db.ExportFormatNDJSON, &out)
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Write(payment))
+	assert.NoError(t, writer.Write(payment))
+	assert.NoError(t, writer.Flush())
+
+	lines = strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"masked_card_number":"**** **** **** 4242"`)
+	assert.NotContains(t, out.String(), "4242424242424242")
+}
+
+func TestExportResponse(t *testing.T) {
+	// Test case 1: only completed exports can be downloaded
+	assert.Empty(t, exportResponse(db.Export{ExportID: "1", Status: db.ExportStatusRunning}).DownloadURL)
+	assert.Equal(t, "/v1/exports/1/download", exportResponse(db.Export{ExportID: "1", Status: db.ExportStatusCompleted}).DownloadURL)
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"checkout-task/logger"
+	db "checkout-task/models/db"
+	"errors"
+	"go.uber.org/zap"
+	"time"
+)
+
+var (
+	ErrIdempotencyKeyReused     = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+// BeginIdempotentRequest claims the key for the request identified by requestHash.
+// A record with a non-zero ResponseCode holds the stored response of an earlier identical request.
+func BeginIdempotentRequest(scope string, key string, requestHash string) (*db.IdempotencyKey, error) {
+	now := time.Now()
+
+	// Expired keys can be reused for any request
+	if err := DbConnection.Where("scope = ? AND idempotency_key = ? AND expires_at < ?", scope, key, now).Delete(&db.IdempotencyKey{}).Error; err != nil {
+		return nil, err
+	}
+
+	record := &db.IdempotencyKey{
+		Scope:       scope,
+		Key:         key,
+		RequestHash: requestHash,
+		ExpiresAt:   now.Add(time.Duration(Config.IdempotencyKeyTTLHours) * time.Hour),
+	}
+	createErr := DbConnection.Create(record).Error
+	if createErr == nil {
+		return record, nil
+	}
+
+	// The unique index rejected the insert, so look at the request that claimed the key first
+	existing := &db.IdempotencyKey{}
+	if err := DbConnection.Where("scope = ? AND idempotency_key = ?", scope, key).First(existing).Error; err != nil {
+		return nil, createErr
+	}
+
+	if existing.RequestHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if existing.ResponseCode == 0 {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+
+	return existing, nil
+}
+
+// CompleteIdempotentRequest stores the response so replays of the request receive it again.
+// Server errors release the key instead, letting the merchant retry.
+func CompleteIdempotentRequest(record *db.IdempotencyKey, responseCode int, responseBody []byte) {
+	var err error
+	if responseCode >= 500 {
+		err = DbConnection.Delete(record).Error
+	} else {
+		err = DbConnection.Model(record).UpdateColumns(map[string]interface{}{
+			"response_code": responseCode,
+			"response_body": responseBody,
+		}).Error
+	}
+
+	if err != nil {
+		logger.Error("failed to store idempotent response", zap.Error(err), zap.String("key", record.Key))
+	}
+}
+
+// PurgeExpiredIdempotencyKeys deletes expired keys every interval
+func PurgeExpiredIdempotencyKeys(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := DbConnection.Where("expires_at < ?", time.Now()).Delete(&db.IdempotencyKey{}).Error; err != nil {
+			logger.Error("failed to purge expired idempotency keys", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package services
+
+import (
+	"checkout-task/logger"
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+	"sort"
+)
+
+// ledgerLine is one side of a ledger transaction waiting to be posted
+type ledgerLine struct {
+	Account   string
+	Direction string
+	Amount    int64
+}
+
+func debit(account string, amount int64) ledgerLine {
+	return ledgerLine{Account: account, Direction: db.Debit, Amount: amount}
+}
+
+func credit(account string, amount int64) ledgerLine {
+	return ledgerLine{Account: account, Direction: db.Credit, Amount: amount}
+}
+
+// checkBalanced makes sure the debits of a transaction add up to its credits and every amount is positive
+func checkBalanced(lines []ledgerLine) error {
+	var debits, credits int64
+	for _, line := range lines {
+		if line.Amount <= 0 {
+			return fmt.Errorf("ledger amounts must be positive")
+		}
+		switch line.Direction {
+		case db.Debit:
+			debits += line.Amount
+		case db.Credit:
+			credits += line.Amount
+		default:
+			return fmt.Errorf("unknown ledger direction %q", line.Direction)
+		}
+	}
+	if len(lines) == 0 || debits != credits {
+		return fmt.Errorf("ledger transaction is not balanced: %d debited, %d credited", debits, credits)
+	}
+	return nil
+}
+
+// creditNormal reports whether credits raise the balance of the account type. Acquirer clearing is money
+// owed to the gateway and grows with debits, the other accounts are owed by the gateway or earned by it.
+func creditNormal(accountType string) bool {
+	return accountType != db.AccountAcquirerClearing
+}
+
+// ledgerAccount returns the account of the merchant, opening it on first use
+func ledgerAccount(tx *gorm.DB, merchantID int64, accountType string, currency string) (db.LedgerAccount, error) {
+	if err := tx.Exec(`INSERT INTO ledger_accounts (merchant_id, type, currency, created_at) VALUES (?, ?, ?, NOW())
+		ON CONFLICT (merchant_id, type, currency) DO NOTHING`, merchantID, accountType, currency).Error; err != nil {
+		return db.LedgerAccount{}, err
+	}
+
+	var account db.LedgerAccount
+	err := tx.Where("merchant_id = ? AND type = ? AND currency = ?", merchantID, accountType, currency).First(&account).Error
+	return account, err
+}
+
+// postLedgerTransaction records balanced entries on the caller's transaction. A transaction of the same kind
+// and source is only posted once, posting it again does nothing.
+func postLedgerTransaction(tx *gorm.DB, merchantID int64, currency string, kind string, source string, reference string, lines []ledgerLine) error {
+	if err := checkBalanced(lines); err != nil {
+		return err
+	}
+
+	var posted int
+	if err := tx.Model(&db.LedgerTransaction{}).Where("kind = ? AND source = ?", kind, source).Count(&posted).Error; err != nil {
+		return err
+	}
+	if posted > 0 {
+		return nil
+	}
+
+	transaction := db.LedgerTransaction{
+		TransactionID: uuid.New().String(),
+		MerchantID:    merchantID,
+		Kind:          kind,
+		Source:        source,
+		Reference:     reference,
+		Currency:      currency,
+	}
+	if err := tx.Create(&transaction).Error; err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		account, err := ledgerAccount(tx, merchantID, line.Account, currency)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(&db.LedgerEntry{
+			TransactionID: transaction.ID,
+			AccountID:     account.ID,
+			Direction:     line.Direction,
+			Amount:        line.Amount,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postCaptureLedger posts a capture on the transaction that moves the payment to Captured: the amount is owed
+// to the merchant until it is settled, less the fee of the payment. Test payments move no money and are left out.
+func postCaptureLedger(tx *gorm.DB, payment db.Payment) error {
+	if payment.Mode == db.APIKeyModeTest {
+		return nil
+	}
+	if err := postLedgerTransaction(tx, payment.MerchantID, payment.Currency, db.LedgerCapture, payment.PaymentID, payment.PaymentID, []ledgerLine{
+		debit(db.AccountAcquirerClearing, payment.Amount),
+		credit(db.AccountMerchantPending, payment.Amount),
+	}); err != nil {
+		return err
+	}
+	if payment.Fee == 0 {
+		return nil
+	}
+	return postLedgerTransaction(tx, payment.MerchantID, payment.Currency, db.LedgerFee, payment.PaymentID, payment.PaymentID, []ledgerLine{
+		debit(db.AccountMerchantPending, payment.Fee),
+		credit(db.AccountGatewayFees, payment.Fee),
+	})
+}
+
+// postRefundLedger posts a succeeded refund on the transaction that records it. Refunds of payments that are
+// not settled yet come out of the merchant's pending funds, refunds of settled payments out of the available
+// ones. The caller holds the lock of the payment, so settlement cannot claim it meanwhile.
+func postRefundLedger(tx *gorm.DB, payment db.Payment, refund db.Refund) error {
+	if payment.Mode == db.APIKeyModeTest {
+		return nil
+	}
+	account := db.AccountMerchantPending
+	if payment.SettlementBatchID != 0 {
+		account = db.AccountMerchantAvailable
+	}
+	return postLedgerTransaction(tx, payment.MerchantID, refund.Currency, db.LedgerRefund, refund.RefundID, refund.RefundID, []ledgerLine{
+		debit(account, refund.Amount),
+		credit(db.AccountRefunds, refund.Amount),
+	})
+}
+
+// GetBalances returns the pending and available funds of the merchant per currency, or only in currency if given
+func GetBalances(merchantID int64, currency string) ([]models.BalanceResponse, error) {
+	query := DbConnection.Table("ledger_accounts").
+		Select(`ledger_accounts.currency, ledger_accounts.type,
+			COALESCE(SUM(CASE WHEN ledger_entries.direction = ? THEN ledger_entries.amount ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN ledger_entries.direction = ? THEN ledger_entries.amount ELSE 0 END), 0)`, db.Debit, db.Credit).
+		Joins("LEFT JOIN ledger_entries ON ledger_entries.account_id = ledger_accounts.id").
+		Where("ledger_accounts.merchant_id = ? AND ledger_accounts.type IN (?)", merchantID, []string{db.AccountMerchantPending, db.AccountMerchantAvailable})
+	if currency != "" {
+		query = query.Where("ledger_accounts.currency = ?", currency)
+	}
+
+	rows, err := query.Group("ledger_accounts.currency, ledger_accounts.type").Rows()
+	if err != nil {
+		logger.Error("failed to retrieve balances", zap.Error(err))
+		return nil, fmt.Errorf("failed to retrieve balances")
+	}
+	defer rows.Close()
+
+	balances := map[string]*models.BalanceResponse{}
+	for rows.Next() {
+		var accountCurrency, accountType string
+		var debits, credits int64
+		if err := rows.Scan(&accountCurrency, &accountType, &debits, &credits); err != nil {
+			logger.Error("failed to retrieve balances", zap.Error(err))
+			return nil, fmt.Errorf("failed to retrieve balances")
+		}
+
+		balance, ok := balances[accountCurrency]
+		if !ok {
+			balance = &models.BalanceResponse{Currency: accountCurrency}
+			balances[accountCurrency] = balance
+		}
+		amount := accountBalance(accountType, debits, credits)
+		if accountType == db.AccountMerchantPending {
+			balance.Pending = amount
+		} else {
+			balance.Available = amount
+		}
+	}
+
+	responses := make([]models.BalanceResponse, 0, len(balances))
+	for _, balance := range balances {
+		responses = append(responses, *balance)
+	}
+	sort.Slice(responses, func(i, j int) bool { return responses[i].Currency < responses[j].Currency })
+	return responses, nil
+}
+
+// accountBalance is the balance of an account of the type from the sums of its debits and credits
+func accountBalance(accountType string, debits int64, credits int64) int64 {
+	if creditNormal(accountType) {
+		return credits - debits
+	}
+	return debits - credits
+}
+
+// ledgerBalance returns the balance of an account of the merchant, on the caller's transaction
+func ledgerBalance(tx *gorm.DB, merchantID int64, accountType string, currency string) (int64, error) {
+	row := tx.Table("ledger_entries").
+		Select(`COALESCE(SUM(CASE WHEN ledger_entries.direction = ? THEN ledger_entries.amount ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN ledger_entries.direction = ? THEN ledger_entries.amount ELSE 0 END), 0)`, db.Debit, db.Credit).
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_entries.account_id").
+		Where("ledger_accounts.merchant_id = ? AND ledger_accounts.type = ? AND ledger_accounts.currency = ?", merchantID, accountType, currency).
+		Row()
+
+	var debits, credits int64
+	if err := row.Scan(&debits, &credits); err != nil {
+		return 0, err
+	}
+	return accountBalance(accountType, debits, credits), nil
+}
+
+// ledgerDebited returns how much the transactions of the kind with the references debited from an account of
+// the merchant, on the caller's transaction
+func ledgerDebited(tx *gorm.DB, merchantID int64, accountType string, currency string, kind string, references []string) (int64, error) {
+	if len(references) == 0 {
+		return 0, nil
+	}
+	row := tx.Table("ledger_entries").
+		Select("COALESCE(SUM(ledger_entries.amount), 0)").
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_entries.account_id").
+		Joins("JOIN ledger_transactions ON ledger_transactions.id = ledger_entries.transaction_id").
+		Where("ledger_accounts.merchant_id = ? AND ledger_accounts.type = ? AND ledger_accounts.currency = ?", merchantID, accountType, currency).
+		Where("ledger_entries.direction = ? AND ledger_transactions.kind = ? AND ledger_transactions.reference IN (?)", db.Debit, kind, references).
+		Row()
+
+	var debited int64
+	if err := row.Scan(&debited); err != nil {
+		return 0, err
+	}
+	return debited, nil
+}
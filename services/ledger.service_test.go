@@ -0,0 +1,39 @@
+package services
+
+import (
+	db "checkout-task/models/db"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCheckBalanced(t *testing.T) {
+	// Test case 1: a capture debits and credits the same amount
+	assert.NoError(t, checkBalanced([]ledgerLine{
+		debit(db.AccountAcquirerClearing, 1050),
+		credit(db.AccountMerchantPending, 1050),
+	}))
+
+	// Test case 2: several lines may balance each other
+	assert.NoError(t, checkBalanced([]ledgerLine{
+		debit(db.AccountMerchantPending, 1000),
+		credit(db.AccountMerchantAvailable, 970),
+		credit(db.AccountGatewayFees, 30),
+	}))
+
+	// Test case 3: unbalanced, empty and non-positive transactions are refused
+	assert.Error(t, checkBalanced([]ledgerLine{
+		debit(db.AccountAcquirerClearing, 1050),
+		credit(db.AccountMerchantPending, 1000),
+	}))
+	assert.Error(t, checkBalanced(nil))
+	assert.Error(t, checkBalanced([]ledgerLine{debit(db.AccountRefunds, 0), credit(db.AccountMerchantPending, 0)}))
+	assert.Error(t, checkBalanced([]ledgerLine{debit(db.AccountRefunds, -5), credit(db.AccountMerchantPending, -5)}))
+}
+
+func TestAccountBalance(t *testing.T) {
+	// Test case 1: merchant funds grow with credits
+	assert.Equal(t, int64(750), accountBalance(db.AccountMerchantPending, 300, 1050))
+
+	// Test case 2: the acquirer clearing account grows with debits
+	assert.Equal(t, int64(750), accountBalance(db.AccountAcquirerClearing, 1050, 300))
+}
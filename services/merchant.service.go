@@ -0,0 +1,71 @@
+package services
+
+import (
+	"checkout-task/logger"
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// CreateMerchant registers a merchant, the email is what the merchant requests access tokens with
+func CreateMerchant(request models.MerchantRequest) (db.Merchant, error) {
+	var count int
+	if err := DbConnection.Model(&db.Merchant{}).Where("email = ?", request.Email).Count(&count).Error; err != nil {
+		logger.Error("failed to check merchant email", zap.Error(err))
+		return db.Merchant{}, fmt.Errorf("failed to store merchant details")
+	}
+	if count > 0 {
+		return db.Merchant{}, fmt.Errorf("a merchant with this email already exists")
+	}
+
+	merchant := db.Merchant{
+		MerchantID: uuid.New().String(),
+		Name:       request.Name,
+		Email:      request.Email,
+	}
+	if err := DbConnection.Create(&merchant).Error; err != nil {
+		logger.Error("failed to store merchant details", zap.Error(err))
+		return db.Merchant{}, fmt.Errorf("failed to store merchant details")
+	}
+
+	return merchant, nil
+}
+
+// ListMerchants returns every registered merchant, oldest first
+func ListMerchants() ([]db.Merchant, error) {
+	var merchants []db.Merchant
+	if err := DbConnection.Order("id asc").Find(&merchants).Error; err != nil {
+		logger.Error("failed to retrieve merchants", zap.Error(err))
+		return nil, fmt.Errorf("failed to retrieve merchants")
+	}
+	return merchants, nil
+}
+
+// GetMerchantByEmail finds the merchant registered with the email
+func GetMerchantByEmail(email string) (db.Merchant, error) {
+	var merchant db.Merchant
+	if err := DbConnection.Where("email = ?", email).First(&merchant).Error; err != nil {
+		return db.Merchant{}, fmt.Errorf("no merchant registered with this email")
+	}
+	return merchant, nil
+}
+
+// GetMerchantByMerchantID finds a merchant by its public identifier
+func GetMerchantByMerchantID(merchantID string) (db.Merchant, error) {
+	var merchant db.Merchant
+	if err := DbConnection.Where("merchant_id = ?", merchantID).First(&merchant).Error; err != nil {
+		return db.Merchant{}, fmt.Errorf("merchant not found")
+	}
+	return merchant, nil
+}
+
+// GetMerchant finds a merchant by its internal id
+func GetMerchant(id int64) (db.Merchant, error) {
+	var merchant db.Merchant
+	if err := DbConnection.Where("id = ?", id).First(&merchant).Error; err != nil {
+		return db.Merchant{}, fmt.Errorf("merchant not found")
+	}
+	return merchant, nil
+}
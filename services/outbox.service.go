@@ -0,0 +1,223 @@
+package services
+
+import (
+	"checkout-task/constants"
+	"checkout-task/logger"
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	outboxBatchSize = 100
+	// outboxBaseBackoff is the wait after the first failed publication of an event, it doubles up to maxOutboxBackoff
+	outboxBaseBackoff = 5 * time.Second
+	maxOutboxBackoff  = time.Hour
+)
+
+// outboxConsumer handles the outbox events on the transaction that records its receipt. Consumers only
+// working in the database see every event exactly once, others must drop duplicates by event id.
+type outboxConsumer struct {
+	Name   string
+	Handle func(tx *gorm.DB, event db.OutboxEvent, data outboxData) error
+}
+
+// outboxConsumers are given every event, in the order of this list
+var outboxConsumers = []outboxConsumer{
+	{"webhooks", publishWebhookEvent},
+	{"analytics", recordPaymentStat},
+}
+
+// outboxData is the payload of outbox events, refund events carry the refund next to the payment
+type outboxData struct {
+	Payment models.PaymentResponse `json:"payment"`
+	Refund  *outboxRefund          `json:"refund,omitempty"`
+}
+
+// mode is the mode of the payment, events written before payments had a mode are live
+func (data outboxData) mode() string {
+	if data.Payment.Mode == db.APIKeyModeTest {
+		return db.APIKeyModeTest
+	}
+	return db.APIKeyModeLive
+}
+
+type outboxRefund struct {
+	RefundID  string `json:"refund_id"`
+	PaymentID string `json:"payment_id"`
+	Amount    int64  `json:"amount"`
+	Currency  string `json:"currency"`
+	Status    string `json:"status"`
+}
+
+// paymentEventTypes maps the payment statuses to the type of the event recording the move to them
+var paymentEventTypes = map[string]string{
+	constants.PENDING:           db.EventPaymentCreated,
+	constants.AUTHORIZED:        db.EventPaymentAuthorized,
+	constants.CAPTURED:          db.EventPaymentCaptured,
+	constants.DECLINED:          db.EventPaymentDeclined,
+	constants.FAILED:            db.EventPaymentFailed,
+	constants.VOIDED:            db.EventPaymentVoided,
+	constants.PARTIALLYREFUNDED: db.EventPaymentPartiallyRefunded,
+	constants.REFUNDED:          db.EventPaymentRefunded,
+}
+
+// outboxBackoff is the wait before publishing again an event that failed attempts times
+func outboxBackoff(attempts int) time.Duration {
+	backoff := outboxBaseBackoff
+	for i := 1; i < attempts && backoff < maxOutboxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxOutboxBackoff {
+		return maxOutboxBackoff
+	}
+	return backoff
+}
+
+// writeOutboxEvent records an event of the payment on the caller's transaction, so it is committed
+// together with the change it describes
+func writeOutboxEvent(tx *gorm.DB, payment db.Payment, eventType string, data outboxData) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return tx.Create(&db.OutboxEvent{
+		EventID:       uuid.New().String(),
+		MerchantID:    payment.MerchantID,
+		Type:          eventType,
+		AggregateID:   payment.PaymentID,
+		Payload:       string(payload),
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}).Error
+}
+
+// publishPaymentEvent records the new status of the payment in the outbox
+func publishPaymentEvent(tx *gorm.DB, payment db.Payment) error {
+	eventType, ok := paymentEventTypes[payment.Status]
+	if !ok {
+		return nil
+	}
+	return writeOutboxEvent(tx, payment, eventType, outboxData{Payment: paymentResponse(payment)})
+}
+
+// publishRefundEvent records in the outbox whether a refund of the payment went through
+func publishRefundEvent(tx *gorm.DB, payment db.Payment, refund db.Refund, succeeded bool) error {
+	eventType := db.EventRefundFailed
+	if succeeded {
+		eventType = db.EventRefundSucceeded
+	}
+	return writeOutboxEvent(tx, payment, eventType, outboxData{
+		Payment: paymentResponse(payment),
+		Refund: &outboxRefund{
+			RefundID:  refund.RefundID,
+			PaymentID: payment.PaymentID,
+			Amount:    refund.Amount,
+			Currency:  refund.Currency,
+			Status:    refund.Status,
+		},
+	})
+}
+
+// RelayOutbox publishes the outbox events that are due every interval
+func RelayOutbox(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for {
+		relayOutbox()
+		<-ticker.C
+	}
+}
+
+func relayOutbox() {
+	// Events wait for the earlier events of their payment, so consumers see the changes of a payment in order
+	var events []db.OutboxEvent
+	if err := DbConnection.
+		Where("published_at IS NULL AND next_attempt_at <= ?", time.Now()).
+		Where("NOT EXISTS (SELECT 1 FROM outbox_events earlier WHERE earlier.aggregate_id = outbox_events.aggregate_id AND earlier.published_at IS NULL AND earlier.id < outbox_events.id)").
+		Order("id asc").Limit(outboxBatchSize).Find(&events).Error; err != nil {
+		logger.Error("failed to find due outbox events", zap.Error(err))
+		return
+	}
+
+	for _, event := range events {
+		// Push the next attempt back while publishing, so no other relay takes the event meanwhile
+		result := DbConnection.Model(&db.OutboxEvent{}).
+			Where("id = ? AND published_at IS NULL AND next_attempt_at = ?", event.ID, event.NextAttemptAt).
+			UpdateColumn("next_attempt_at", time.Now().Add(time.Minute))
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+
+		update := map[string]interface{}{"attempts": event.Attempts + 1, "last_error": ""}
+		if err := publishOutboxEvent(event); err != nil {
+			logger.Error("failed to publish outbox event", zap.Error(err), zap.String("event_id", event.EventID))
+			update["last_error"] = err.Error()
+			update["next_attempt_at"] = time.Now().Add(outboxBackoff(event.Attempts + 1))
+		} else {
+			update["published_at"] = time.Now()
+		}
+		if err := DbConnection.Model(&db.OutboxEvent{}).Where("id = ?", event.ID).UpdateColumns(update).Error; err != nil {
+			logger.Error("failed to update outbox event", zap.Error(err), zap.String("event_id", event.EventID))
+		}
+	}
+}
+
+// publishOutboxEvent gives the event to every consumer that has no receipt for it yet
+func publishOutboxEvent(event db.OutboxEvent) error {
+	var data outboxData
+	if err := json.Unmarshal([]byte(event.Payload), &data); err != nil {
+		return err
+	}
+
+	var receipts []db.OutboxReceipt
+	if err := DbConnection.Where("event_id = ?", event.ID).Find(&receipts).Error; err != nil {
+		return err
+	}
+	handled := map[string]bool{}
+	for _, receipt := range receipts {
+		handled[receipt.Consumer] = true
+	}
+
+	for _, consumer := range outboxConsumers {
+		if handled[consumer.Name] {
+			continue
+		}
+
+		tx := DbConnection.Begin()
+		if err := consumer.Handle(tx, event, data); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Create(&db.OutboxReceipt{EventID: event.ID, Consumer: consumer.Name}).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordPaymentStat counts the event in the daily statistics of the merchant, test payments are not counted
+func recordPaymentStat(tx *gorm.DB, event db.OutboxEvent, data outboxData) error {
+	if data.mode() == db.APIKeyModeTest {
+		return nil
+	}
+	amount, currency := data.Payment.Amount, data.Payment.Currency
+	if data.Refund != nil {
+		amount, currency = data.Refund.Amount, data.Refund.Currency
+	}
+
+	return tx.Exec(`INSERT INTO payment_stats (merchant_id, day, currency, event_type, count, amount)
+		VALUES (?, ?, ?, ?, 1, ?)
+		ON CONFLICT (merchant_id, day, currency, event_type)
+		DO UPDATE SET count = payment_stats.count + 1, amount = payment_stats.amount + EXCLUDED.amount`,
+		event.MerchantID, event.CreatedAt.UTC().Format("2006-01-02"), currency, event.Type, amount).Error
+}
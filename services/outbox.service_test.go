@@ -0,0 +1,54 @@
+package services
+
+import (
+	"checkout-task/constants"
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestOutboxBackoff(t *testing.T) {
+	// Test case 1: the wait doubles with every failed attempt
+	assert.Equal(t, 5*time.Second, outboxBackoff(1))
+	assert.Equal(t, 10*time.Second, outboxBackoff(2))
+	assert.Equal(t, 40*time.Second, outboxBackoff(4))
+
+	// Test case 2: events are retried at least hourly however often they failed
+	assert.Equal(t, time.Hour, outboxBackoff(11))
+	assert.Equal(t, time.Hour, outboxBackoff(1000))
+}
+
+func TestOutboxData(t *testing.T) {
+	// Test case 1: payment events carry no refund
+	encoded, err := json.Marshal(outboxData{Payment: models.PaymentResponse{PaymentID: "p1", Amount: 1050, Currency: "EUR"}})
+	assert.NoError(t, err)
+	assert.NotContains(t, string(encoded), "refund\"")
+
+	// Test case 2: consumers read back what was written
+	var data outboxData
+	assert.NoError(t, json.Unmarshal(encoded, &data))
+	assert.Equal(t, int64(1050), data.Payment.Amount)
+	assert.Nil(t, data.Refund)
+
+	encoded, err = json.Marshal(outboxData{Refund: &outboxRefund{RefundID: "r1", Amount: 300, Currency: "EUR", Status: constants.SUCCESS}})
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(encoded, &data))
+	assert.Equal(t, int64(300), data.Refund.Amount)
+
+	// Test case 3: events written before payments had a mode are live
+	assert.Equal(t, db.APIKeyModeLive, outboxData{}.mode())
+	assert.Equal(t, db.APIKeyModeTest, outboxData{Payment: models.PaymentResponse{Mode: db.APIKeyModeTest}}.mode())
+}
+
+func TestWebhookEventType(t *testing.T) {
+	// Test case 1: every status with a webhook event has an outbox event of the same type
+	assert.Equal(t, true, webhookEventType(paymentEventTypes[constants.CAPTURED]))
+	assert.Equal(t, true, webhookEventType(db.EventRefundSucceeded))
+
+	// Test case 2: internal events are not sent to merchants
+	assert.Equal(t, false, webhookEventType(paymentEventTypes[constants.PENDING]))
+	assert.Equal(t, false, webhookEventType(db.EventPaymentRefunded))
+}
@@ -1,163 +1,468 @@
 package services
 
 import (
+	"checkout-task/acquirer"
 	"checkout-task/constants"
 	"checkout-task/logger"
 	"checkout-task/models"
 	db "checkout-task/models/db"
 	"checkout-task/utils"
+	"checkout-task/vault"
+	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/google/uuid"
-	"github.com/jinzhu/gorm"
 	"go.uber.org/zap"
+	"strings"
 )
 
-// ProcessPayment processes a payment and returns a response
-func ProcessPayment(payment models.PaymentRequest) (models.ProcessPaymentResponse, error) {
-	// Store payment details in database
+// ProcessPayment authorizes a payment and captures it straight away, authorizations that cannot be captured
+// are voided
+func ProcessPayment(merchantID int64, mode string, payment models.PaymentRequest) (models.ProcessPaymentResponse, error) {
+	authorization, err := AuthorizePayment(merchantID, mode, payment)
+	if err != nil || authorization.Status != constants.AUTHORIZED {
+		return authorization, err
+	}
+
+	capture, err := CapturePayment(merchantID, mode, authorization.PaymentIdentifier)
+	capture.CardToken = authorization.CardToken
+	capture.Brand = authorization.Brand
+	if err == nil && capture.Status != constants.AUTHORIZED {
+		return capture, nil
+	}
+
+	// The capture was declined or never sent, release the hold instead of leaving an authorization the
+	// merchant did not ask for. Payments the void cannot reach are reported as they are.
+	void, voidErr := VoidPayment(merchantID, mode, authorization.PaymentIdentifier)
+	if voidErr != nil {
+		logger.Error("failed to void payment after its capture failed", zap.Error(voidErr), zap.String("payment_id", authorization.PaymentIdentifier))
+		return capture, err
+	}
+	void.CardToken = authorization.CardToken
+	void.Brand = authorization.Brand
+	void.DeclineCode = capture.DeclineCode
+	void.DeclineCategory = capture.DeclineCategory
+	void.Retryable = capture.Retryable
+	return void, nil
+}
+
+// AuthorizePayment stores the payment and places a hold on the card for its amount. Payments in test mode
+// only use test cards and go to the bank simulator.
+func AuthorizePayment(merchantID int64, mode string, payment models.PaymentRequest) (models.ProcessPaymentResponse, error) {
+	// Find the card in the vault, cards sent in full are stored first
+	var vaultCard db.VaultCard
+	var cardNumber string
+	var err error
+	if payment.CardToken != "" {
+		vaultCard, cardNumber, err = DetokenizeCard(merchantID, mode, payment.CardToken)
+		if err == nil && payment.CVV != 0 && !utils.ValidateBrandCVV(vaultCard.Brand, payment.CVV) {
+			err = fmt.Errorf("invalid CVV")
+		}
+	} else {
+		cardNumber = strings.ReplaceAll(payment.CardNumber, " ", "")
+		vaultCard, err = TokenizeCard(merchantID, mode, models.CardRequest{
+			CardNumber: cardNumber,
+			ExpMonth:   payment.ExpMonth,
+			ExpYear:    payment.ExpYear,
+		})
+	}
+	if err != nil {
+		return models.ProcessPaymentResponse{}, err
+	}
+
+	metadata, err := encodeMetadata(payment.Metadata)
+	if err != nil {
+		return models.ProcessPaymentResponse{}, err
+	}
+
+	// Issuer country, card type and funding source of the card, unknown BINs leave them empty. The fee
+	// of the capture depends on them, so payments are not taken when they cannot be looked up.
+	bin, err := LookupBIN(cardNumber)
+	if err != nil && err != ErrBINNotFound {
+		return models.ProcessPaymentResponse{}, err
+	}
+
+	// Store payment details in database, the card is referenced by its token and the CVV is
+	// only passed on to the bank
 	paymentInfo := db.Payment{
-		PaymentID:  uuid.New().String(),
-		CardNumber: payment.CardNumber,
-		ExpMonth:   payment.ExpMonth,
-		ExpYear:    payment.ExpYear,
-		CVV:        payment.CVV,
-		Amount:     payment.Amount,
-		Currency:   payment.Currency,
-		Status:     constants.INPROGRESS,
-	}
-	if err := DbConnection.Create(&paymentInfo).Error; err != nil {
+		MerchantID:          merchantID,
+		Mode:                mode,
+		PaymentID:           uuid.New().String(),
+		Reference:           payment.Reference,
+		Description:         payment.Description,
+		Metadata:            metadata,
+		StatementDescriptor: payment.StatementDescriptor,
+		CardToken:           vaultCard.Token,
+		CardLast4:           vaultCard.Last4,
+		Brand:               vaultCard.Brand,
+		CardBIN:             bin.BIN,
+		IssuerCountry:       bin.Country,
+		CardType:            bin.CardType,
+		Commercial:          bin.Commercial,
+		ExpMonth:            vaultCard.ExpMonth,
+		ExpYear:             vaultCard.ExpYear,
+		Amount:              payment.Amount,
+		Currency:            payment.Currency,
+	}
+	tx := DbConnection.Begin()
+	if err := CreatePayment(tx, &paymentInfo); err != nil {
+		tx.Rollback()
+		logger.Error("failed to store payment details", zap.Error(err))
+		return models.ProcessPaymentResponse{}, fmt.Errorf("failed to store payment details")
+	}
+	if err := tx.Commit().Error; err != nil {
 		logger.Error("failed to store payment details", zap.Error(err))
 		return models.ProcessPaymentResponse{}, fmt.Errorf("failed to store payment details")
 	}
 
-	// Simulate acquiring bank
-	status := simulateAcquiringBank(payment)
+	// Ask the acquiring bank to hold the funds
+	bankResponse, err := acquirerFor(mode).Authorize(context.Background(), acquirer.AuthorizationRequest{
+		MerchantReference:   paymentInfo.PaymentID,
+		CardNumber:          cardNumber,
+		ExpMonth:            vaultCard.ExpMonth,
+		ExpYear:             vaultCard.ExpYear,
+		CVV:                 payment.CVV,
+		Amount:              payment.Amount,
+		Currency:            payment.Currency,
+		StoredCredential:    payment.CardToken != "",
+		StatementDescriptor: payment.StatementDescriptor,
+	})
+	if err != nil {
+		logger.Error("Payment failed while contacting the acquirer", zap.Error(err), zap.String("payment_id", paymentInfo.PaymentID))
+	} else if !bankResponse.Approved {
+		logger.Error("Payment declined by the acquirer", zap.String("reason", bankResponse.DeclineReason), zap.String("payment_id", paymentInfo.PaymentID))
+	}
 
-	if status == constants.SUCCESS {
-		if err := DbConnection.Model(&db.Payment{}).Where("id = ?", paymentInfo.ID).Update(db.Payment{Status: constants.SUCCESS}).Error; err != nil {
-			logger.Error("failed to store payment details", zap.Error(err))
-			return models.ProcessPaymentResponse{}, fmt.Errorf("failed to store payment details")
-		}
+	resolved, err := resolveAuthorization(paymentInfo.ID, bankResponse, err)
+	if err != nil {
+		return models.ProcessPaymentResponse{}, err
 	}
 
-	logger.Info("Payment processed", zap.String("status", status))
+	logger.Info("Payment authorized", zap.String("status", resolved.Status))
 	//  Return success or failure response
 	return models.ProcessPaymentResponse{
-		Status:            status,
-		PaymentIdentifier: paymentInfo.PaymentID,
+		Status:            resolved.Status,
+		PaymentIdentifier: resolved.PaymentID,
+		CardToken:         resolved.CardToken,
+		Brand:             resolved.Brand,
+		DeclineCode:       resolved.DeclineCode,
+		DeclineCategory:   resolved.DeclineCategory,
+		Retryable:         acquirer.DeclineRetryable(resolved.DeclineCode),
 	}, nil
+}
 
+// authorizationOutcome turns the acquirer's answer to an authorization into the status of the payment, the
+// reason of the move and its decline code. Payments the acquirer did not answer for wait in
+// AuthorizationRequested, it may have held the funds; those it was not asked about at all fail.
+func authorizationOutcome(bankResponse acquirer.Response, err error) (string, string, string) {
+	switch {
+	case err == acquirer.ErrNotSent:
+		return constants.FAILED, err.Error(), acquirer.DeclineAcquirerUnavailable
+	case err != nil:
+		return constants.AUTHORIZATIONREQUESTED, "acquirer did not answer: " + err.Error(), ""
+	case bankResponse.Approved:
+		return constants.AUTHORIZED, "approved by bank", ""
+	case bankResponse.DeclineCode == acquirer.DeclineAcquirerUnavailable:
+		return constants.FAILED, bankResponse.DeclineReason, bankResponse.DeclineCode
+	case bankResponse.DeclineCode == "":
+		return constants.DECLINED, bankResponse.DeclineReason, acquirer.DeclineDoNotHonour
+	}
+	return constants.DECLINED, bankResponse.DeclineReason, bankResponse.DeclineCode
 }
 
-func RetrievePayment(paymentID string) (*models.PaymentResponse, error) {
+// resolveAuthorization applies the acquirer's answer to a payment waiting for its authorization, Pending or
+// AuthorizationRequested, and returns the payment. Payments resolved meanwhile, by the request itself or by
+// RecoverBankOperations, are returned as they are.
+func resolveAuthorization(id int64, bankResponse acquirer.Response, bankErr error) (db.Payment, error) {
+	tx := DbConnection.Begin()
+
 	var payment db.Payment
-	if err := DbConnection.Where("payment_id = ?", paymentID).First(&payment).Error; err != nil {
-		logger.Error("failed to retrieve payment details", zap.String("error", err.Error()), zap.String("payment_id", paymentID))
-		return &models.PaymentResponse{}, fmt.Errorf("failed to retrieve payment details")
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id).First(&payment).Error; err != nil {
+		tx.Rollback()
+		logger.Error("failed to retrieve payment details", zap.Error(err), zap.Int64("id", id))
+		return db.Payment{}, fmt.Errorf("failed to retrieve payment details")
+	}
+	status, reason, declineCode := authorizationOutcome(bankResponse, bankErr)
+	if (payment.Status != constants.PENDING && payment.Status != constants.AUTHORIZATIONREQUESTED) || payment.Status == status {
+		tx.Rollback()
+		return payment, nil
 	}
 
-	// Get payment details from cache
-	paymentDetails, isPaymentDetailsFound, err := GetPaymentDetailsFromCache(paymentID)
+	payment.AcquirerReference = bankResponse.Reference
+	payment.DeclineCode = declineCode
+	payment.DeclineCategory = ""
+	if declineCode != "" {
+		payment.DeclineCategory = acquirer.DeclineCategory(declineCode)
+	}
+	if err := tx.Model(&payment).UpdateColumns(map[string]interface{}{
+		"acquirer_reference": payment.AcquirerReference,
+		"decline_code":       payment.DeclineCode,
+		"decline_category":   payment.DeclineCategory,
+	}).Error; err != nil {
+		tx.Rollback()
+		logger.Error("failed to store payment details", zap.Error(err))
+		return db.Payment{}, fmt.Errorf("failed to store payment details")
+	}
+	if err := TransitionPayment(tx, &payment, status, reason); err != nil {
+		tx.Rollback()
+		logger.Error("failed to store payment details", zap.Error(err))
+		return db.Payment{}, fmt.Errorf("failed to store payment details")
+	}
+	if err := tx.Commit().Error; err != nil {
+		logger.Error("failed to store payment details", zap.Error(err))
+		return db.Payment{}, fmt.Errorf("failed to store payment details")
+	}
+	DeletePaymentDetailsFromCache(payment.PaymentID)
+
+	return payment, nil
+}
+
+// CapturePayment settles the amount held by a previously authorized payment. The payment moves to
+// CaptureRequested before the acquirer is asked, so no row stays locked during the call; payments the
+// answer never reached are resolved by RecoverBankOperations.
+func CapturePayment(merchantID int64, mode string, paymentID string) (models.ProcessPaymentResponse, error) {
+	payment, err := requestPaymentOperation(merchantID, mode, paymentID, constants.CAPTUREREQUESTED, "capture requested by merchant")
 	if err != nil {
-		logger.Error("failed to retrieve payment details from cache", zap.String("error", err.Error()), zap.String("payment_id", paymentID))
+		return models.ProcessPaymentResponse{}, err
 	}
 
-	if isPaymentDetailsFound {
-		return paymentDetails, nil
+	// Ask the acquiring bank to settle the hold
+	bankResponse, err := acquirerFor(payment.Mode).Capture(context.Background(), payment.AcquirerReference, acquirer.AmountRequest{
+		OperationReference: captureReference(payment),
+		Amount:             payment.Amount,
+	})
+	if err != nil {
+		logger.Error("Capture failed while contacting the acquirer", zap.Error(err), zap.String("payment_id", paymentID))
+		return models.ProcessPaymentResponse{Status: payment.Status, PaymentIdentifier: paymentID}, nil
 	}
 
-	// Mask card number
-	maskedCardNumber := utils.MaskCardNumber(payment.CardNumber)
-	payment.CardNumber = maskedCardNumber
+	return resolvePaymentOperation(payment.ID, constants.CAPTUREREQUESTED, bankResponse)
+}
 
-	// Return payment details
-	paymentDetails = &models.PaymentResponse{
-		PaymentID:        payment.PaymentID,
-		MaskedCardNumber: payment.CardNumber,
-		Amount:           payment.Amount,
-		Currency:         payment.Currency,
-		Status:           payment.Status,
+// VoidPayment releases the hold of an authorized payment that was never captured. Like captures, the payment
+// waits in VoidRequested while the acquirer is asked.
+func VoidPayment(merchantID int64, mode string, paymentID string) (models.ProcessPaymentResponse, error) {
+	payment, err := requestPaymentOperation(merchantID, mode, paymentID, constants.VOIDREQUESTED, "void requested by merchant")
+	if err != nil {
+		return models.ProcessPaymentResponse{}, err
 	}
 
-	// Set payment details in cache
-	SetPaymentDetails(paymentID, paymentDetails)
-	return paymentDetails, nil
+	// Ask the acquiring bank to release the hold
+	bankResponse, err := acquirerFor(payment.Mode).Void(context.Background(), payment.AcquirerReference, acquirer.VoidRequest{
+		OperationReference: voidReference(payment),
+	})
+	if err != nil {
+		logger.Error("Void failed while contacting the acquirer", zap.Error(err), zap.String("payment_id", paymentID))
+		return models.ProcessPaymentResponse{Status: payment.Status, PaymentIdentifier: paymentID}, nil
+	}
+
+	return resolvePaymentOperation(payment.ID, constants.VOIDREQUESTED, bankResponse)
 }
 
-func simulateAcquiringBank(payment models.PaymentRequest) string {
-	// Validate card details
-	validCardNumber := utils.ValidateCardNumber(payment.CardNumber)
-	validExpiryDate := utils.ValidateExpiryDate(payment.ExpMonth, payment.ExpYear)
-	validAmount := utils.ValidateAmount(payment.Amount)
-	validCurrency := utils.ValidateCurrency(payment.Currency)
-	validCVV := utils.ValidateCVV(payment.CVV)
+// requestPaymentOperation moves an authorized payment of the merchant to CaptureRequested or VoidRequested
+// and commits, so the acquirer is only called once the request is recorded
+func requestPaymentOperation(merchantID int64, mode string, paymentID string, requested string, reason string) (db.Payment, error) {
+	tx := DbConnection.Begin()
 
-	if !validCardNumber || !validExpiryDate || !validAmount || !validCurrency || !validCVV {
-		logger.Error("Payment failed due to invalid card details")
-		return constants.FAILURE
+	// Lock the payment so concurrent captures and voids cannot both be sent to the bank
+	var payment db.Payment
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("payment_id = ? AND merchant_id = ? AND mode = ?", paymentID, merchantID, mode).First(&payment).Error; err != nil {
+		tx.Rollback()
+		logger.Error("failed to retrieve payment details", zap.Error(err), zap.String("payment_id", paymentID))
+		return db.Payment{}, fmt.Errorf("failed to retrieve payment details")
 	}
 
-	// Check if card has sufficient funds
-	hasSufficientFunds := CheckFunds(payment.CardNumber, payment.Amount)
-	if !hasSufficientFunds {
-		logger.Error("Payment failed due to insufficient funds")
-		return constants.FAILURE
+	operation := "captured"
+	if requested == constants.VOIDREQUESTED {
+		operation = "voided"
+		if utils.Contains([]string{constants.CAPTURED, constants.PARTIALLYREFUNDED, constants.REFUNDED}, payment.Status) {
+			tx.Rollback()
+			return db.Payment{}, fmt.Errorf("payment has already been captured and cannot be voided, refund it instead")
+		}
 	}
-
-	// Check for fraud
-	isFraud := CheckForFraud(payment.CardNumber, payment.Amount, payment.Currency)
-	if isFraud {
-		logger.Error("Payment failed due to potential fraud")
-		return constants.FAILURE
+	if !CanTransition(payment.Status, requested) {
+		tx.Rollback()
+		return db.Payment{}, fmt.Errorf("payment cannot be %s in status %s", operation, payment.Status)
 	}
 
-	// Approve payment
-	err := ApprovePayment(payment.CardNumber, payment.Amount)
-	if err != nil {
-		logger.Error("Payment failed while approving payment", zap.Error(err))
-		return constants.FAILURE
+	if err := TransitionPayment(tx, &payment, requested, reason); err != nil {
+		tx.Rollback()
+		logger.Error("failed to store payment details", zap.Error(err))
+		return db.Payment{}, fmt.Errorf("failed to store payment details")
+	}
+	if err := tx.Commit().Error; err != nil {
+		logger.Error("failed to store payment details", zap.Error(err))
+		return db.Payment{}, fmt.Errorf("failed to store payment details")
 	}
+	DeletePaymentDetailsFromCache(paymentID)
 
-	logger.Info("Payment approved successfully")
-	// Return status
-	return constants.SUCCESS
+	return payment, nil
 }
 
-func CheckFunds(cardNumber string, amount float64) bool {
-	var card db.Card
-	if err := DbConnection.Where("card_number = ?", cardNumber).First(&card).Error; err != nil {
-		fmt.Println("Error finding card:", err)
-		return false
+// resolvePaymentOperation applies the acquirer's answer to a payment waiting in the requested status: approved
+// captures and voids complete, declined ones return the payment to Authorized. Payments resolved meanwhile,
+// by the merchant's request or by RecoverBankOperations, are left as they are.
+func resolvePaymentOperation(id int64, requested string, bankResponse acquirer.Response) (models.ProcessPaymentResponse, error) {
+	tx := DbConnection.Begin()
+
+	var payment db.Payment
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", id).First(&payment).Error; err != nil {
+		tx.Rollback()
+		logger.Error("failed to retrieve payment details", zap.Error(err), zap.Int64("id", id))
+		return models.ProcessPaymentResponse{}, fmt.Errorf("failed to retrieve payment details")
+	}
+	if payment.Status != requested {
+		tx.Rollback()
+		return models.ProcessPaymentResponse{Status: payment.Status, PaymentIdentifier: payment.PaymentID}, nil
 	}
 
-	return card.Balance >= amount
-}
+	status, reason := constants.CAPTURED, "captured by merchant"
+	if requested == constants.VOIDREQUESTED {
+		status, reason = constants.VOIDED, "voided by merchant"
+	}
+	if !bankResponse.Approved {
+		logger.Error("Operation declined by the acquirer", zap.String("status", requested), zap.String("reason", bankResponse.DeclineReason), zap.String("payment_id", payment.PaymentID))
+		status, reason = constants.AUTHORIZED, "declined by the acquirer: "+bankResponse.DeclineReason
+	}
 
-func CheckForFraud(cardNumber string, amount float64, currency string) bool {
-	var fraud db.Fraud
-	if err := DbConnection.Where("card_number = ? AND amount >= ? AND currency = ?", cardNumber, amount, currency).First(&fraud).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return false
+	// Captures are priced with the move to Captured, if that fails the payment stays CaptureRequested
+	// and RecoverBankOperations completes it later
+	if status == constants.CAPTURED {
+		fee, err := calculateFee(tx, payment)
+		if err != nil {
+			tx.Rollback()
+			logger.Error("failed to calculate payment fee", zap.Error(err), zap.String("payment_id", payment.PaymentID))
+			return models.ProcessPaymentResponse{}, fmt.Errorf("failed to calculate payment fee")
+		}
+		if err := chargeFee(tx, &payment, fee); err != nil {
+			tx.Rollback()
+			logger.Error("failed to store payment fee", zap.Error(err))
+			return models.ProcessPaymentResponse{}, fmt.Errorf("failed to store payment details")
 		}
-		fmt.Println("Error finding fraud:", err)
-		return true
 	}
 
-	return true
+	if err := TransitionPayment(tx, &payment, status, reason); err != nil {
+		tx.Rollback()
+		logger.Error("failed to store payment details", zap.Error(err))
+		return models.ProcessPaymentResponse{}, fmt.Errorf("failed to store payment details")
+	}
+	if status == constants.CAPTURED {
+		if err := postCaptureLedger(tx, payment); err != nil {
+			tx.Rollback()
+			logger.Error("failed to post capture to the ledger", zap.Error(err), zap.String("payment_id", payment.PaymentID))
+			return models.ProcessPaymentResponse{}, fmt.Errorf("failed to store payment details")
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		logger.Error("failed to store payment details", zap.Error(err))
+		return models.ProcessPaymentResponse{}, fmt.Errorf("failed to store payment details")
+	}
+	DeletePaymentDetailsFromCache(payment.PaymentID)
+
+	if !bankResponse.Approved {
+		return declinedResponse(payment, bankResponse.DeclineCode), nil
+	}
+
+	logger.Info("Payment operation completed", zap.String("payment_id", payment.PaymentID), zap.String("status", status))
+	return models.ProcessPaymentResponse{
+		Status:            status,
+		PaymentIdentifier: payment.PaymentID,
+	}, nil
 }
 
-func ApprovePayment(cardNumber string, amount float64) error {
-	// Find the card in the database
-	var card db.Card
-	if err := DbConnection.Where("card_number = ?", cardNumber).First(&card).Error; err != nil {
-		return err
+// declinedResponse reports an operation the bank turned down, the payment keeps its status
+func declinedResponse(payment db.Payment, declineCode string) models.ProcessPaymentResponse {
+	return models.ProcessPaymentResponse{
+		Status:            payment.Status,
+		PaymentIdentifier: payment.PaymentID,
+		DeclineCode:       declineCode,
+		DeclineCategory:   acquirer.DeclineCategory(declineCode),
+		Retryable:         acquirer.DeclineRetryable(declineCode),
 	}
+}
 
-	// Update the card balance
-	card.Balance -= amount
-	if err := DbConnection.Save(&card).Error; err != nil {
-		return err
+// RetrievePayment returns a payment of the merchant in the mode with its card number masked
+func RetrievePayment(merchantID int64, mode string, paymentID string) (*models.PaymentResponse, error) {
+	var payment db.Payment
+	if err := DbConnection.Where("payment_id = ? AND merchant_id = ? AND mode = ?", paymentID, merchantID, mode).First(&payment).Error; err != nil {
+		logger.Error("failed to retrieve payment details", zap.String("error", err.Error()), zap.String("payment_id", paymentID))
+		return &models.PaymentResponse{}, fmt.Errorf("failed to retrieve payment details")
+	}
+
+	// Get payment details from cache
+	paymentDetails, isPaymentDetailsFound, err := GetPaymentDetailsFromCache(paymentID)
+	if err != nil {
+		logger.Error("failed to retrieve payment details from cache", zap.String("error", err.Error()), zap.String("payment_id", paymentID))
+	}
+
+	if isPaymentDetailsFound {
+		return paymentDetails, nil
+	}
+
+	// Return payment details
+	response := paymentResponse(payment)
+	paymentDetails = &response
+
+	// Set payment details in cache
+	SetPaymentDetails(paymentID, paymentDetails)
+	return paymentDetails, nil
+}
+
+// paymentResponse shows a payment to its merchant with the card number masked. Payments made before the
+// vault that were not encrypted yet still hold the full number.
+func paymentResponse(payment db.Payment) models.PaymentResponse {
+	maskedCardNumber := utils.MaskLast4(payment.CardLast4)
+	if payment.CardLast4 == "" && !vault.IsEncrypted(payment.CardNumber) {
+		maskedCardNumber = utils.MaskCardNumber(payment.CardNumber)
+	}
+
+	return models.PaymentResponse{
+		PaymentID:           payment.PaymentID,
+		Mode:                payment.Mode,
+		Reference:           payment.Reference,
+		Description:         payment.Description,
+		Metadata:            decodeMetadata(payment),
+		StatementDescriptor: payment.StatementDescriptor,
+		CardToken:           payment.CardToken,
+		MaskedCardNumber:    maskedCardNumber,
+		Brand:               payment.Brand,
+		IssuerCountry:       payment.IssuerCountry,
+		CardType:            payment.CardType,
+		Commercial:          payment.Commercial,
+		Amount:              payment.Amount,
+		RefundedAmount:      payment.RefundedAmount,
+		Fee:                 payment.Fee,
+		Currency:            payment.Currency,
+		Status:              payment.Status,
+		DeclineCode:         payment.DeclineCode,
+		DeclineCategory:     payment.DeclineCategory,
+		Retryable:           acquirer.DeclineRetryable(payment.DeclineCode),
+		CreatedAt:           payment.CreatedAt,
+		UpdatedAt:           payment.UpdatedAt,
 	}
+}
 
-	return nil
+// encodeMetadata stores the merchant's metadata as a JSON object, payments without metadata store nothing
+func encodeMetadata(metadata map[string]string) (string, error) {
+	if len(metadata) == 0 {
+		return "", nil
+	}
+	encoded, err := json.Marshal(metadata)
+	if err != nil {
+		logger.Error("failed to encode payment metadata", zap.Error(err))
+		return "", fmt.Errorf("failed to store payment details")
+	}
+	return string(encoded), nil
+}
+
+func decodeMetadata(payment db.Payment) map[string]string {
+	if payment.Metadata == "" {
+		return nil
+	}
+	var metadata map[string]string
+	if err := json.Unmarshal([]byte(payment.Metadata), &metadata); err != nil {
+		logger.Error("failed to decode payment metadata", zap.Error(err), zap.String("payment_id", payment.PaymentID))
+		return nil
+	}
+	return metadata
 }
@@ -0,0 +1,34 @@
+package services
+
+import (
+	"checkout-task/acquirer"
+	"checkout-task/constants"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAuthorizationOutcome(t *testing.T) {
+	// Test case 1: approvals and declines of the acquirer
+	status, _, declineCode := authorizationOutcome(acquirer.Response{Approved: true, Reference: "auth_1"}, nil)
+	assert.Equal(t, constants.AUTHORIZED, status)
+	assert.Equal(t, "", declineCode)
+	status, _, declineCode = authorizationOutcome(acquirer.Response{DeclineCode: acquirer.DeclineStolenCard}, nil)
+	assert.Equal(t, constants.DECLINED, status)
+	assert.Equal(t, acquirer.DeclineStolenCard, declineCode)
+	_, _, declineCode = authorizationOutcome(acquirer.Response{}, nil)
+	assert.Equal(t, acquirer.DeclineDoNotHonour, declineCode)
+
+	// Test case 2: without an answer the acquirer may have held the funds, the payment waits for recovery
+	status, _, declineCode = authorizationOutcome(acquirer.Response{}, errors.New("acquirer unreachable"))
+	assert.Equal(t, constants.AUTHORIZATIONREQUESTED, status)
+	assert.Equal(t, "", declineCode)
+
+	// Test case 3: authorizations the acquirer never received fail
+	status, _, declineCode = authorizationOutcome(acquirer.Response{}, acquirer.ErrNotSent)
+	assert.Equal(t, constants.FAILED, status)
+	assert.Equal(t, acquirer.DeclineAcquirerUnavailable, declineCode)
+	notFound, _ := lookupOutcome(acquirer.Response{}, acquirer.ErrOperationNotFound)
+	status, _, _ = authorizationOutcome(notFound, nil)
+	assert.Equal(t, constants.FAILED, status)
+}
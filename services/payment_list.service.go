@@ -0,0 +1,130 @@
+package services
+
+import (
+	"checkout-task/logger"
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultPaymentListLimit is the page size when the request sets none
+const defaultPaymentListLimit = 20
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// paymentCursor points at the last payment of a page, pages are ordered by creation time and id
+type paymentCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// encodePaymentCursor returns an opaque cursor for the payment
+func encodePaymentCursor(payment db.Payment) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(payment.CreatedAt.UTC().Format(time.RFC3339Nano) + "," + strconv.FormatInt(payment.ID, 10)))
+}
+
+func decodePaymentCursor(cursor string) (paymentCursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return paymentCursor{}, ErrInvalidCursor
+	}
+	parts := strings.Split(string(decoded), ",")
+	if len(parts) != 2 {
+		return paymentCursor{}, ErrInvalidCursor
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return paymentCursor{}, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return paymentCursor{}, ErrInvalidCursor
+	}
+	return paymentCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// filterPayments narrows query to the merchant's payments in the mode matching the filters of the request
+func filterPayments(query *gorm.DB, merchantID int64, mode string, request models.PaymentListRequest) *gorm.DB {
+	query = query.Where("merchant_id = ? AND mode = ?", merchantID, mode)
+	if request.Status != "" {
+		query = query.Where("status = ?", request.Status)
+	}
+	if request.Currency != "" {
+		query = query.Where("currency = ?", request.Currency)
+	}
+	if request.MinAmount > 0 {
+		query = query.Where("amount >= ?", request.MinAmount)
+	}
+	if request.MaxAmount > 0 {
+		query = query.Where("amount <= ?", request.MaxAmount)
+	}
+	if !request.CreatedFrom.IsZero() {
+		query = query.Where("created_at >= ?", request.CreatedFrom)
+	}
+	if !request.CreatedTo.IsZero() {
+		query = query.Where("created_at < ?", request.CreatedTo)
+	}
+	if request.Last4 != "" {
+		query = query.Where("card_last4 = ?", request.Last4)
+	}
+	if request.Brand != "" {
+		query = query.Where("brand = ?", strings.ToLower(request.Brand))
+	}
+	if request.Reference != "" {
+		query = query.Where("reference = ?", request.Reference)
+	}
+	return query
+}
+
+// paymentOrder returns the ORDER BY clause and the comparison that selects the payments after a cursor
+func paymentOrder(order string) (string, string) {
+	if order == "asc" {
+		return "created_at asc, id asc", ">"
+	}
+	return "created_at desc, id desc", "<"
+}
+
+// ListPayments returns a page of the merchant's payments in the mode matching the filters, newest first unless
+// the order is "asc". The cursor of the last payment fetches the next page.
+func ListPayments(merchantID int64, mode string, request models.PaymentListRequest) (models.PaymentList, error) {
+	limit := request.Limit
+	if limit == 0 {
+		limit = defaultPaymentListLimit
+	}
+	orderBy, after := paymentOrder(request.Order)
+
+	query := filterPayments(DbConnection, merchantID, mode, request)
+	if request.Cursor != "" {
+		cursor, err := decodePaymentCursor(request.Cursor)
+		if err != nil {
+			return models.PaymentList{}, err
+		}
+		query = query.Where(fmt.Sprintf("(created_at, id) %s (?, ?)", after), cursor.CreatedAt, cursor.ID)
+	}
+
+	// Fetch one more payment than the page holds to know whether another page follows
+	var payments []db.Payment
+	if err := query.Order(orderBy).Limit(limit + 1).Find(&payments).Error; err != nil {
+		logger.Error("failed to list payments", zap.Error(err))
+		return models.PaymentList{}, fmt.Errorf("failed to list payments")
+	}
+
+	list := models.PaymentList{Payments: []models.PaymentResponse{}}
+	if len(payments) > limit {
+		payments = payments[:limit]
+		list.HasMore = true
+		list.NextCursor = encodePaymentCursor(payments[limit-1])
+	}
+	for _, payment := range payments {
+		list.Payments = append(list.Payments, paymentResponse(payment))
+	}
+
+	return list, nil
+}
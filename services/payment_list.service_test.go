@@ -0,0 +1,34 @@
+package services
+
+import (
+	db "checkout-task/models/db"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestPaymentCursor(t *testing.T) {
+	createdAt := time.Date(2026, 10, 18, 9, 30, 15, 123456000, time.FixedZone("CEST", 2*60*60))
+
+	// Test case 1: a cursor points back at the payment it was made from
+	cursor, err := decodePaymentCursor(encodePaymentCursor(db.Payment{ID: 42, CreatedAt: createdAt}))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), cursor.ID)
+	assert.True(t, createdAt.Equal(cursor.CreatedAt))
+
+	// Test case 2: anything else is rejected
+	for _, invalid := range []string{"not a cursor!", "MjAyNg", "MjAyNi0xMC0xOFQwOTozMDoxNVosYWJj"} {
+		_, err := decodePaymentCursor(invalid)
+		assert.Equal(t, ErrInvalidCursor, err, invalid)
+	}
+}
+
+func TestPaymentOrder(t *testing.T) {
+	orderBy, after := paymentOrder("asc")
+	assert.Equal(t, "created_at asc, id asc", orderBy)
+	assert.Equal(t, ">", after)
+
+	orderBy, after = paymentOrder("")
+	assert.Equal(t, "created_at desc, id desc", orderBy)
+	assert.Equal(t, "<", after)
+}
@@ -0,0 +1,425 @@
+package services
+
+import (
+	"checkout-task/constants"
+	"checkout-task/logger"
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"checkout-task/utils"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+)
+
+// PricingOverride is the plan recorded on payments charged by a rule of their merchant
+const PricingOverride = "merchant override"
+
+// defaultPricingPlanID is the plan the gateway creates when it starts without any pricing plan
+const defaultPricingPlanID = "standard"
+
+var (
+	ErrPaymentNotCaptured  = errors.New("fees are only charged once the payment is captured")
+	ErrPricingPlanNotFound = errors.New("pricing plan not found")
+	ErrNoPricingPlan       = errors.New("no pricing plan applies to the merchant")
+)
+
+// defaultFixedFees are the fixed fees of the default plan in minor units, payments in other currencies only
+// pay the rate
+var defaultFixedFees = []struct {
+	Currency string
+	FixedFee int64
+}{{"EUR", 25}, {"GBP", 20}, {"USD", 30}}
+
+// eeaCountries are the countries of the European Economic Area
+var eeaCountries = map[string]bool{
+	"AT": true, "BE": true, "BG": true, "CY": true, "CZ": true, "DE": true, "DK": true, "EE": true, "ES": true,
+	"FI": true, "FR": true, "GR": true, "HR": true, "HU": true, "IE": true, "IS": true, "IT": true, "LI": true,
+	"LT": true, "LU": true, "LV": true, "MT": true, "NL": true, "NO": true, "PL": true, "PT": true, "RO": true,
+	"SE": true, "SI": true, "SK": true,
+}
+
+// paymentFee is the fee of a payment and how it was computed
+type paymentFee struct {
+	Plan     string
+	Region   string
+	Rate     int64
+	Variable int64
+	Fixed    int64
+	Total    int64
+}
+
+// cardRegion is the region of the issuing country of a card, cards of unknown BINs are international
+func cardRegion(issuerCountry string) string {
+	switch {
+	case eeaCountries[issuerCountry]:
+		return db.CardRegionEEA
+	case issuerCountry == "GB":
+		return db.CardRegionUK
+	case issuerCountry == "US" || issuerCountry == "CA":
+		return db.CardRegionNorthAmerica
+	}
+	return db.CardRegionInternational
+}
+
+// ruleSpecificity ranks the rules matching a payment, a rule for the currency beats one for the brand,
+// which beats one for the card region
+func ruleSpecificity(rule db.PricingRule) int {
+	specificity := 0
+	if rule.Currency != "" {
+		specificity += 4
+	}
+	if rule.Brand != "" {
+		specificity += 2
+	}
+	if rule.CardRegion != "" {
+		specificity++
+	}
+	return specificity
+}
+
+// selectPricingRule returns the most specific of the rules matching the payment, the oldest one on a tie
+func selectPricingRule(rules []db.PricingRule, brand string, region string, currency string) (db.PricingRule, bool) {
+	var selected db.PricingRule
+	found := false
+	for _, rule := range rules {
+		if (rule.Brand != "" && rule.Brand != brand) ||
+			(rule.CardRegion != "" && rule.CardRegion != region) ||
+			(rule.Currency != "" && rule.Currency != currency) {
+			continue
+		}
+		if !found || ruleSpecificity(rule) > ruleSpecificity(selected) ||
+			(ruleSpecificity(rule) == ruleSpecificity(selected) && rule.ID < selected.ID) {
+			selected, found = rule, true
+		}
+	}
+	return selected, found
+}
+
+// applyPricingRule charges the rate of the rule on the amount, rounded half up, plus its fixed fee.
+// The fee never exceeds the amount.
+func applyPricingRule(rule db.PricingRule, amount int64) (int64, int64, int64) {
+	variable := (amount*rule.RateBasisPts + 5000) / 10000
+	total := variable + rule.FixedFee
+	if total > amount {
+		total = amount
+	}
+	return variable, rule.FixedFee, total
+}
+
+// defaultPricingRules are the rules of the default plan: a rate by card region and brand, charged with the fixed
+// fee of the currency in the currencies of defaultFixedFees, and a rate of its own for JPY
+func defaultPricingRules() []models.PricingRuleRequest {
+	rates := []models.PricingRuleRequest{
+		{RateBasisPts: 290},
+		{CardRegion: db.CardRegionEEA, RateBasisPts: 140},
+		{CardRegion: db.CardRegionUK, RateBasisPts: 250},
+		{Brand: utils.BrandAmex, RateBasisPts: 350},
+	}
+
+	rules := append([]models.PricingRuleRequest{}, rates...)
+	for _, fixedFee := range defaultFixedFees {
+		for _, rule := range rates {
+			rule.Currency, rule.FixedFee = fixedFee.Currency, fixedFee.FixedFee
+			rules = append(rules, rule)
+		}
+	}
+	return append(rules, models.PricingRuleRequest{Currency: "JPY", RateBasisPts: 360})
+}
+
+// seedDefaultPricingPlan creates the default plan when there is no pricing plan at all, so captures are
+// always priced. Plans changed through the admin API are left as they are.
+func seedDefaultPricingPlan() {
+	var plans int
+	if err := DbConnection.Model(&db.PricingPlan{}).Count(&plans).Error; err != nil {
+		logger.Error("Failed to count the pricing plans", zap.Error(err))
+		return
+	}
+	if plans > 0 {
+		return
+	}
+
+	if _, err := CreatePricingPlan(models.PricingPlanRequest{
+		PlanID:    defaultPricingPlanID,
+		Name:      "Standard",
+		IsDefault: true,
+		Rules:     defaultPricingRules(),
+	}); err != nil {
+		logger.Error("Failed to create the default pricing plan", zap.Error(err))
+		return
+	}
+	logger.Info("Created the default pricing plan", zap.String("plan_id", defaultPricingPlanID))
+}
+
+// calculateFee prices a payment with the rules of its merchant, which override the rules of the merchant's
+// plan. Payments no rule of the plan matches are free, merchants without a plan cannot be charged and
+// ErrNoPricingPlan leaves their captures to be priced later.
+func calculateFee(tx *gorm.DB, payment db.Payment) (paymentFee, error) {
+	fee := paymentFee{Region: cardRegion(payment.IssuerCountry)}
+
+	var merchant db.Merchant
+	if err := tx.Where("id = ?", payment.MerchantID).First(&merchant).Error; err != nil {
+		return fee, err
+	}
+
+	var rules []db.PricingRule
+	if err := tx.Where("merchant_id = ?", merchant.ID).Find(&rules).Error; err != nil {
+		return fee, err
+	}
+	rule, found := selectPricingRule(rules, payment.Brand, fee.Region, payment.Currency)
+	fee.Plan = PricingOverride
+
+	if !found {
+		var plan db.PricingPlan
+		query := tx.Where("is_default = ?", true)
+		if merchant.PricingPlanID != 0 {
+			query = tx.Where("id = ?", merchant.PricingPlanID)
+		}
+		if err := query.First(&plan).Error; err != nil {
+			if gorm.IsRecordNotFoundError(err) {
+				return fee, ErrNoPricingPlan
+			}
+			return fee, err
+		}
+
+		var planRules []db.PricingRule
+		if err := tx.Where("plan_id = ? AND merchant_id = 0", plan.ID).Find(&planRules).Error; err != nil {
+			return fee, err
+		}
+		rule, found = selectPricingRule(planRules, payment.Brand, fee.Region, payment.Currency)
+		fee.Plan = plan.PlanID
+	}
+
+	if found {
+		fee.Rate = rule.RateBasisPts
+		fee.Variable, fee.Fixed, fee.Total = applyPricingRule(rule, payment.Amount)
+	}
+	return fee, nil
+}
+
+// chargeFee stores the fee on the payment, on the caller's transaction
+func chargeFee(tx *gorm.DB, payment *db.Payment, fee paymentFee) error {
+	if err := tx.Model(&db.Payment{}).Where("id = ?", payment.ID).UpdateColumns(map[string]interface{}{
+		"fee":          fee.Total,
+		"fee_rate_bps": fee.Rate,
+		"fee_variable": fee.Variable,
+		"fee_fixed":    fee.Fixed,
+		"fee_plan":     fee.Plan,
+		"card_region":  fee.Region,
+	}).Error; err != nil {
+		return err
+	}
+
+	payment.Fee, payment.FeeRate, payment.FeeVariable, payment.FeeFixed = fee.Total, fee.Rate, fee.Variable, fee.Fixed
+	payment.FeePlan, payment.CardRegion = fee.Plan, fee.Region
+	return nil
+}
+
+// GetPaymentFees returns the breakdown of the fee charged for a captured payment of the merchant
+func GetPaymentFees(merchantID int64, mode string, paymentID string) (models.PaymentFeesResponse, error) {
+	var payment db.Payment
+	if err := DbConnection.Where("payment_id = ? AND merchant_id = ? AND mode = ?", paymentID, merchantID, mode).First(&payment).Error; err != nil {
+		logger.Error("failed to retrieve payment details", zap.Error(err), zap.String("payment_id", paymentID))
+		return models.PaymentFeesResponse{}, fmt.Errorf("failed to retrieve payment details")
+	}
+
+	switch payment.Status {
+	case constants.CAPTURED, constants.PARTIALLYREFUNDED, constants.REFUNDED:
+	default:
+		return models.PaymentFeesResponse{}, ErrPaymentNotCaptured
+	}
+
+	return models.PaymentFeesResponse{
+		PaymentID:   payment.PaymentID,
+		Amount:      payment.Amount,
+		Currency:    payment.Currency,
+		Brand:       payment.Brand,
+		CardRegion:  payment.CardRegion,
+		Plan:        payment.FeePlan,
+		RateBps:     payment.FeeRate,
+		VariableFee: payment.FeeVariable,
+		FixedFee:    payment.FeeFixed,
+		Fee:         payment.Fee,
+		Net:         payment.Amount - payment.Fee,
+	}, nil
+}
+
+// pricingRules turns the rules of a request into rows of the plan, or of the merchant when merchantID is set
+func pricingRules(planID int64, merchantID int64, requests []models.PricingRuleRequest) []db.PricingRule {
+	rules := make([]db.PricingRule, 0, len(requests))
+	for _, request := range requests {
+		rules = append(rules, db.PricingRule{
+			PlanID:       planID,
+			MerchantID:   merchantID,
+			Brand:        request.Brand,
+			CardRegion:   request.CardRegion,
+			Currency:     request.Currency,
+			RateBasisPts: request.RateBasisPts,
+			FixedFee:     request.FixedFee,
+		})
+	}
+	return rules
+}
+
+// replacePricingRules swaps the rules of the plan, or the overrides of the merchant, on the caller's transaction
+func replacePricingRules(tx *gorm.DB, planID int64, merchantID int64, requests []models.PricingRuleRequest) error {
+	if err := tx.Where("plan_id = ? AND merchant_id = ?", planID, merchantID).Delete(&db.PricingRule{}).Error; err != nil {
+		return err
+	}
+	for _, rule := range pricingRules(planID, merchantID, requests) {
+		if err := tx.Create(&rule).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pricingPlanResponse(plan db.PricingPlan) (models.PricingPlanResponse, error) {
+	response := models.PricingPlanResponse{PricingPlan: plan, Rules: []db.PricingRule{}}
+	err := DbConnection.Where("plan_id = ? AND merchant_id = 0", plan.ID).Order("id asc").Find(&response.Rules).Error
+	return response, err
+}
+
+func findPricingPlan(planID string) (db.PricingPlan, error) {
+	var plan db.PricingPlan
+	if err := DbConnection.Where("plan_id = ?", planID).First(&plan).Error; err != nil {
+		return db.PricingPlan{}, ErrPricingPlanNotFound
+	}
+	return plan, nil
+}
+
+// CreatePricingPlan stores a pricing plan with its rules. A default plan takes over from the previous one.
+func CreatePricingPlan(request models.PricingPlanRequest) (models.PricingPlanResponse, error) {
+	if _, err := findPricingPlan(request.PlanID); err == nil {
+		return models.PricingPlanResponse{}, fmt.Errorf("a pricing plan with this plan_id already exists")
+	}
+
+	plan := db.PricingPlan{PlanID: request.PlanID, Name: request.Name, IsDefault: request.IsDefault}
+	tx := DbConnection.Begin()
+	if plan.IsDefault {
+		if err := tx.Model(&db.PricingPlan{}).Where("is_default = ?", true).UpdateColumn("is_default", false).Error; err != nil {
+			tx.Rollback()
+			logger.Error("failed to replace the default pricing plan", zap.Error(err))
+			return models.PricingPlanResponse{}, fmt.Errorf("failed to store pricing plan")
+		}
+	}
+	if err := tx.Create(&plan).Error; err != nil {
+		tx.Rollback()
+		logger.Error("failed to store pricing plan", zap.Error(err))
+		return models.PricingPlanResponse{}, fmt.Errorf("failed to store pricing plan")
+	}
+	if err := replacePricingRules(tx, plan.ID, 0, request.Rules); err != nil {
+		tx.Rollback()
+		logger.Error("failed to store pricing rules", zap.Error(err))
+		return models.PricingPlanResponse{}, fmt.Errorf("failed to store pricing plan")
+	}
+	if err := tx.Commit().Error; err != nil {
+		logger.Error("failed to store pricing plan", zap.Error(err))
+		return models.PricingPlanResponse{}, fmt.Errorf("failed to store pricing plan")
+	}
+
+	response, err := pricingPlanResponse(plan)
+	if err != nil {
+		logger.Error("failed to retrieve pricing rules", zap.Error(err), zap.String("plan_id", plan.PlanID))
+		return models.PricingPlanResponse{}, fmt.Errorf("failed to retrieve pricing plan")
+	}
+	return response, nil
+}
+
+// ListPricingPlans returns every pricing plan with its rules, oldest first
+func ListPricingPlans() ([]models.PricingPlanResponse, error) {
+	var plans []db.PricingPlan
+	if err := DbConnection.Order("id asc").Find(&plans).Error; err != nil {
+		logger.Error("failed to retrieve pricing plans", zap.Error(err))
+		return nil, fmt.Errorf("failed to retrieve pricing plans")
+	}
+
+	responses := make([]models.PricingPlanResponse, 0, len(plans))
+	for _, plan := range plans {
+		response, err := pricingPlanResponse(plan)
+		if err != nil {
+			logger.Error("failed to retrieve pricing rules", zap.Error(err), zap.String("plan_id", plan.PlanID))
+			return nil, fmt.Errorf("failed to retrieve pricing plans")
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// ReplacePricingPlanRules replaces every rule of a pricing plan, the captures after it are charged by the new rules
+func ReplacePricingPlanRules(planID string, rules []models.PricingRuleRequest) (models.PricingPlanResponse, error) {
+	plan, err := findPricingPlan(planID)
+	if err != nil {
+		return models.PricingPlanResponse{}, err
+	}
+
+	tx := DbConnection.Begin()
+	if err := replacePricingRules(tx, plan.ID, 0, rules); err != nil {
+		tx.Rollback()
+		logger.Error("failed to replace pricing rules", zap.Error(err), zap.String("plan_id", planID))
+		return models.PricingPlanResponse{}, fmt.Errorf("failed to replace pricing rules")
+	}
+	if err := tx.Commit().Error; err != nil {
+		logger.Error("failed to replace pricing rules", zap.Error(err), zap.String("plan_id", planID))
+		return models.PricingPlanResponse{}, fmt.Errorf("failed to replace pricing rules")
+	}
+
+	response, err := pricingPlanResponse(plan)
+	if err != nil {
+		logger.Error("failed to retrieve pricing rules", zap.Error(err), zap.String("plan_id", planID))
+		return models.PricingPlanResponse{}, fmt.Errorf("failed to retrieve pricing plan")
+	}
+	return response, nil
+}
+
+// GetMerchantPricing returns the plan of the merchant and the rules overriding it
+func GetMerchantPricing(merchant db.Merchant) (models.MerchantPricingResponse, error) {
+	response := models.MerchantPricingResponse{Overrides: []db.PricingRule{}}
+	if merchant.PricingPlanID != 0 {
+		var plan db.PricingPlan
+		if err := DbConnection.Where("id = ?", merchant.PricingPlanID).First(&plan).Error; err != nil {
+			logger.Error("failed to retrieve pricing plan of merchant", zap.Error(err), zap.String("merchant_id", merchant.MerchantID))
+			return models.MerchantPricingResponse{}, fmt.Errorf("failed to retrieve merchant pricing")
+		}
+		response.PlanID = plan.PlanID
+	}
+	if err := DbConnection.Where("merchant_id = ?", merchant.ID).Order("id asc").Find(&response.Overrides).Error; err != nil {
+		logger.Error("failed to retrieve pricing overrides", zap.Error(err), zap.String("merchant_id", merchant.MerchantID))
+		return models.MerchantPricingResponse{}, fmt.Errorf("failed to retrieve merchant pricing")
+	}
+	return response, nil
+}
+
+// SetMerchantPricingPlan charges the merchant by a pricing plan, or by the default plan when planID is empty
+func SetMerchantPricingPlan(merchant db.Merchant, planID string) (models.MerchantPricingResponse, error) {
+	var pricingPlanID int64
+	if planID != "" {
+		plan, err := findPricingPlan(planID)
+		if err != nil {
+			return models.MerchantPricingResponse{}, err
+		}
+		pricingPlanID = plan.ID
+	}
+
+	if err := DbConnection.Model(&db.Merchant{}).Where("id = ?", merchant.ID).UpdateColumn("pricing_plan_id", pricingPlanID).Error; err != nil {
+		logger.Error("failed to change pricing plan of merchant", zap.Error(err), zap.String("merchant_id", merchant.MerchantID))
+		return models.MerchantPricingResponse{}, fmt.Errorf("failed to change pricing plan")
+	}
+	merchant.PricingPlanID = pricingPlanID
+	return GetMerchantPricing(merchant)
+}
+
+// ReplaceMerchantPricingOverrides replaces the rules overriding the plan of the merchant, no rules removes them
+func ReplaceMerchantPricingOverrides(merchant db.Merchant, rules []models.PricingRuleRequest) (models.MerchantPricingResponse, error) {
+	tx := DbConnection.Begin()
+	if err := replacePricingRules(tx, 0, merchant.ID, rules); err != nil {
+		tx.Rollback()
+		logger.Error("failed to replace pricing overrides", zap.Error(err), zap.String("merchant_id", merchant.MerchantID))
+		return models.MerchantPricingResponse{}, fmt.Errorf("failed to replace pricing overrides")
+	}
+	if err := tx.Commit().Error; err != nil {
+		logger.Error("failed to replace pricing overrides", zap.Error(err), zap.String("merchant_id", merchant.MerchantID))
+		return models.MerchantPricingResponse{}, fmt.Errorf("failed to replace pricing overrides")
+	}
+	return GetMerchantPricing(merchant)
+}
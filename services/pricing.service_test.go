@@ -0,0 +1,81 @@
+package services
+
+import (
+	db "checkout-task/models/db"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCardRegion(t *testing.T) {
+	assert.Equal(t, db.CardRegionEEA, cardRegion("DE"))
+	assert.Equal(t, db.CardRegionUK, cardRegion("GB"))
+	assert.Equal(t, db.CardRegionNorthAmerica, cardRegion("US"))
+	assert.Equal(t, db.CardRegionInternational, cardRegion("JP"))
+	assert.Equal(t, db.CardRegionInternational, cardRegion(""))
+}
+
+func TestSelectPricingRule(t *testing.T) {
+	rules := []db.PricingRule{
+		{ID: 1, RateBasisPts: 290, FixedFee: 30},
+		{ID: 2, CardRegion: db.CardRegionEEA, RateBasisPts: 140, FixedFee: 25},
+		{ID: 3, Brand: "amex", RateBasisPts: 350, FixedFee: 30},
+		{ID: 4, Currency: "JPY", RateBasisPts: 360},
+		{ID: 5, CardRegion: db.CardRegionEEA, RateBasisPts: 100},
+	}
+
+	// Test case 1: payments matching no specific rule get the catch-all rule
+	rule, found := selectPricingRule(rules, "visa", db.CardRegionNorthAmerica, "USD")
+	assert.True(t, found)
+	assert.Equal(t, int64(1), rule.ID)
+
+	// Test case 2: the most specific rule wins, currency before brand before region
+	rule, _ = selectPricingRule(rules, "visa", db.CardRegionEEA, "EUR")
+	assert.Equal(t, int64(2), rule.ID)
+	rule, _ = selectPricingRule(rules, "amex", db.CardRegionEEA, "EUR")
+	assert.Equal(t, int64(3), rule.ID)
+	rule, _ = selectPricingRule(rules, "amex", db.CardRegionEEA, "JPY")
+	assert.Equal(t, int64(4), rule.ID)
+
+	// Test case 3: without a catch-all rule some payments match nothing
+	_, found = selectPricingRule(rules[1:], "visa", db.CardRegionUK, "GBP")
+	assert.False(t, found)
+}
+
+func TestApplyPricingRule(t *testing.T) {
+	// Test case 1: 1.4% of 10.50 rounds half up to 15, plus 25 fixed
+	variable, fixed, total := applyPricingRule(db.PricingRule{RateBasisPts: 140, FixedFee: 25}, 1050)
+	assert.Equal(t, int64(15), variable)
+	assert.Equal(t, int64(25), fixed)
+	assert.Equal(t, int64(40), total)
+
+	// Test case 2: the fee of small payments never exceeds their amount
+	_, _, total = applyPricingRule(db.PricingRule{RateBasisPts: 290, FixedFee: 30}, 20)
+	assert.Equal(t, int64(20), total)
+}
+
+func TestDefaultPricingRules(t *testing.T) {
+	rules := defaultPricingRules()
+	for _, rule := range rules {
+		assert.NoError(t, rule.Validate())
+	}
+	planRules := pricingRules(1, 0, rules)
+	for i := range planRules {
+		planRules[i].ID = int64(i + 1)
+	}
+
+	// Test case 1: payments in currencies with a fixed fee pay it on top of the rate of their region or brand
+	rule, _ := selectPricingRule(planRules, "visa", db.CardRegionEEA, "EUR")
+	assert.Equal(t, int64(140), rule.RateBasisPts)
+	assert.Equal(t, int64(25), rule.FixedFee)
+	rule, _ = selectPricingRule(planRules, "amex", db.CardRegionUK, "GBP")
+	assert.Equal(t, int64(350), rule.RateBasisPts)
+	assert.Equal(t, int64(20), rule.FixedFee)
+
+	// Test case 2: other currencies only pay the rate
+	rule, _ = selectPricingRule(planRules, "visa", db.CardRegionInternational, "SEK")
+	assert.Equal(t, int64(290), rule.RateBasisPts)
+	assert.Equal(t, int64(0), rule.FixedFee)
+	rule, _ = selectPricingRule(planRules, "visa", db.CardRegionInternational, "JPY")
+	assert.Equal(t, int64(360), rule.RateBasisPts)
+	assert.Equal(t, int64(0), rule.FixedFee)
+}
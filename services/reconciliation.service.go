@@ -0,0 +1,245 @@
+package services
+
+import (
+	"checkout-task/acquirer"
+	"checkout-task/constants"
+	"checkout-task/logger"
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"io"
+	"sort"
+	"time"
+)
+
+const (
+	reconciliationListSize = 100
+	// reconciliationChunkSize is how many acquirer references are looked up per query
+	reconciliationChunkSize = 500
+)
+
+var ErrReconciliationRunNotFound = errors.New("reconciliation run not found")
+
+// captured reports whether the gateway recorded the capture of the payment
+func captured(payment db.Payment) bool {
+	switch payment.Status {
+	case constants.CAPTURED, constants.PARTIALLYREFUNDED, constants.REFUNDED:
+		return true
+	}
+	return false
+}
+
+// reconcile matches the captures of the settlement file to payments by acquirer reference, then amount and
+// currency. referenced are the payments of the file's references, capturedOnDate those the gateway captured
+// on the settlement date, which the acquirer should have settled unless settledBefore holds their acquirer
+// reference: the acquirer dates captures when it makes them, so one made just before midnight can be settled
+// the day before the gateway recorded it.
+func reconcile(records []acquirer.SettlementRecord, referenced []db.Payment, capturedOnDate []db.Payment, settledBefore map[string]bool) []db.ReconciliationItem {
+	byReference := map[string]db.Payment{}
+	for _, payment := range append(append([]db.Payment{}, referenced...), capturedOnDate...) {
+		byReference[payment.AcquirerReference] = payment
+	}
+
+	items := make([]db.ReconciliationItem, 0, len(records))
+	settled := map[int64]bool{}
+	for _, record := range records {
+		item := db.ReconciliationItem{
+			Status:            db.ReconciliationMissingOnOurSide,
+			AcquirerReference: record.AuthorizationReference,
+			BankReference:     record.Reference,
+			Currency:          record.Currency,
+			BankAmount:        record.Amount,
+		}
+
+		// Captures settled twice are missing on our side the second time
+		payment, ok := byReference[record.AuthorizationReference]
+		if ok && !settled[payment.ID] {
+			item.PaymentID, item.MerchantID, item.PaymentAmount = payment.PaymentID, payment.MerchantID, payment.Amount
+			if captured(payment) {
+				settled[payment.ID] = true
+				item.Status = db.ReconciliationMatched
+				if payment.Amount != record.Amount || payment.Currency != record.Currency {
+					item.Status = db.ReconciliationAmountMismatch
+				}
+			}
+		}
+		items = append(items, item)
+	}
+
+	sort.Slice(capturedOnDate, func(i, j int) bool { return capturedOnDate[i].ID < capturedOnDate[j].ID })
+	for _, payment := range capturedOnDate {
+		if settled[payment.ID] || settledBefore[payment.AcquirerReference] {
+			continue
+		}
+		items = append(items, db.ReconciliationItem{
+			Status:            db.ReconciliationMissingAtBank,
+			AcquirerReference: payment.AcquirerReference,
+			PaymentID:         payment.PaymentID,
+			MerchantID:        payment.MerchantID,
+			Currency:          payment.Currency,
+			PaymentAmount:     payment.Amount,
+		})
+	}
+	return items
+}
+
+// countReconciliation adds up the items of a run by status
+func countReconciliation(run *db.ReconciliationRun, items []db.ReconciliationItem) {
+	for _, item := range items {
+		switch item.Status {
+		case db.ReconciliationMatched:
+			run.Matched++
+		case db.ReconciliationMissingOnOurSide:
+			run.MissingOnOurSide++
+		case db.ReconciliationMissingAtBank:
+			run.MissingAtBank++
+		case db.ReconciliationAmountMismatch:
+			run.AmountMismatches++
+		}
+	}
+}
+
+// ReconcileSettlementFile checks the settlement file of the acquirer for a day against the payments and
+// stores the result. Files that cannot be read are refused as a whole.
+func ReconcileSettlementFile(date time.Time, fileName string, file io.Reader) (models.ReconciliationReport, error) {
+	records, err := acquirer.ReadSettlementFile(file)
+	if err != nil {
+		return models.ReconciliationReport{}, err
+	}
+
+	var referenced []db.Payment
+	for start := 0; start < len(records); start += reconciliationChunkSize {
+		end := start + reconciliationChunkSize
+		if end > len(records) {
+			end = len(records)
+		}
+		references := make([]string, 0, end-start)
+		for _, record := range records[start:end] {
+			references = append(references, record.AuthorizationReference)
+		}
+
+		var chunk []db.Payment
+		if err := DbConnection.Where("mode = ? AND acquirer_reference IN (?)", db.APIKeyModeLive, references).Find(&chunk).Error; err != nil {
+			logger.Error("failed to find settled payments", zap.Error(err))
+			return models.ReconciliationReport{}, fmt.Errorf("failed to reconcile settlement file")
+		}
+		referenced = append(referenced, chunk...)
+	}
+
+	from := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	var capturedOnDate []db.Payment
+	if err := DbConnection.Where("mode = ? AND captured_at >= ? AND captured_at < ?", db.APIKeyModeLive, from, from.AddDate(0, 0, 1)).Find(&capturedOnDate).Error; err != nil {
+		logger.Error("failed to find captured payments", zap.Error(err))
+		return models.ReconciliationReport{}, fmt.Errorf("failed to reconcile settlement file")
+	}
+
+	settledBefore, err := settledOnOtherDays(from, capturedOnDate)
+	if err != nil {
+		logger.Error("failed to find payments settled on other days", zap.Error(err))
+		return models.ReconciliationReport{}, fmt.Errorf("failed to reconcile settlement file")
+	}
+
+	run := db.ReconciliationRun{
+		RunID:          uuid.New().String(),
+		SettlementDate: from,
+		FileName:       fileName,
+		LineCount:      len(records),
+	}
+	items := reconcile(records, referenced, capturedOnDate, settledBefore)
+	countReconciliation(&run, items)
+
+	tx := DbConnection.Begin()
+	if err := tx.Create(&run).Error; err != nil {
+		tx.Rollback()
+		logger.Error("failed to store reconciliation run", zap.Error(err))
+		return models.ReconciliationReport{}, fmt.Errorf("failed to reconcile settlement file")
+	}
+	for i := range items {
+		items[i].RunID = run.ID
+		if err := tx.Create(&items[i]).Error; err != nil {
+			tx.Rollback()
+			logger.Error("failed to store reconciliation items", zap.Error(err))
+			return models.ReconciliationReport{}, fmt.Errorf("failed to reconcile settlement file")
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		logger.Error("failed to store reconciliation run", zap.Error(err))
+		return models.ReconciliationReport{}, fmt.Errorf("failed to reconcile settlement file")
+	}
+
+	logger.Info("Settlement file reconciled", zap.String("run_id", run.RunID), zap.Int("lines", run.LineCount),
+		zap.Int("missing_on_our_side", run.MissingOnOurSide), zap.Int("missing_at_bank", run.MissingAtBank), zap.Int("amount_mismatches", run.AmountMismatches))
+	return models.ReconciliationReport{Run: run, Items: discrepancies(items)}, nil
+}
+
+// settledOnOtherDays returns the acquirer references of the payments that the runs of other settlement dates
+// found in their file
+func settledOnOtherDays(date time.Time, payments []db.Payment) (map[string]bool, error) {
+	settled := map[string]bool{}
+	for start := 0; start < len(payments); start += reconciliationChunkSize {
+		end := start + reconciliationChunkSize
+		if end > len(payments) {
+			end = len(payments)
+		}
+		references := make([]string, 0, end-start)
+		for _, payment := range payments[start:end] {
+			references = append(references, payment.AcquirerReference)
+		}
+
+		var items []db.ReconciliationItem
+		if err := DbConnection.Select("acquirer_reference").
+			Where("status IN (?) AND acquirer_reference IN (?)", []string{db.ReconciliationMatched, db.ReconciliationAmountMismatch}, references).
+			Where("run_id IN (SELECT id FROM reconciliation_runs WHERE settlement_date <> ?)", date).
+			Find(&items).Error; err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			settled[item.AcquirerReference] = true
+		}
+	}
+	return settled, nil
+}
+
+func discrepancies(items []db.ReconciliationItem) []db.ReconciliationItem {
+	flagged := []db.ReconciliationItem{}
+	for _, item := range items {
+		if item.Status != db.ReconciliationMatched {
+			flagged = append(flagged, item)
+		}
+	}
+	return flagged
+}
+
+// ListReconciliationRuns returns the latest reconciliation runs, newest first
+func ListReconciliationRuns() ([]db.ReconciliationRun, error) {
+	runs := []db.ReconciliationRun{}
+	if err := DbConnection.Order("id desc").Limit(reconciliationListSize).Find(&runs).Error; err != nil {
+		logger.Error("failed to list reconciliation runs", zap.Error(err))
+		return nil, fmt.Errorf("failed to list reconciliation runs")
+	}
+	return runs, nil
+}
+
+// GetReconciliationReport returns a run with its items in the status, or its discrepancies without one
+func GetReconciliationReport(runID string, status string) (models.ReconciliationReport, error) {
+	var run db.ReconciliationRun
+	if err := DbConnection.Where("run_id = ?", runID).First(&run).Error; err != nil {
+		return models.ReconciliationReport{}, ErrReconciliationRunNotFound
+	}
+
+	query := DbConnection.Where("run_id = ?", run.ID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	} else {
+		query = query.Where("status <> ?", db.ReconciliationMatched)
+	}
+	items := []db.ReconciliationItem{}
+	if err := query.Order("id asc").Find(&items).Error; err != nil {
+		logger.Error("failed to retrieve reconciliation items", zap.Error(err), zap.String("run_id", runID))
+		return models.ReconciliationReport{}, fmt.Errorf("failed to retrieve reconciliation report")
+	}
+	return models.ReconciliationReport{Run: run, Items: items}, nil
+}
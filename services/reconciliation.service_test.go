@@ -0,0 +1,62 @@
+package services
+
+import (
+	"checkout-task/acquirer"
+	"checkout-task/constants"
+	db "checkout-task/models/db"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestReconcile(t *testing.T) {
+	payments := []db.Payment{
+		{ID: 1, PaymentID: "p1", AcquirerReference: "auth_1", Amount: 1050, Currency: "EUR", Status: constants.CAPTURED},
+		{ID: 2, PaymentID: "p2", AcquirerReference: "auth_2", Amount: 2000, Currency: "EUR", Status: constants.PARTIALLYREFUNDED},
+		{ID: 3, PaymentID: "p3", AcquirerReference: "auth_3", Amount: 3000, Currency: "EUR", Status: constants.CAPTURED},
+		{ID: 4, PaymentID: "p4", AcquirerReference: "auth_4", Amount: 4000, Currency: "EUR", Status: constants.AUTHORIZED},
+	}
+	records := []acquirer.SettlementRecord{
+		{Reference: "capture_1", AuthorizationReference: "auth_1", Amount: 1050, Currency: "EUR"},
+		{Reference: "capture_2", AuthorizationReference: "auth_2", Amount: 2100, Currency: "EUR"},
+		{Reference: "capture_4", AuthorizationReference: "auth_4", Amount: 4000, Currency: "EUR"},
+		{Reference: "capture_9", AuthorizationReference: "auth_9", Amount: 1000, Currency: "EUR"},
+		{Reference: "capture_1b", AuthorizationReference: "auth_1", Amount: 1050, Currency: "EUR"},
+	}
+
+	items := reconcile(records, []db.Payment{payments[0], payments[1], payments[3]}, payments[:3], map[string]bool{})
+	statuses := make([]string, 0, len(items))
+	for _, item := range items {
+		statuses = append(statuses, item.Status)
+	}
+
+	// Test case 1: every line of the file is matched or flagged, in order, then the payments the bank left out
+	assert.Equal(t, []string{
+		db.ReconciliationMatched,
+		db.ReconciliationAmountMismatch,
+		db.ReconciliationMissingOnOurSide,
+		db.ReconciliationMissingOnOurSide,
+		db.ReconciliationMissingOnOurSide,
+		db.ReconciliationMissingAtBank,
+	}, statuses)
+
+	// Test case 2: mismatches show both amounts, captures of payments we did not capture name the payment
+	assert.Equal(t, int64(2100), items[1].BankAmount)
+	assert.Equal(t, int64(2000), items[1].PaymentAmount)
+	assert.Equal(t, "p4", items[2].PaymentID)
+	assert.Equal(t, "", items[3].PaymentID)
+	assert.Equal(t, "p3", items[5].PaymentID)
+
+	// Test case 3: the run counts its items
+	run := db.ReconciliationRun{}
+	countReconciliation(&run, items)
+	assert.Equal(t, 1, run.Matched)
+	assert.Equal(t, 3, run.MissingOnOurSide)
+	assert.Equal(t, 1, run.MissingAtBank)
+	assert.Equal(t, 1, run.AmountMismatches)
+	assert.Len(t, discrepancies(items), 5)
+
+	// Test case 4: captures the acquirer settled on the day before are not missing at the bank
+	items = reconcile(records[:1], payments[:1], payments[:3], map[string]bool{"auth_2": true, "auth_3": true})
+	assert.Len(t, items, 1)
+	assert.Equal(t, db.ReconciliationMatched, items[0].Status)
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"checkout-task/acquirer"
+	"checkout-task/constants"
+	"checkout-task/logger"
+	db "checkout-task/models/db"
+	"context"
+	"go.uber.org/zap"
+	"time"
+)
+
+const recoveryBatchSize = 100
+
+// minRecoveryAge is how long an operation waits for its answer before RecoverBankOperations
+// asks the acquirer about it. It is raised to twice BANK_TIMEOUT so calls still running are left alone.
+const minRecoveryAge = time.Minute
+
+// captureReference and voidReference identify the capture and void of a payment at the acquirer,
+// refunds are identified by their refund id
+func captureReference(payment db.Payment) string {
+	return "cap_" + payment.PaymentID
+}
+
+func voidReference(payment db.Payment) string {
+	return "void_" + payment.PaymentID
+}
+
+func recoveryAge() time.Duration {
+	age := 2 * time.Duration(Config.BankTimeoutMillis) * time.Millisecond
+	if age < minRecoveryAge {
+		return minRecoveryAge
+	}
+	return age
+}
+
+// lookupOutcome turns the answer of the acquirer about an operation into the response it would have given.
+// Operations it never received are declined, ok is false when the acquirer could not be asked.
+func lookupOutcome(response acquirer.Response, err error) (acquirer.Response, bool) {
+	if err == acquirer.ErrOperationNotFound {
+		return acquirer.Response{
+			Approved:      false,
+			DeclineCode:   acquirer.DeclineAcquirerUnavailable,
+			DeclineReason: "operation never reached the acquirer",
+		}, true
+	}
+	return response, err == nil
+}
+
+// RecoverBankOperations asks the acquirer every interval about the authorizations, captures, voids and refunds
+// that never got its answer, because it did not respond or the answer could not be stored, and applies the outcome
+func RecoverBankOperations(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for {
+		recoverAuthorizations()
+		recoverPaymentOperations()
+		recoverRefunds()
+		<-ticker.C
+	}
+}
+
+// recoverAuthorizations settles payments still waiting for their authorization, including Pending ones the
+// gateway stopped working on before it asked the acquirer. The acquirer knows them by their payment id.
+func recoverAuthorizations() {
+	var payments []db.Payment
+	if err := DbConnection.Where("status IN (?) AND updated_at < ?", []string{constants.PENDING, constants.AUTHORIZATIONREQUESTED}, time.Now().Add(-recoveryAge())).
+		Order("id asc").Limit(recoveryBatchSize).Find(&payments).Error; err != nil {
+		logger.Error("failed to find payments waiting for their authorization", zap.Error(err))
+		return
+	}
+
+	for _, payment := range payments {
+		bankResponse, ok := lookupOutcome(acquirerFor(payment.Mode).LookupAuthorization(context.Background(), payment.PaymentID))
+		if !ok {
+			logger.Error("failed to look up authorization at the acquirer", zap.String("payment_id", payment.PaymentID))
+			continue
+		}
+		resolved, err := resolveAuthorization(payment.ID, bankResponse, nil)
+		if err != nil {
+			logger.Error("failed to recover authorization", zap.Error(err), zap.String("payment_id", payment.PaymentID))
+			continue
+		}
+		logger.Info("Authorization recovered", zap.String("payment_id", payment.PaymentID), zap.String("status", resolved.Status))
+	}
+}
+
+func recoverPaymentOperations() {
+	var payments []db.Payment
+	if err := DbConnection.Where("status IN (?) AND updated_at < ?", []string{constants.CAPTUREREQUESTED, constants.VOIDREQUESTED}, time.Now().Add(-recoveryAge())).
+		Order("id asc").Limit(recoveryBatchSize).Find(&payments).Error; err != nil {
+		logger.Error("failed to find payments waiting for the acquirer", zap.Error(err))
+		return
+	}
+
+	for _, payment := range payments {
+		reference := captureReference(payment)
+		if payment.Status == constants.VOIDREQUESTED {
+			reference = voidReference(payment)
+		}
+
+		bankResponse, ok := lookupOutcome(acquirerFor(payment.Mode).Lookup(context.Background(), reference))
+		if !ok {
+			logger.Error("failed to look up operation at the acquirer", zap.String("payment_id", payment.PaymentID), zap.String("status", payment.Status))
+			continue
+		}
+		if _, err := resolvePaymentOperation(payment.ID, payment.Status, bankResponse); err != nil {
+			logger.Error("failed to recover payment operation", zap.Error(err), zap.String("payment_id", payment.PaymentID))
+			continue
+		}
+		logger.Info("Payment operation recovered", zap.String("payment_id", payment.PaymentID), zap.Bool("approved", bankResponse.Approved))
+	}
+}
+
+func recoverRefunds() {
+	var refunds []db.Refund
+	if err := DbConnection.Where("status = ? AND created_at < ?", constants.PENDING, time.Now().Add(-recoveryAge())).
+		Order("id asc").Limit(recoveryBatchSize).Find(&refunds).Error; err != nil {
+		logger.Error("failed to find refunds waiting for the acquirer", zap.Error(err))
+		return
+	}
+
+	for _, refund := range refunds {
+		var payment db.Payment
+		if err := DbConnection.Where("id = ?", refund.PaymentID).First(&payment).Error; err != nil {
+			logger.Error("failed to retrieve payment of refund", zap.Error(err), zap.String("refund_id", refund.RefundID))
+			continue
+		}
+
+		bankResponse, ok := lookupOutcome(acquirerFor(payment.Mode).Lookup(context.Background(), refund.RefundID))
+		if !ok {
+			logger.Error("failed to look up refund at the acquirer", zap.String("refund_id", refund.RefundID))
+			continue
+		}
+		if _, err := resolveRefund(refund.ID, bankResponse); err != nil {
+			logger.Error("failed to recover refund", zap.Error(err), zap.String("refund_id", refund.RefundID))
+			continue
+		}
+		logger.Info("Refund recovered", zap.String("refund_id", refund.RefundID), zap.Bool("approved", bankResponse.Approved))
+	}
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"checkout-task/acquirer"
+	"checkout-task/models"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestLookupOutcome(t *testing.T) {
+	// Test case 1: the outcome the acquirer recorded is applied as it is
+	response, ok := lookupOutcome(acquirer.Response{Approved: true, Reference: "capture_1"}, nil)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, true, response.Approved)
+	assert.Equal(t, "capture_1", response.Reference)
+
+	// Test case 2: an operation the acquirer never received is declined
+	response, ok = lookupOutcome(acquirer.Response{}, acquirer.ErrOperationNotFound)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, false, response.Approved)
+	assert.Equal(t, acquirer.DeclineAcquirerUnavailable, response.DeclineCode)
+
+	// Test case 3: the acquirer could not be asked, the operation keeps waiting
+	_, ok = lookupOutcome(acquirer.Response{}, errors.New("acquirer unreachable"))
+	assert.Equal(t, false, ok)
+}
+
+func TestRecoveryAge(t *testing.T) {
+	defer func(config *models.EnvConfig) { Config = config }(Config)
+	Config = &models.EnvConfig{}
+
+	// Test case 1: short bank timeouts wait the minimum
+	Config.BankTimeoutMillis = 5000
+	assert.Equal(t, time.Minute, recoveryAge())
+
+	// Test case 2: calls that may still be running are left alone
+	Config.BankTimeoutMillis = 45000
+	assert.Equal(t, 90*time.Second, recoveryAge())
+}
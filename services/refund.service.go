@@ -0,0 +1,187 @@
+package services
+
+import (
+	"checkout-task/acquirer"
+	"checkout-task/constants"
+	"checkout-task/logger"
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"checkout-task/utils"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+)
+
+// RefundPayment refunds the given amount of a captured payment back to the card.
+// A zero amount refunds whatever has not been refunded yet. The refund is stored Pending before the
+// acquirer is asked, refunds the answer never reached are resolved by RecoverBankOperations.
+func RefundPayment(merchantID int64, mode string, paymentID string, amount int64) (models.RefundResponse, error) {
+	payment, refund, err := requestRefund(merchantID, mode, paymentID, amount)
+	if err != nil {
+		return models.RefundResponse{}, err
+	}
+
+	// Ask the acquiring bank to credit the card
+	bankResponse, err := acquirerFor(payment.Mode).Refund(context.Background(), payment.AcquirerReference, acquirer.AmountRequest{
+		OperationReference: refund.RefundID,
+		Amount:             refund.Amount,
+	})
+	if err != nil {
+		logger.Error("Refund failed while contacting the acquirer", zap.Error(err), zap.String("payment_id", paymentID))
+		return refundResponse(payment, refund), nil
+	}
+
+	return resolveRefund(refund.ID, bankResponse)
+}
+
+// requestRefund stores a Pending refund of the payment and commits. Pending refunds count against the
+// amount left to refund, so concurrent refunds cannot exceed the captured amount.
+func requestRefund(merchantID int64, mode string, paymentID string, amount int64) (db.Payment, db.Refund, error) {
+	tx := DbConnection.Begin()
+
+	var payment db.Payment
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("payment_id = ? AND merchant_id = ? AND mode = ?", paymentID, merchantID, mode).First(&payment).Error; err != nil {
+		tx.Rollback()
+		logger.Error("failed to retrieve payment details", zap.Error(err), zap.String("payment_id", paymentID))
+		return db.Payment{}, db.Refund{}, fmt.Errorf("failed to retrieve payment details")
+	}
+
+	if !CanTransition(payment.Status, constants.REFUNDED) {
+		tx.Rollback()
+		return db.Payment{}, db.Refund{}, fmt.Errorf("payment cannot be refunded in status %s", payment.Status)
+	}
+
+	var pending int64
+	if err := tx.Model(&db.Refund{}).Where("payment_id = ? AND status = ?", payment.ID, constants.PENDING).
+		Select("COALESCE(SUM(amount), 0)").Row().Scan(&pending); err != nil {
+		tx.Rollback()
+		logger.Error("failed to retrieve refund details", zap.Error(err), zap.String("payment_id", paymentID))
+		return db.Payment{}, db.Refund{}, fmt.Errorf("failed to retrieve refund details")
+	}
+
+	remaining := payment.Amount - payment.RefundedAmount - pending
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount <= 0 || amount > remaining {
+		tx.Rollback()
+		return db.Payment{}, db.Refund{}, fmt.Errorf("refund amount exceeds the %s %s left to refund", utils.FormatMinorUnits(remaining, payment.Currency), payment.Currency)
+	}
+
+	refund := db.Refund{
+		RefundID:  uuid.New().String(),
+		PaymentID: payment.ID,
+		Amount:    amount,
+		Currency:  payment.Currency,
+		Status:    constants.PENDING,
+	}
+	if err := tx.Create(&refund).Error; err != nil {
+		tx.Rollback()
+		logger.Error("failed to store refund details", zap.Error(err))
+		return db.Payment{}, db.Refund{}, fmt.Errorf("failed to store refund details")
+	}
+	if err := tx.Commit().Error; err != nil {
+		logger.Error("failed to store refund details", zap.Error(err))
+		return db.Payment{}, db.Refund{}, fmt.Errorf("failed to store refund details")
+	}
+
+	return payment, refund, nil
+}
+
+// resolveRefund applies the acquirer's answer to a Pending refund and, when it went through, to its payment.
+// Refunds resolved meanwhile are left as they are.
+func resolveRefund(id int64, bankResponse acquirer.Response) (models.RefundResponse, error) {
+	tx := DbConnection.Begin()
+
+	var refund db.Refund
+	if err := tx.Where("id = ?", id).First(&refund).Error; err != nil {
+		tx.Rollback()
+		logger.Error("failed to retrieve refund details", zap.Error(err), zap.Int64("id", id))
+		return models.RefundResponse{}, fmt.Errorf("failed to retrieve refund details")
+	}
+
+	// Lock the payment, then read the refund again as the lock makes it final
+	var payment db.Payment
+	if err := tx.Set("gorm:query_option", "FOR UPDATE").Where("id = ?", refund.PaymentID).First(&payment).Error; err != nil {
+		tx.Rollback()
+		logger.Error("failed to retrieve payment details", zap.Error(err), zap.String("refund_id", refund.RefundID))
+		return models.RefundResponse{}, fmt.Errorf("failed to retrieve payment details")
+	}
+	if err := tx.Where("id = ?", id).First(&refund).Error; err != nil {
+		tx.Rollback()
+		logger.Error("failed to retrieve refund details", zap.Error(err), zap.Int64("id", id))
+		return models.RefundResponse{}, fmt.Errorf("failed to retrieve refund details")
+	}
+	if refund.Status != constants.PENDING {
+		tx.Rollback()
+		return refundResponse(payment, refund), nil
+	}
+
+	refund.Status = constants.SUCCESS
+	if !bankResponse.Approved {
+		logger.Error("Refund declined by the acquirer", zap.String("reason", bankResponse.DeclineReason), zap.String("payment_id", payment.PaymentID))
+		refund.Status = constants.FAILURE
+	}
+	refund.AcquirerReference = bankResponse.Reference
+
+	if err := tx.Model(&refund).UpdateColumns(map[string]interface{}{
+		"status":             refund.Status,
+		"acquirer_reference": refund.AcquirerReference,
+	}).Error; err != nil {
+		tx.Rollback()
+		logger.Error("failed to store refund details", zap.Error(err))
+		return models.RefundResponse{}, fmt.Errorf("failed to store refund details")
+	}
+
+	if refund.Status == constants.SUCCESS {
+		status := constants.PARTIALLYREFUNDED
+		if payment.RefundedAmount+refund.Amount == payment.Amount {
+			status = constants.REFUNDED
+		}
+
+		if err := tx.Model(&db.Payment{}).Where("id = ?", payment.ID).UpdateColumn("refunded_amount", gorm.Expr("refunded_amount + ?", refund.Amount)).Error; err != nil {
+			tx.Rollback()
+			logger.Error("failed to store payment details", zap.Error(err))
+			return models.RefundResponse{}, fmt.Errorf("failed to store payment details")
+		}
+
+		payment.RefundedAmount += refund.Amount
+		if err := TransitionPayment(tx, &payment, status, "refund "+refund.RefundID); err != nil {
+			tx.Rollback()
+			logger.Error("failed to store payment details", zap.Error(err))
+			return models.RefundResponse{}, fmt.Errorf("failed to store payment details")
+		}
+		if err := postRefundLedger(tx, payment, refund); err != nil {
+			tx.Rollback()
+			logger.Error("failed to post refund to the ledger", zap.Error(err), zap.String("refund_id", refund.RefundID))
+			return models.RefundResponse{}, fmt.Errorf("failed to store refund details")
+		}
+	}
+
+	if err := publishRefundEvent(tx, payment, refund, refund.Status == constants.SUCCESS); err != nil {
+		tx.Rollback()
+		logger.Error("failed to store refund details", zap.Error(err))
+		return models.RefundResponse{}, fmt.Errorf("failed to store refund details")
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		logger.Error("failed to store refund details", zap.Error(err))
+		return models.RefundResponse{}, fmt.Errorf("failed to store refund details")
+	}
+	DeletePaymentDetailsFromCache(payment.PaymentID)
+
+	logger.Info("Refund processed", zap.String("payment_id", payment.PaymentID), zap.String("refund_id", refund.RefundID), zap.String("status", refund.Status))
+	return refundResponse(payment, refund), nil
+}
+
+func refundResponse(payment db.Payment, refund db.Refund) models.RefundResponse {
+	return models.RefundResponse{
+		RefundID:          refund.RefundID,
+		PaymentIdentifier: payment.PaymentID,
+		Amount:            refund.Amount,
+		Currency:          refund.Currency,
+		Status:            refund.Status,
+	}
+}
@@ -0,0 +1,438 @@
+package services
+
+import (
+	"checkout-task/acquirer"
+	"checkout-task/constants"
+	"checkout-task/logger"
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+	"time"
+)
+
+const (
+	// maxPayoutAttempts is how often a payout is sent to the bank before it fails
+	maxPayoutAttempts = 5
+	// payoutBaseBackoff is the wait after the first failed transfer, it doubles with every attempt
+	payoutBaseBackoff  = 10 * time.Minute
+	settlementListSize = 100
+)
+
+var ErrSettlementBatchNotFound = errors.New("settlement batch not found")
+
+// settlementWindow returns the settlement date of the last day that ended and its cutoff, midnight UTC.
+// Everything captured or refunded before the cutoff and not settled yet belongs to that date.
+func settlementWindow(now time.Time) (time.Time, time.Time) {
+	now = now.UTC()
+	cutoff := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return cutoff.AddDate(0, 0, -1), cutoff
+}
+
+// payoutBackoff is the wait before sending again a payout the bank did not pay attempts times
+func payoutBackoff(attempts int) time.Duration {
+	return payoutBaseBackoff << (attempts - 1)
+}
+
+// settlementLines makes a line of every payment and refund of a batch and adds them up on the batch.
+// paymentIDs gives the payment of each refund.
+func settlementLines(batch *db.SettlementBatch, payments []db.Payment, refunds []db.Refund, paymentIDs map[int64]string) []db.SettlementLine {
+	lines := make([]db.SettlementLine, 0, len(payments)+len(refunds))
+	for _, payment := range payments {
+		lines = append(lines, db.SettlementLine{
+			BatchID:   batch.ID,
+			Type:      db.SettlementLinePayment,
+			PaymentID: payment.PaymentID,
+			Amount:    payment.Amount,
+			Fee:       payment.Fee,
+			Net:       payment.Amount - payment.Fee,
+		})
+		batch.PaymentCount++
+		batch.Gross += payment.Amount
+		batch.Fees += payment.Fee
+	}
+	for _, refund := range refunds {
+		lines = append(lines, db.SettlementLine{
+			BatchID:   batch.ID,
+			Type:      db.SettlementLineRefund,
+			PaymentID: paymentIDs[refund.PaymentID],
+			RefundID:  refund.RefundID,
+			Amount:    refund.Amount,
+			Net:       -refund.Amount,
+		})
+		batch.RefundCount++
+		batch.Refunds += refund.Amount
+	}
+	batch.Net = batch.Gross - batch.Refunds - batch.Fees
+	return lines
+}
+
+// settlementLedgerLines settles the refunds of the batch with the acquirer and moves what its payments and the
+// refunds taken from the pending funds left there to the merchant's available funds, or back when those refunds
+// outweigh the payments. Refunds of payments settled earlier came out of the available funds already.
+func settlementLedgerLines(batch db.SettlementBatch, pendingRefunds int64) []ledgerLine {
+	var lines []ledgerLine
+	if batch.Refunds > 0 {
+		lines = append(lines, debit(db.AccountRefunds, batch.Refunds), credit(db.AccountAcquirerClearing, batch.Refunds))
+	}
+	settled := batch.Gross - batch.Fees - pendingRefunds
+	if settled > 0 {
+		lines = append(lines, debit(db.AccountMerchantPending, settled), credit(db.AccountMerchantAvailable, settled))
+	}
+	if settled < 0 {
+		lines = append(lines, debit(db.AccountMerchantAvailable, -settled), credit(db.AccountMerchantPending, -settled))
+	}
+	return lines
+}
+
+// ProcessSettlements settles the last day that ended and sends the payouts that are due every interval
+func ProcessSettlements(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for {
+		settlePayments(time.Now())
+		sendDuePayouts()
+		<-ticker.C
+	}
+}
+
+// settlePayments makes a batch for every merchant and currency with live payments or refunds to settle.
+// Merchants settled for the date already get what is left in the next batch.
+func settlePayments(now time.Time) {
+	date, cutoff := settlementWindow(now)
+
+	rows, err := DbConnection.Raw(`SELECT merchant_id, currency FROM payments
+		WHERE mode = ? AND settlement_batch_id = 0 AND captured_at < ?
+		UNION
+		SELECT payments.merchant_id, refunds.currency FROM refunds JOIN payments ON payments.id = refunds.payment_id
+		WHERE payments.mode = ? AND refunds.settlement_batch_id = 0 AND refunds.status = ? AND refunds.created_at < ? AND payments.captured_at IS NOT NULL`,
+		db.APIKeyModeLive, cutoff, db.APIKeyModeLive, constants.SUCCESS, cutoff).Rows()
+	if err != nil {
+		logger.Error("failed to find payments to settle", zap.Error(err))
+		return
+	}
+	type settlementKey struct {
+		MerchantID int64
+		Currency   string
+	}
+	var keys []settlementKey
+	for rows.Next() {
+		var key settlementKey
+		if err := rows.Scan(&key.MerchantID, &key.Currency); err != nil {
+			rows.Close()
+			logger.Error("failed to find payments to settle", zap.Error(err))
+			return
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+
+	for _, key := range keys {
+		var settled int
+		if err := DbConnection.Model(&db.SettlementBatch{}).
+			Where("merchant_id = ? AND currency = ? AND settlement_date = ?", key.MerchantID, key.Currency, date.Format("2006-01-02")).
+			Count(&settled).Error; err != nil || settled > 0 {
+			continue
+		}
+		if err := createSettlementBatch(key.MerchantID, key.Currency, date, cutoff); err != nil {
+			logger.Error("failed to settle payments", zap.Error(err), zap.Int64("merchant_id", key.MerchantID), zap.String("currency", key.Currency))
+		}
+	}
+}
+
+// createSettlementBatch settles the payments and refunds of the merchant in the currency, posts the batch to
+// the ledger and pays the merchant's available funds out. Everything commits together. The unique index on
+// merchant, currency and date leaves a merchant another worker is settling for the date alone.
+func createSettlementBatch(merchantID int64, currency string, date time.Time, cutoff time.Time) error {
+	tx := DbConnection.Begin()
+
+	batch := db.SettlementBatch{
+		BatchID:        uuid.New().String(),
+		MerchantID:     merchantID,
+		Currency:       currency,
+		SettlementDate: date,
+		CutoffAt:       cutoff,
+	}
+	if err := tx.Create(&batch).Error; err != nil {
+		tx.Rollback()
+		if uniqueViolation(err) {
+			logger.Info("Merchant already settled for the date", zap.Int64("merchant_id", merchantID), zap.String("currency", currency))
+			return nil
+		}
+		return err
+	}
+
+	// Claim what the batch settles, rows claimed by another batch meanwhile are left out
+	if err := tx.Model(&db.Payment{}).
+		Where("merchant_id = ? AND mode = ? AND currency = ? AND settlement_batch_id = 0 AND captured_at < ?", merchantID, db.APIKeyModeLive, currency, cutoff).
+		UpdateColumn("settlement_batch_id", batch.ID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Model(&db.Refund{}).
+		Where("currency = ? AND settlement_batch_id = 0 AND status = ? AND created_at < ?", currency, constants.SUCCESS, cutoff).
+		Where("payment_id IN (SELECT id FROM payments WHERE merchant_id = ? AND mode = ? AND captured_at IS NOT NULL)", merchantID, db.APIKeyModeLive).
+		UpdateColumn("settlement_batch_id", batch.ID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var payments []db.Payment
+	if err := tx.Where("settlement_batch_id = ?", batch.ID).Order("id asc").Find(&payments).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	var refunds []db.Refund
+	if err := tx.Where("settlement_batch_id = ?", batch.ID).Order("id asc").Find(&refunds).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if len(payments) == 0 && len(refunds) == 0 {
+		tx.Rollback()
+		return nil
+	}
+
+	paymentIDs := map[int64]string{}
+	if len(refunds) > 0 {
+		ids := make([]int64, 0, len(refunds))
+		for _, refund := range refunds {
+			ids = append(ids, refund.PaymentID)
+		}
+		var refunded []db.Payment
+		if err := tx.Select("id, payment_id").Where("id IN (?)", ids).Find(&refunded).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		for _, payment := range refunded {
+			paymentIDs[payment.ID] = payment.PaymentID
+		}
+	}
+
+	for _, line := range settlementLines(&batch, payments, refunds, paymentIDs) {
+		if err := tx.Create(&line).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Model(&db.SettlementBatch{}).Where("id = ?", batch.ID).UpdateColumns(map[string]interface{}{
+		"payment_count": batch.PaymentCount,
+		"refund_count":  batch.RefundCount,
+		"gross":         batch.Gross,
+		"refunds":       batch.Refunds,
+		"fees":          batch.Fees,
+		"net":           batch.Net,
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// Only the refunds the ledger took from the pending funds are settled out of them
+	refundIDs := make([]string, 0, len(refunds))
+	for _, refund := range refunds {
+		refundIDs = append(refundIDs, refund.RefundID)
+	}
+	pendingRefunds, err := ledgerDebited(tx, merchantID, db.AccountMerchantPending, currency, db.LedgerRefund, refundIDs)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if lines := settlementLedgerLines(batch, pendingRefunds); len(lines) > 0 {
+		if err := postLedgerTransaction(tx, merchantID, currency, db.LedgerSettlement, batch.BatchID, batch.BatchID, lines); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// Pay out everything available, which makes up for batches refunds outweighed
+	available, err := ledgerBalance(tx, merchantID, db.AccountMerchantAvailable, currency)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if available > 0 {
+		payout := db.Payout{
+			PayoutID:      uuid.New().String(),
+			MerchantID:    merchantID,
+			BatchID:       batch.ID,
+			Amount:        available,
+			Currency:      currency,
+			Status:        db.PayoutStatusPending,
+			NextAttemptAt: time.Now(),
+		}
+		if err := tx.Create(&payout).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := postLedgerTransaction(tx, merchantID, currency, db.LedgerPayout, payout.PayoutID, payout.PayoutID, []ledgerLine{
+			debit(db.AccountMerchantAvailable, payout.Amount),
+			credit(db.AccountAcquirerClearing, payout.Amount),
+		}); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	logger.Info("Settlement batch created", zap.String("batch_id", batch.BatchID), zap.Int64("net", batch.Net), zap.String("currency", currency))
+	return nil
+}
+
+func sendDuePayouts() {
+	var payouts []db.Payout
+	if err := DbConnection.Where("status = ? AND next_attempt_at <= ?", db.PayoutStatusPending, time.Now()).
+		Order("next_attempt_at asc").Limit(settlementListSize).Find(&payouts).Error; err != nil {
+		logger.Error("failed to find due payouts", zap.Error(err))
+		return
+	}
+
+	for _, payout := range payouts {
+		// Push the next attempt back while sending, so no other worker sends the payout meanwhile
+		result := DbConnection.Model(&db.Payout{}).
+			Where("id = ? AND status = ? AND next_attempt_at = ?", payout.ID, db.PayoutStatusPending, payout.NextAttemptAt).
+			UpdateColumn("next_attempt_at", time.Now().Add(payoutBaseBackoff))
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+		if err := sendPayout(payout); err != nil {
+			logger.Error("failed to send payout", zap.Error(err), zap.String("payout_id", payout.PayoutID))
+		}
+	}
+}
+
+// sendPayout asks the bank to transfer the payout. Payouts the bank does not pay are sent again with backoff
+// until maxPayoutAttempts, then they fail and their amount goes back to the merchant's available funds.
+func sendPayout(payout db.Payout) error {
+	var merchant db.Merchant
+	if err := DbConnection.Where("id = ?", payout.MerchantID).First(&merchant).Error; err != nil {
+		return err
+	}
+
+	response, err := Bank.Transfer(context.Background(), acquirer.TransferRequest{
+		Reference:   payout.PayoutID,
+		Beneficiary: merchant.MerchantID,
+		Amount:      payout.Amount,
+		Currency:    payout.Currency,
+	})
+	now := time.Now()
+	update := map[string]interface{}{"attempts": payout.Attempts + 1, "last_error": ""}
+	switch {
+	case err == nil && response.Approved:
+		update["status"] = db.PayoutStatusPaid
+		update["bank_reference"] = response.Reference
+		update["paid_at"] = now
+	case err != nil:
+		update["last_error"] = err.Error()
+	default:
+		update["last_error"] = fmt.Sprintf("transfer declined: %s", response.DeclineReason)
+	}
+	if update["status"] == nil {
+		if payout.Attempts+1 >= maxPayoutAttempts {
+			update["status"] = db.PayoutStatusFailed
+		} else {
+			update["next_attempt_at"] = now.Add(payoutBackoff(payout.Attempts + 1))
+		}
+	}
+
+	tx := DbConnection.Begin()
+	if err := tx.Model(&db.Payout{}).Where("id = ?", payout.ID).UpdateColumns(update).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if update["status"] == db.PayoutStatusFailed {
+		if err := postLedgerTransaction(tx, payout.MerchantID, payout.Currency, db.LedgerReversal, payout.PayoutID, payout.PayoutID, []ledgerLine{
+			debit(db.AccountAcquirerClearing, payout.Amount),
+			credit(db.AccountMerchantAvailable, payout.Amount),
+		}); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	logger.Info("Payout sent", zap.String("payout_id", payout.PayoutID), zap.Any("status", update["status"]), zap.Any("error", update["last_error"]))
+	return nil
+}
+
+func settlementBatchResponse(tx *gorm.DB, batch db.SettlementBatch) (models.SettlementBatchResponse, error) {
+	response := models.SettlementBatchResponse{SettlementBatch: batch}
+
+	var payout db.Payout
+	err := tx.Where("batch_id = ?", batch.ID).First(&payout).Error
+	if err == nil {
+		response.Payout = &payout
+	} else if !gorm.IsRecordNotFoundError(err) {
+		return response, err
+	}
+	return response, nil
+}
+
+// ListSettlementBatches returns the latest batches of the merchant with their payouts, newest first,
+// optionally only those in a currency
+func ListSettlementBatches(merchantID int64, currency string) ([]models.SettlementBatchResponse, error) {
+	query := DbConnection.Where("merchant_id = ?", merchantID)
+	if currency != "" {
+		query = query.Where("currency = ?", currency)
+	}
+	var batches []db.SettlementBatch
+	if err := query.Order("settlement_date desc, id desc").Limit(settlementListSize).Find(&batches).Error; err != nil {
+		logger.Error("failed to list settlement batches", zap.Error(err))
+		return nil, fmt.Errorf("failed to list settlement batches")
+	}
+
+	responses := make([]models.SettlementBatchResponse, 0, len(batches))
+	for _, batch := range batches {
+		response, err := settlementBatchResponse(DbConnection, batch)
+		if err != nil {
+			logger.Error("failed to list settlement batches", zap.Error(err))
+			return nil, fmt.Errorf("failed to list settlement batches")
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+func findSettlementBatch(merchantID int64, batchID string) (db.SettlementBatch, error) {
+	var batch db.SettlementBatch
+	if err := DbConnection.Where("batch_id = ? AND merchant_id = ?", batchID, merchantID).First(&batch).Error; err != nil {
+		return db.SettlementBatch{}, ErrSettlementBatchNotFound
+	}
+	return batch, nil
+}
+
+// GetSettlementBatch returns a batch of the merchant with its payout
+func GetSettlementBatch(merchantID int64, batchID string) (models.SettlementBatchResponse, error) {
+	batch, err := findSettlementBatch(merchantID, batchID)
+	if err != nil {
+		return models.SettlementBatchResponse{}, err
+	}
+
+	response, err := settlementBatchResponse(DbConnection, batch)
+	if err != nil {
+		logger.Error("failed to retrieve settlement batch", zap.Error(err), zap.String("batch_id", batchID))
+		return models.SettlementBatchResponse{}, fmt.Errorf("failed to retrieve settlement batch")
+	}
+	return response, nil
+}
+
+// ListSettlementLines returns the payments and refunds settled in a batch of the merchant
+func ListSettlementLines(merchantID int64, batchID string) ([]db.SettlementLine, error) {
+	batch, err := findSettlementBatch(merchantID, batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []db.SettlementLine{}
+	if err := DbConnection.Where("batch_id = ?", batch.ID).Order("id asc").Find(&lines).Error; err != nil {
+		logger.Error("failed to list settlement lines", zap.Error(err), zap.String("batch_id", batchID))
+		return nil, fmt.Errorf("failed to list settlement lines")
+	}
+	return lines, nil
+}
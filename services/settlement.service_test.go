@@ -0,0 +1,73 @@
+package services
+
+import (
+	db "checkout-task/models/db"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestSettlementWindow(t *testing.T) {
+	// Test case 1: the day that ended at the last midnight UTC is settled
+	date, cutoff := settlementWindow(time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC), date)
+	assert.Equal(t, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), cutoff)
+
+	// Test case 2: other time zones settle by the UTC day
+	date, _ = settlementWindow(time.Date(2026, 10, 18, 1, 0, 0, 0, time.FixedZone("CEST", 2*60*60)))
+	assert.Equal(t, time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC), date)
+}
+
+func TestSettlementLines(t *testing.T) {
+	batch := db.SettlementBatch{ID: 7}
+	payments := []db.Payment{
+		{PaymentID: "p1", Amount: 10000, Fee: 165},
+		{PaymentID: "p2", Amount: 2000, Fee: 53},
+	}
+	refunds := []db.Refund{{RefundID: "r1", PaymentID: 3, Amount: 500}}
+
+	lines := settlementLines(&batch, payments, refunds, map[int64]string{3: "p0"})
+
+	// Test case 1: a line per payment and refund, refunds count against the merchant
+	assert.Len(t, lines, 3)
+	assert.Equal(t, int64(9835), lines[0].Net)
+	assert.Equal(t, db.SettlementLineRefund, lines[2].Type)
+	assert.Equal(t, "p0", lines[2].PaymentID)
+	assert.Equal(t, int64(-500), lines[2].Net)
+	assert.Equal(t, int64(7), lines[2].BatchID)
+
+	// Test case 2: the batch nets refunds and fees out of the captured amounts
+	assert.Equal(t, 2, batch.PaymentCount)
+	assert.Equal(t, 1, batch.RefundCount)
+	assert.Equal(t, int64(12000), batch.Gross)
+	assert.Equal(t, int64(218), batch.Fees)
+	assert.Equal(t, int64(11282), batch.Net)
+}
+
+func TestSettlementLedgerLines(t *testing.T) {
+	// Test case 1: the net moves from pending to available and refunds are settled with the acquirer
+	lines := settlementLedgerLines(db.SettlementBatch{Gross: 12000, Fees: 218, Refunds: 500, Net: 11282}, 500)
+	assert.NoError(t, checkBalanced(lines))
+	assert.Len(t, lines, 4)
+	assert.Equal(t, debit(db.AccountMerchantPending, 11282), lines[2])
+
+	// Test case 2: refunds outweighing the payments take the difference from the available funds
+	lines = settlementLedgerLines(db.SettlementBatch{Gross: 2000, Refunds: 3000, Net: -1000}, 3000)
+	assert.NoError(t, checkBalanced(lines))
+	assert.Equal(t, debit(db.AccountMerchantAvailable, 1000), lines[2])
+
+	// Test case 3: refunds of payments settled earlier came out of the available funds, only the payments move
+	lines = settlementLedgerLines(db.SettlementBatch{Gross: 2000, Fees: 58, Refunds: 3000, Net: -1058}, 0)
+	assert.NoError(t, checkBalanced(lines))
+	assert.Len(t, lines, 4)
+	assert.Equal(t, debit(db.AccountMerchantPending, 1942), lines[2])
+	assert.Equal(t, credit(db.AccountMerchantAvailable, 1942), lines[3])
+
+	// Test case 4: a batch netting to nothing posts nothing
+	assert.Empty(t, settlementLedgerLines(db.SettlementBatch{}, 0))
+}
+
+func TestPayoutBackoff(t *testing.T) {
+	assert.Equal(t, 10*time.Minute, payoutBackoff(1))
+	assert.Equal(t, 80*time.Minute, payoutBackoff(4))
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"checkout-task/constants"
+	db "checkout-task/models/db"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+// paymentTransitions lists the statuses a payment may move to from each status.
+// Statuses without an entry are terminal.
+var paymentTransitions = map[string][]string{
+	"":                               {constants.PENDING},
+	constants.PENDING:                {constants.AUTHORIZATIONREQUESTED, constants.AUTHORIZED, constants.DECLINED, constants.FAILED},
+	constants.AUTHORIZATIONREQUESTED: {constants.AUTHORIZED, constants.DECLINED, constants.FAILED},
+	constants.AUTHORIZED:             {constants.CAPTUREREQUESTED, constants.VOIDREQUESTED},
+	constants.CAPTUREREQUESTED:       {constants.CAPTURED, constants.AUTHORIZED},
+	constants.VOIDREQUESTED:          {constants.VOIDED, constants.AUTHORIZED},
+	constants.CAPTURED:               {constants.PARTIALLYREFUNDED, constants.REFUNDED},
+	constants.PARTIALLYREFUNDED:      {constants.PARTIALLYREFUNDED, constants.REFUNDED},
+}
+
+// CanTransition reports whether a payment may move from one status to another
+func CanTransition(from string, to string) bool {
+	for _, status := range paymentTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CreatePayment stores a new payment in the Pending status and records its first event, in payment_events
+// and in the outbox
+func CreatePayment(tx *gorm.DB, payment *db.Payment) error {
+	payment.Status = constants.PENDING
+	if err := tx.Create(payment).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Create(&db.PaymentEvent{
+		PaymentID: payment.ID,
+		ToStatus:  constants.PENDING,
+		Reason:    "payment created",
+	}).Error; err != nil {
+		return err
+	}
+
+	return publishPaymentEvent(tx, *payment)
+}
+
+// TransitionPayment moves the payment to a new status and records the transition in payment_events and the outbox.
+// All writes happen on tx, so callers holding a transaction get them committed together.
+func TransitionPayment(tx *gorm.DB, payment *db.Payment, to string, reason string) error {
+	if !CanTransition(payment.Status, to) {
+		return fmt.Errorf("payment cannot move from %s to %s", payment.Status, to)
+	}
+
+	// Only move the row if nobody changed its status since it was read
+	now := time.Now()
+	update := map[string]interface{}{"status": to, "updated_at": now}
+	if to == constants.CAPTURED {
+		update["captured_at"] = now
+	}
+	result := tx.Model(&db.Payment{}).
+		Where("id = ? AND status = ?", payment.ID, payment.Status).
+		UpdateColumns(update)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("payment status changed concurrently")
+	}
+
+	event := db.PaymentEvent{
+		PaymentID:  payment.ID,
+		FromStatus: payment.Status,
+		ToStatus:   to,
+		Reason:     reason,
+	}
+	if err := tx.Create(&event).Error; err != nil {
+		return err
+	}
+
+	from := payment.Status
+	payment.Status = to
+	payment.UpdatedAt = now
+	if to == constants.CAPTURED {
+		payment.CapturedAt = &now
+	}
+
+	// A declined capture or void returns to Authorized, which was published already
+	if to == constants.AUTHORIZED && (from == constants.CAPTUREREQUESTED || from == constants.VOIDREQUESTED) {
+		return nil
+	}
+	return publishPaymentEvent(tx, *payment)
+}
+
+// UpdatePaymentStatus runs TransitionPayment in a transaction of its own
+func UpdatePaymentStatus(payment *db.Payment, to string, reason string) error {
+	tx := DbConnection.Begin()
+	if err := TransitionPayment(tx, payment, to, reason); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// RetrievePaymentEvents returns the status history of a payment of the merchant in the mode, oldest first
+func RetrievePaymentEvents(merchantID int64, mode string, paymentID string) ([]db.PaymentEvent, error) {
+	var payment db.Payment
+	if err := DbConnection.Where("payment_id = ? AND merchant_id = ? AND mode = ?", paymentID, merchantID, mode).First(&payment).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve payment details")
+	}
+
+	var events []db.PaymentEvent
+	if err := DbConnection.Where("payment_id = ?", payment.ID).Order("id asc").Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("failed to retrieve payment events")
+	}
+
+	return events, nil
+}
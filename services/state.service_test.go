@@ -0,0 +1,44 @@
+package services
+
+import (
+	"checkout-task/constants"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	// Test case 1: happy path of a payment that is captured and refunded in two steps
+	assert.Equal(t, true, CanTransition("", constants.PENDING))
+	assert.Equal(t, true, CanTransition(constants.PENDING, constants.AUTHORIZED))
+	assert.Equal(t, true, CanTransition(constants.AUTHORIZED, constants.CAPTUREREQUESTED))
+	assert.Equal(t, true, CanTransition(constants.CAPTUREREQUESTED, constants.CAPTURED))
+	assert.Equal(t, true, CanTransition(constants.CAPTURED, constants.PARTIALLYREFUNDED))
+	assert.Equal(t, true, CanTransition(constants.PARTIALLYREFUNDED, constants.PARTIALLYREFUNDED))
+	assert.Equal(t, true, CanTransition(constants.PARTIALLYREFUNDED, constants.REFUNDED))
+
+	// Test case 2: authorizations can be voided but captures cannot
+	assert.Equal(t, true, CanTransition(constants.AUTHORIZED, constants.VOIDREQUESTED))
+	assert.Equal(t, true, CanTransition(constants.VOIDREQUESTED, constants.VOIDED))
+	assert.Equal(t, false, CanTransition(constants.CAPTURED, constants.VOIDED))
+
+	// Test case 3: the acquirer is asked before captures and voids, a decline returns to Authorized
+	assert.Equal(t, false, CanTransition(constants.AUTHORIZED, constants.CAPTURED))
+	assert.Equal(t, false, CanTransition(constants.AUTHORIZED, constants.VOIDED))
+	assert.Equal(t, true, CanTransition(constants.CAPTUREREQUESTED, constants.AUTHORIZED))
+	assert.Equal(t, true, CanTransition(constants.VOIDREQUESTED, constants.AUTHORIZED))
+	assert.Equal(t, false, CanTransition(constants.CAPTUREREQUESTED, constants.VOIDED))
+
+	// Test case 4: a payment cannot skip authorization or leave a terminal status
+	assert.Equal(t, false, CanTransition(constants.PENDING, constants.CAPTURED))
+	assert.Equal(t, false, CanTransition(constants.DECLINED, constants.AUTHORIZED))
+	assert.Equal(t, false, CanTransition(constants.VOIDED, constants.CAPTURED))
+	assert.Equal(t, false, CanTransition(constants.REFUNDED, constants.PARTIALLYREFUNDED))
+	assert.Equal(t, false, CanTransition(constants.FAILED, constants.PENDING))
+
+	// Test case 5: authorizations without an answer wait for the outcome the acquirer recorded
+	assert.Equal(t, true, CanTransition(constants.PENDING, constants.AUTHORIZATIONREQUESTED))
+	assert.Equal(t, true, CanTransition(constants.AUTHORIZATIONREQUESTED, constants.AUTHORIZED))
+	assert.Equal(t, true, CanTransition(constants.AUTHORIZATIONREQUESTED, constants.DECLINED))
+	assert.Equal(t, true, CanTransition(constants.AUTHORIZATIONREQUESTED, constants.FAILED))
+	assert.Equal(t, false, CanTransition(constants.AUTHORIZATIONREQUESTED, constants.CAPTUREREQUESTED))
+}
@@ -9,7 +9,7 @@ import (
 	"github.com/go-redis/cache/v8"
 	"github.com/go-redis/redis/v8"
 	"github.com/jinzhu/gorm"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 	"log"
@@ -30,10 +30,68 @@ func ConnectDB() {
 	}
 	logger.Info("Connected Successfully to the Database")
 
+	backfillPaymentTimestamps := !DbConnection.Dialect().HasColumn("payments", "created_at")
 	DbConnection.AutoMigrate(&db.Payment{})
-	DbConnection.AutoMigrate(&db.Card{})
-	DbConnection.AutoMigrate(&db.Fraud{})
+	DbConnection.AutoMigrate(&db.Merchant{})
 	DbConnection.AutoMigrate(&db.Token{})
+	DbConnection.AutoMigrate(&db.APIKey{})
+	DbConnection.AutoMigrate(&db.VaultCard{})
+	DbConnection.AutoMigrate(&db.RetiredVaultKey{})
+	DbConnection.AutoMigrate(&db.BIN{})
+	DbConnection.AutoMigrate(&db.Export{})
+	DbConnection.AutoMigrate(&db.WebhookEndpoint{})
+	DbConnection.AutoMigrate(&db.WebhookEvent{})
+	DbConnection.AutoMigrate(&db.WebhookDelivery{})
+	DbConnection.AutoMigrate(&db.WebhookAttempt{})
+	DbConnection.AutoMigrate(&db.OutboxEvent{})
+	DbConnection.AutoMigrate(&db.OutboxReceipt{})
+	DbConnection.AutoMigrate(&db.PaymentStat{})
+	DbConnection.AutoMigrate(&db.LedgerAccount{})
+	DbConnection.AutoMigrate(&db.LedgerTransaction{})
+	DbConnection.AutoMigrate(&db.LedgerEntry{})
+	DbConnection.AutoMigrate(&db.PricingPlan{})
+	DbConnection.AutoMigrate(&db.PricingRule{})
+	DbConnection.AutoMigrate(&db.SettlementBatch{})
+	DbConnection.AutoMigrate(&db.SettlementLine{})
+	DbConnection.AutoMigrate(&db.Payout{})
+	DbConnection.AutoMigrate(&db.ReconciliationRun{})
+	DbConnection.AutoMigrate(&db.ReconciliationItem{})
+	DbConnection.AutoMigrate(&db.Refund{})
+	DbConnection.AutoMigrate(&db.PaymentEvent{})
+	DbConnection.AutoMigrate(&db.IdempotencyKey{})
+	seedDefaultPricingPlan()
+
+	// Vault cards are unique per merchant, mode and card since cards are stored separately in test mode
+	if DbConnection.Dialect().HasIndex("vault_cards", "idx_vault_cards_merchant_fingerprint") {
+		DbConnection.Model(&db.VaultCard{}).RemoveIndex("idx_vault_cards_merchant_fingerprint")
+	}
+
+	// CVVs must never be stored, databases from before kept them on every payment
+	if DbConnection.Dialect().HasColumn("payments", "cvv") {
+		DbConnection.Model(&db.Payment{}).DropColumn("cvv")
+	}
+
+	// Fixed fees are in minor units of a currency, rules matching any currency charged them in whatever the
+	// payment was made in
+	if err := DbConnection.Exec("UPDATE pricing_rules SET fixed_fee = 0 WHERE currency = '' AND fixed_fee > 0").Error; err != nil {
+		logger.Error("Failed to remove fixed fees of rules without a currency", zap.Error(err))
+	}
+
+	// Answers of webhook endpoints were kept before and could hold the content of internal services
+	if DbConnection.Dialect().HasColumn("webhook_attempts", "response_body") {
+		DbConnection.Model(&db.WebhookAttempt{}).DropColumn("response_body")
+	}
+
+	// Payments from before timestamps were kept were created with their first event and last
+	// updated with their latest one
+	if backfillPaymentTimestamps {
+		if err := DbConnection.Exec(`UPDATE payments SET
+			created_at = COALESCE((SELECT MIN(created_at) FROM payment_events WHERE payment_events.payment_id = payments.id), now()),
+			updated_at = COALESCE((SELECT MAX(created_at) FROM payment_events WHERE payment_events.payment_id = payments.id), now())
+			WHERE created_at IS NULL`).Error; err != nil {
+			logger.Error("Failed to backfill payment timestamps", zap.Error(err))
+		}
+	}
 }
 
 var redisDefaultClient *redis.Client
@@ -42,6 +100,12 @@ var redisDefaultOnce sync.Once
 var redisCache *cache.Cache
 var redisCacheOnce sync.Once
 
+// uniqueViolation reports whether the database refused a row for breaking a unique index
+func uniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
 func GetRedisDefaultClient() *redis.Client {
 	redisDefaultOnce.Do(func() {
 		redisDefaultClient = redis.NewClient(&redis.Options{
@@ -104,3 +168,11 @@ func GetPaymentDetailsFromCache(paymentID string) (*models.PaymentResponse, bool
 	}
 	return paymentDetails, true, nil
 }
+
+func DeletePaymentDetailsFromCache(paymentID string) {
+	if !Config.UseRedis {
+		return
+	}
+
+	_ = GetRedisCache().Delete(context.TODO(), getPaymentCacheKey(paymentID))
+}
@@ -10,13 +10,13 @@ import (
 	"time"
 )
 
-// CreateToken create a new token record
-func CreateToken(email string, tokenType string, expiresAt time.Time) (db.Token, error) {
+// CreateToken create a new token record for the merchant, bound to the API key it is issued with and its mode
+func CreateToken(merchant db.Merchant, apiKeyID int64, mode string, tokenType string, expiresAt time.Time) (db.Token, error) {
 	// Generate a random UUID
 	rand.Seed(time.Now().UnixNano())
 	ID := rand.Int63()
 	claims := &db.UserClaims{
-		Email: email,
+		Email: merchant.Email,
 		Type:  tokenType,
 		RegisteredClaims: jwt.RegisteredClaims{
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -34,13 +34,16 @@ func CreateToken(email string, tokenType string, expiresAt time.Time) (db.Token,
 	tokenModel := db.Token{
 		ID:          ID,
 		Token:       tokenString,
+		MerchantID:  merchant.ID,
+		APIKeyID:    apiKeyID,
+		Mode:        mode,
 		Type:        tokenType,
 		ExpiresAt:   expiresAt,
 		Blacklisted: false,
 	}
 
 	if err := DbConnection.Create(&tokenModel).Error; err != nil {
-		return db.Token{}, fmt.Errorf("Cannot save access token to db", err.Error())
+		return db.Token{}, fmt.Errorf("cannot save access token to db: %s", err.Error())
 	}
 
 	return tokenModel, nil
@@ -56,17 +59,33 @@ func DeleteTokenById(tokenId int64) error {
 	return DbConnection.Delete(token).Error
 }
 
-// GenerateAccessTokens generates "access" and "refresh" token for user
-func GenerateAccessTokens(email string) (db.Token, db.Token, error) {
+// GenerateAccessTokens generates "access" and "refresh" token for the merchant registered with the email.
+// The secret key must be an active key of that merchant, unknown emails and wrong keys fail alike.
+func GenerateAccessTokens(email string, secretKey string) (db.Token, db.Token, error) {
+	apiKey, err := VerifySecretKey(secretKey)
+	if err != nil {
+		return db.Token{}, db.Token{}, errors.New("invalid email or secret key")
+	}
+
+	merchant, err := GetMerchantByEmail(email)
+	if err != nil || merchant.ID != apiKey.MerchantID {
+		return db.Token{}, db.Token{}, errors.New("invalid email or secret key")
+	}
+
+	return GenerateMerchantTokens(merchant, apiKey.ID, apiKey.Mode)
+}
+
+// GenerateMerchantTokens generates "access" and "refresh" token bound to the merchant and its API key
+func GenerateMerchantTokens(merchant db.Merchant, apiKeyID int64, mode string) (db.Token, db.Token, error) {
 	accessExpiresAt := time.Now().Add(time.Duration(Config.JWTAccessExpirationMinutes) * time.Minute)
 	refreshExpiresAt := time.Now().Add(time.Duration(Config.JWTRefreshExpirationDays) * time.Hour * 24)
 
-	accessToken, err := CreateToken(email, db.TokenTypeAccess, accessExpiresAt)
+	accessToken, err := CreateToken(merchant, apiKeyID, mode, db.TokenTypeAccess, accessExpiresAt)
 	if err != nil {
 		return db.Token{}, db.Token{}, err
 	}
 
-	refreshToken, err := CreateToken(email, db.TokenTypeRefresh, refreshExpiresAt)
+	refreshToken, err := CreateToken(merchant, apiKeyID, mode, db.TokenTypeRefresh, refreshExpiresAt)
 	if err != nil {
 		return db.Token{}, db.Token{}, err
 	}
@@ -95,5 +114,15 @@ func VerifyToken(token string, tokenType string) (*db.Token, error) {
 	if err := DbConnection.Where("id = ? AND type >= ? AND blacklisted = ?", userId, tokenType, false).First(&tokenModel).Error; err != nil {
 		return &db.Token{}, errors.New("cannot find token")
 	}
+
+	if tokenModel.MerchantID == 0 {
+		return &db.Token{}, errors.New("token is not bound to a merchant")
+	}
+
+	// Tokens only work as long as the API key they were issued with
+	var apiKey db.APIKey
+	if err := DbConnection.Where("id = ? AND merchant_id = ?", tokenModel.APIKeyID, tokenModel.MerchantID).First(&apiKey).Error; err != nil || !apiKey.Active(time.Now()) {
+		return &db.Token{}, errors.New("token is not bound to an active api key")
+	}
 	return tokenModel, nil
 }
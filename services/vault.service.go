@@ -0,0 +1,100 @@
+package services
+
+import (
+	"checkout-task/logger"
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"checkout-task/utils"
+	"checkout-task/vault"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"strings"
+)
+
+// Vault encrypts the card numbers of vault_cards
+var Vault *vault.Vault
+
+var ErrCardTokenNotFound = errors.New("card token not found")
+
+func InitVault() {
+	v, err := vault.NewFromConfig(Config.VaultKeys, Config.VaultActiveKeyID, Config.VaultFingerprintKey)
+	if err != nil {
+		logger.Fatal("cannot initialise the card vault", zap.Error(err))
+	}
+	if err := v.DisableRetiredKeys(DbConnection); err != nil {
+		logger.Fatal("cannot initialise the card vault", zap.Error(err))
+	}
+	Vault = v
+}
+
+// TokenizeCard stores a card in the vault and returns its token. A merchant storing the same
+// card again in the same mode gets the same token back, with the expiry date updated.
+func TokenizeCard(merchantID int64, mode string, card models.CardRequest) (db.VaultCard, error) {
+	cardNumber := strings.ReplaceAll(card.CardNumber, " ", "")
+	fingerprint := Vault.Fingerprint(cardNumber)
+
+	var vaultCard db.VaultCard
+	err := DbConnection.Where("merchant_id = ? AND mode = ? AND fingerprint = ?", merchantID, mode, fingerprint).First(&vaultCard).Error
+	if err == nil {
+		if vaultCard.ExpMonth != card.ExpMonth || vaultCard.ExpYear != card.ExpYear {
+			if err := DbConnection.Model(&vaultCard).UpdateColumns(map[string]interface{}{
+				"exp_month": card.ExpMonth,
+				"exp_year":  card.ExpYear,
+			}).Error; err != nil {
+				logger.Error("failed to update vault card", zap.Error(err))
+				return db.VaultCard{}, fmt.Errorf("failed to store card")
+			}
+		}
+		return vaultCard, nil
+	}
+
+	encryptedCardNumber, err := Vault.Encrypt(cardNumber)
+	if err != nil {
+		logger.Error("failed to encrypt card number", zap.Error(err))
+		return db.VaultCard{}, fmt.Errorf("failed to store card")
+	}
+	token, err := vault.NewToken()
+	if err != nil {
+		logger.Error("failed to generate card token", zap.Error(err))
+		return db.VaultCard{}, fmt.Errorf("failed to store card")
+	}
+
+	vaultCard = db.VaultCard{
+		Token:               token,
+		MerchantID:          merchantID,
+		Mode:                mode,
+		Fingerprint:         fingerprint,
+		EncryptedCardNumber: encryptedCardNumber,
+		Last4:               cardNumber[len(cardNumber)-4:],
+		Brand:               utils.DetectBrand(cardNumber),
+		ExpMonth:            card.ExpMonth,
+		ExpYear:             card.ExpYear,
+	}
+	if err := DbConnection.Create(&vaultCard).Error; err != nil {
+		logger.Error("failed to store vault card", zap.Error(err))
+		return db.VaultCard{}, fmt.Errorf("failed to store card")
+	}
+
+	return vaultCard, nil
+}
+
+// DetokenizeCard returns the vault card of the merchant's token in the mode together with its card number
+func DetokenizeCard(merchantID int64, mode string, token string) (db.VaultCard, string, error) {
+	var vaultCard db.VaultCard
+	if err := DbConnection.Where("token = ? AND merchant_id = ? AND mode = ?", token, merchantID, mode).First(&vaultCard).Error; err != nil {
+		return db.VaultCard{}, "", ErrCardTokenNotFound
+	}
+
+	cardNumber, err := Vault.Decrypt(vaultCard.EncryptedCardNumber)
+	if err != nil {
+		logger.Error("failed to decrypt card number", zap.Error(err), zap.String("card_token", token))
+		return db.VaultCard{}, "", fmt.Errorf("failed to retrieve card")
+	}
+	// Cards stored before brands were detected
+	if vaultCard.Brand == "" {
+		vaultCard.Brand = utils.DetectBrand(cardNumber)
+	}
+
+	return vaultCard, cardNumber, nil
+}
@@ -0,0 +1,555 @@
+package services
+
+import (
+	"bytes"
+	"checkout-task/logger"
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// maxWebhookAttempts is how often a delivery is tried before it is dead-lettered
+	maxWebhookAttempts = 10
+	// webhookBaseBackoff is the wait after the first failed attempt, it doubles with every attempt
+	webhookBaseBackoff = time.Minute
+	webhookTimeout     = 10 * time.Second
+	webhookBatchSize   = 100
+	// maxWebhookResponseBody is how much of the endpoint's answer is read, and dropped, before the connection is reused
+	maxWebhookResponseBody = 1024
+)
+
+var (
+	ErrWebhookEndpointNotFound = errors.New("webhook endpoint not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrWebhookAddressBlocked   = errors.New("webhook url must not point to a private or local address")
+)
+
+// sharedAddressSpace is the carrier-grade NAT range of RFC 6598, which also holds cloud metadata services
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// nat64Prefix (RFC 6052) and sixToFourPrefix (RFC 3056) are IPv6 ranges that carry an IPv4 address, which
+// gateways translate back to IPv4
+var (
+	nat64Prefix     = &net.IPNet{IP: net.ParseIP("64:ff9b::"), Mask: net.CIDRMask(96, 128)}
+	sixToFourPrefix = &net.IPNet{IP: net.ParseIP("2002::"), Mask: net.CIDRMask(16, 128)}
+)
+
+// webhookClient only connects to public addresses, whatever the host of the endpoint resolves to when the
+// request is sent, so a host changing its DNS records after the endpoint was saved cannot reach internal
+// services. Proxies are not used and redirects are returned as the answer of the endpoint.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: webhookTimeout, Control: webhookDialControl}).DialContext,
+		TLSHandshakeTimeout: webhookTimeout,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// blockedWebhookIP reports whether webhooks must not be sent to the address: loopback, private, link-local
+// (169.254.169.254 is the metadata service of most clouds), shared, unspecified and multicast addresses. NAT64
+// and 6to4 addresses are checked by the IPv4 address they embed.
+func blockedWebhookIP(ip net.IP) bool {
+	if ip.To4() == nil {
+		switch {
+		case nat64Prefix.Contains(ip):
+			ip = ip[12:16]
+		case sixToFourPrefix.Contains(ip):
+			ip = ip[2:6]
+		}
+	}
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || sharedAddressSpace.Contains(ip)
+}
+
+// webhookDialControl runs on the resolved address of every connection of webhookClient and refuses blocked ones
+func webhookDialControl(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || blockedWebhookIP(ip) {
+		return ErrWebhookAddressBlocked
+	}
+	return nil
+}
+
+// validateWebhookURL checks the URL of an endpoint when it is saved. Release mode only accepts https, and
+// every address the host resolves to must be public.
+func validateWebhookURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.Hostname() == "" {
+		return fmt.Errorf("webhook url is not valid")
+	}
+	if Config.Mode == "release" && parsed.Scheme != "https" {
+		return fmt.Errorf("webhook url must be an https URL")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+	defer cancel()
+	addresses, err := net.DefaultResolver.LookupIPAddr(ctx, parsed.Hostname())
+	if err != nil || len(addresses) == 0 {
+		return fmt.Errorf("webhook url host cannot be resolved")
+	}
+	for _, address := range addresses {
+		if blockedWebhookIP(address.IP) {
+			return ErrWebhookAddressBlocked
+		}
+	}
+	return nil
+}
+
+// webhookPayload is the JSON body of every webhook request
+type webhookPayload struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// newWebhookSecret returns a random signing secret such as "whsec_<48 hex characters>"
+func newWebhookSecret() (string, error) {
+	random := make([]byte, 24)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(random), nil
+}
+
+// signWebhook signs the payload sent at timestamp as "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<payload>">"
+func signWebhook(secret string, payload []byte, timestamp time.Time) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp.Unix())
+	mac.Write(payload)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp.Unix(), hex.EncodeToString(mac.Sum(nil)))
+}
+
+// webhookBackoff is the wait before retrying a delivery that failed attempts times
+func webhookBackoff(attempts int) time.Duration {
+	return webhookBaseBackoff << (attempts - 1)
+}
+
+func endpointEventTypes(endpoint db.WebhookEndpoint) []string {
+	if endpoint.EventTypes == "" {
+		return []string{}
+	}
+	return strings.Split(endpoint.EventTypes, ",")
+}
+
+// subscribed reports whether the endpoint receives events of the type, endpoints without event types receive all
+func subscribed(endpoint db.WebhookEndpoint, eventType string) bool {
+	eventTypes := endpointEventTypes(endpoint)
+	for _, subscribedType := range eventTypes {
+		if subscribedType == eventType {
+			return true
+		}
+	}
+	return len(eventTypes) == 0
+}
+
+func webhookEndpointResponse(endpoint db.WebhookEndpoint) models.WebhookEndpointResponse {
+	return models.WebhookEndpointResponse{WebhookEndpoint: endpoint, Events: endpointEventTypes(endpoint)}
+}
+
+// CreateWebhookEndpoint adds an endpoint for the merchant in the mode, the signing secret is returned with it
+func CreateWebhookEndpoint(merchantID int64, mode string, request models.WebhookEndpointRequest) (models.WebhookEndpointResponse, string, error) {
+	if err := validateWebhookURL(request.URL); err != nil {
+		return models.WebhookEndpointResponse{}, "", err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		logger.Error("failed to generate webhook secret", zap.Error(err))
+		return models.WebhookEndpointResponse{}, "", fmt.Errorf("failed to create webhook endpoint")
+	}
+	encryptedSecret, err := Vault.Encrypt(secret)
+	if err != nil {
+		logger.Error("failed to encrypt webhook secret", zap.Error(err))
+		return models.WebhookEndpointResponse{}, "", fmt.Errorf("failed to create webhook endpoint")
+	}
+
+	endpoint := db.WebhookEndpoint{
+		EndpointID:  uuid.New().String(),
+		MerchantID:  merchantID,
+		Mode:        mode,
+		URL:         request.URL,
+		Description: request.Description,
+		EventTypes:  strings.Join(request.Events, ","),
+		Enabled:     true,
+		Secret:      encryptedSecret,
+	}
+	if err := DbConnection.Create(&endpoint).Error; err != nil {
+		logger.Error("failed to store webhook endpoint", zap.Error(err))
+		return models.WebhookEndpointResponse{}, "", fmt.Errorf("failed to create webhook endpoint")
+	}
+
+	return webhookEndpointResponse(endpoint), secret, nil
+}
+
+// ListWebhookEndpoints returns the endpoints of the merchant in the mode, oldest first
+func ListWebhookEndpoints(merchantID int64, mode string) ([]models.WebhookEndpointResponse, error) {
+	var endpoints []db.WebhookEndpoint
+	if err := DbConnection.Where("merchant_id = ? AND mode = ?", merchantID, mode).Order("id asc").Find(&endpoints).Error; err != nil {
+		logger.Error("failed to list webhook endpoints", zap.Error(err))
+		return nil, fmt.Errorf("failed to list webhook endpoints")
+	}
+
+	responses := make([]models.WebhookEndpointResponse, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		responses = append(responses, webhookEndpointResponse(endpoint))
+	}
+	return responses, nil
+}
+
+func findWebhookEndpoint(merchantID int64, mode string, endpointID string) (db.WebhookEndpoint, error) {
+	var endpoint db.WebhookEndpoint
+	if err := DbConnection.Where("endpoint_id = ? AND merchant_id = ? AND mode = ?", endpointID, merchantID, mode).First(&endpoint).Error; err != nil {
+		return db.WebhookEndpoint{}, ErrWebhookEndpointNotFound
+	}
+	return endpoint, nil
+}
+
+// GetWebhookEndpoint returns an endpoint of the merchant with its signing secret
+func GetWebhookEndpoint(merchantID int64, mode string, endpointID string) (models.WebhookEndpointResponse, string, error) {
+	endpoint, err := findWebhookEndpoint(merchantID, mode, endpointID)
+	if err != nil {
+		return models.WebhookEndpointResponse{}, "", err
+	}
+
+	secret, err := Vault.Decrypt(endpoint.Secret)
+	if err != nil {
+		logger.Error("failed to decrypt webhook secret", zap.Error(err), zap.String("endpoint_id", endpointID))
+		return models.WebhookEndpointResponse{}, "", fmt.Errorf("failed to retrieve webhook endpoint")
+	}
+	return webhookEndpointResponse(endpoint), secret, nil
+}
+
+// UpdateWebhookEndpoint changes the fields of the request that are set
+func UpdateWebhookEndpoint(merchantID int64, mode string, endpointID string, request models.WebhookEndpointUpdateRequest) (models.WebhookEndpointResponse, error) {
+	endpoint, err := findWebhookEndpoint(merchantID, mode, endpointID)
+	if err != nil {
+		return models.WebhookEndpointResponse{}, err
+	}
+
+	update := map[string]interface{}{"updated_at": time.Now()}
+	if request.URL != nil {
+		if err := validateWebhookURL(*request.URL); err != nil {
+			return models.WebhookEndpointResponse{}, err
+		}
+		update["url"] = *request.URL
+	}
+	if request.Description != nil {
+		update["description"] = *request.Description
+	}
+	if request.Events != nil {
+		update["event_types"] = strings.Join(*request.Events, ",")
+	}
+	if request.Enabled != nil {
+		update["enabled"] = *request.Enabled
+	}
+
+	if err := DbConnection.Model(&endpoint).UpdateColumns(update).Error; err != nil {
+		logger.Error("failed to update webhook endpoint", zap.Error(err), zap.String("endpoint_id", endpointID))
+		return models.WebhookEndpointResponse{}, fmt.Errorf("failed to update webhook endpoint")
+	}
+
+	endpoint, err = findWebhookEndpoint(merchantID, mode, endpointID)
+	if err != nil {
+		return models.WebhookEndpointResponse{}, err
+	}
+	return webhookEndpointResponse(endpoint), nil
+}
+
+// DeleteWebhookEndpoint removes an endpoint, its deliveries still waiting are dead-lettered
+func DeleteWebhookEndpoint(merchantID int64, mode string, endpointID string) error {
+	endpoint, err := findWebhookEndpoint(merchantID, mode, endpointID)
+	if err != nil {
+		return err
+	}
+
+	tx := DbConnection.Begin()
+	if err := tx.Model(&db.WebhookDelivery{}).
+		Where("endpoint_id = ? AND status = ?", endpoint.ID, db.DeliveryStatusPending).
+		UpdateColumns(map[string]interface{}{"status": db.DeliveryStatusDead, "last_error": "endpoint deleted"}).Error; err != nil {
+		tx.Rollback()
+		logger.Error("failed to dead-letter webhook deliveries", zap.Error(err), zap.String("endpoint_id", endpointID))
+		return fmt.Errorf("failed to delete webhook endpoint")
+	}
+	if err := tx.Delete(&endpoint).Error; err != nil {
+		tx.Rollback()
+		logger.Error("failed to delete webhook endpoint", zap.Error(err), zap.String("endpoint_id", endpointID))
+		return fmt.Errorf("failed to delete webhook endpoint")
+	}
+	return tx.Commit().Error
+}
+
+// publishWebhookEvent is the webhooks consumer of the outbox. It records the event for the merchant and a delivery
+// to each enabled endpoint of the payment's mode receiving its type, keeping the id of the outbox event so merchants
+// can drop duplicates.
+func publishWebhookEvent(tx *gorm.DB, outboxEvent db.OutboxEvent, data outboxData) error {
+	if !webhookEventType(outboxEvent.Type) {
+		return nil
+	}
+
+	var endpoints []db.WebhookEndpoint
+	if err := tx.Where("merchant_id = ? AND mode = ? AND enabled = ?", outboxEvent.MerchantID, data.mode(), true).Find(&endpoints).Error; err != nil {
+		return err
+	}
+	var receivers []db.WebhookEndpoint
+	for _, endpoint := range endpoints {
+		if subscribed(endpoint, outboxEvent.Type) {
+			receivers = append(receivers, endpoint)
+		}
+	}
+	if len(receivers) == 0 {
+		return nil
+	}
+
+	payload := webhookPayload{ID: outboxEvent.EventID, Type: outboxEvent.Type, CreatedAt: outboxEvent.CreatedAt, Data: data}
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	event := db.WebhookEvent{EventID: payload.ID, MerchantID: outboxEvent.MerchantID, Type: payload.Type, Payload: string(encoded)}
+	if err := tx.Create(&event).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, endpoint := range receivers {
+		if err := tx.Create(&db.WebhookDelivery{
+			DeliveryID:    uuid.New().String(),
+			MerchantID:    outboxEvent.MerchantID,
+			Mode:          endpoint.Mode,
+			EventID:       event.ID,
+			EndpointID:    endpoint.ID,
+			Status:        db.DeliveryStatusPending,
+			NextAttemptAt: now,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// webhookEventType reports whether merchants can receive events of the type
+func webhookEventType(eventType string) bool {
+	for _, webhookType := range db.WebhookEventTypes {
+		if webhookType == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// DeliverWebhooks sends the deliveries that are due every interval
+func DeliverWebhooks(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	for {
+		deliverDueWebhooks()
+		<-ticker.C
+	}
+}
+
+func deliverDueWebhooks() {
+	var deliveries []db.WebhookDelivery
+	if err := DbConnection.Where("status = ? AND next_attempt_at <= ?", db.DeliveryStatusPending, time.Now()).
+		Order("next_attempt_at asc").Limit(webhookBatchSize).Find(&deliveries).Error; err != nil {
+		logger.Error("failed to find due webhook deliveries", zap.Error(err))
+		return
+	}
+
+	for _, delivery := range deliveries {
+		// Push the next attempt past the request timeout, so no other worker sends the delivery meanwhile
+		result := DbConnection.Model(&db.WebhookDelivery{}).
+			Where("id = ? AND status = ? AND next_attempt_at = ?", delivery.ID, db.DeliveryStatusPending, delivery.NextAttemptAt).
+			UpdateColumn("next_attempt_at", time.Now().Add(2*webhookTimeout))
+		if result.Error != nil || result.RowsAffected == 0 {
+			continue
+		}
+		if err := attemptDelivery(delivery, false); err != nil {
+			logger.Error("failed to deliver webhook", zap.Error(err), zap.String("delivery_id", delivery.DeliveryID))
+		}
+	}
+}
+
+// attemptDelivery sends the delivery once and logs the attempt. Failed scheduled attempts are retried with
+// backoff until maxWebhookAttempts, then dead-lettered. Manual attempts only change the status when they succeed.
+func attemptDelivery(delivery db.WebhookDelivery, manual bool) error {
+	var event db.WebhookEvent
+	if err := DbConnection.Where("id = ?", delivery.EventID).First(&event).Error; err != nil {
+		return err
+	}
+
+	attempt := db.WebhookAttempt{DeliveryID: delivery.ID, Manual: manual}
+	var endpoint db.WebhookEndpoint
+	if err := DbConnection.Where("id = ?", delivery.EndpointID).First(&endpoint).Error; err != nil {
+		attempt.Error = "endpoint deleted"
+	} else if !endpoint.Enabled {
+		attempt.Error = "endpoint disabled"
+	} else if secret, err := Vault.Decrypt(endpoint.Secret); err != nil {
+		return err
+	} else {
+		started := time.Now()
+		attempt.ResponseStatus, err = sendWebhook(endpoint.URL, secret, event.EventID, []byte(event.Payload), started)
+		attempt.DurationMillis = time.Since(started).Milliseconds()
+		if err != nil {
+			attempt.Error = err.Error()
+		}
+	}
+
+	now := time.Now()
+	update := map[string]interface{}{"last_attempt_at": now, "last_error": attempt.Error}
+	switch {
+	case attempt.Error == "":
+		update["status"] = db.DeliveryStatusSucceeded
+	case manual:
+	case attempt.Error == "endpoint deleted" || attempt.Error == "endpoint disabled" || delivery.Attempts+1 >= maxWebhookAttempts:
+		update["status"] = db.DeliveryStatusDead
+	default:
+		update["next_attempt_at"] = now.Add(webhookBackoff(delivery.Attempts + 1))
+	}
+	if !manual {
+		update["attempts"] = delivery.Attempts + 1
+	}
+
+	tx := DbConnection.Begin()
+	if err := tx.Create(&attempt).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Model(&db.WebhookDelivery{}).Where("id = ?", delivery.ID).UpdateColumns(update).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	if update["status"] == db.DeliveryStatusDead {
+		logger.Info("Webhook delivery dead-lettered", zap.String("delivery_id", delivery.DeliveryID), zap.String("error", attempt.Error))
+	}
+	return nil
+}
+
+// sendWebhook posts the signed payload to the endpoint, answers other than 2xx are errors. The body of the
+// answer is not kept, so endpoints cannot use the gateway to read what another server returns.
+func sendWebhook(url string, secret string, eventID string, payload []byte, now time.Time) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Webhook-Id", eventID)
+	request.Header.Set("Webhook-Signature", signWebhook(secret, payload, now))
+
+	response, err := webhookClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, maxWebhookResponseBody))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("endpoint answered %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+func webhookDeliveryResponse(delivery db.WebhookDelivery) (models.WebhookDeliveryResponse, error) {
+	response := models.WebhookDeliveryResponse{WebhookDelivery: delivery}
+
+	var event db.WebhookEvent
+	if err := DbConnection.Where("id = ?", delivery.EventID).First(&event).Error; err != nil {
+		return response, err
+	}
+	response.EventID, response.EventType = event.EventID, event.Type
+
+	var endpoint db.WebhookEndpoint
+	if err := DbConnection.Where("id = ?", delivery.EndpointID).First(&endpoint).Error; err == nil {
+		response.EndpointID = endpoint.EndpointID
+	}
+	return response, nil
+}
+
+// ListWebhookDeliveries returns the latest deliveries to an endpoint of the merchant, optionally only those in a status
+func ListWebhookDeliveries(merchantID int64, mode string, endpointID string, status string) ([]models.WebhookDeliveryResponse, error) {
+	endpoint, err := findWebhookEndpoint(merchantID, mode, endpointID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := DbConnection.Where("endpoint_id = ?", endpoint.ID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var deliveries []db.WebhookDelivery
+	if err := query.Order("id desc").Limit(webhookBatchSize).Find(&deliveries).Error; err != nil {
+		logger.Error("failed to list webhook deliveries", zap.Error(err))
+		return nil, fmt.Errorf("failed to list webhook deliveries")
+	}
+
+	responses := make([]models.WebhookDeliveryResponse, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		response, err := webhookDeliveryResponse(delivery)
+		if err != nil {
+			logger.Error("failed to list webhook deliveries", zap.Error(err))
+			return nil, fmt.Errorf("failed to list webhook deliveries")
+		}
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// GetWebhookDelivery returns a delivery of the merchant in the mode with the log of its attempts
+func GetWebhookDelivery(merchantID int64, mode string, deliveryID string) (models.WebhookDeliveryResponse, error) {
+	var delivery db.WebhookDelivery
+	if err := DbConnection.Where("delivery_id = ? AND merchant_id = ? AND mode = ?", deliveryID, merchantID, mode).First(&delivery).Error; err != nil {
+		return models.WebhookDeliveryResponse{}, ErrWebhookDeliveryNotFound
+	}
+
+	response, err := webhookDeliveryResponse(delivery)
+	if err != nil {
+		logger.Error("failed to retrieve webhook delivery", zap.Error(err), zap.String("delivery_id", deliveryID))
+		return models.WebhookDeliveryResponse{}, fmt.Errorf("failed to retrieve webhook delivery")
+	}
+	if err := DbConnection.Where("delivery_id = ?", delivery.ID).Order("id asc").Find(&response.AttemptLog).Error; err != nil {
+		logger.Error("failed to retrieve webhook attempts", zap.Error(err), zap.String("delivery_id", deliveryID))
+		return models.WebhookDeliveryResponse{}, fmt.Errorf("failed to retrieve webhook delivery")
+	}
+	return response, nil
+}
+
+// RedeliverWebhook sends a delivery of the merchant in the mode again straight away, dead-lettered ones included
+func RedeliverWebhook(merchantID int64, mode string, deliveryID string) (models.WebhookDeliveryResponse, error) {
+	var delivery db.WebhookDelivery
+	if err := DbConnection.Where("delivery_id = ? AND merchant_id = ? AND mode = ?", deliveryID, merchantID, mode).First(&delivery).Error; err != nil {
+		return models.WebhookDeliveryResponse{}, ErrWebhookDeliveryNotFound
+	}
+
+	if err := attemptDelivery(delivery, true); err != nil {
+		logger.Error("failed to redeliver webhook", zap.Error(err), zap.String("delivery_id", deliveryID))
+		return models.WebhookDeliveryResponse{}, fmt.Errorf("failed to redeliver webhook")
+	}
+	return GetWebhookDelivery(merchantID, mode, deliveryID)
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignWebhook(t *testing.T) {
+	timestamp := time.Unix(1792314000, 0)
+	payload := []byte(`{"id":"evt","type":"payment.captured"}`)
+
+	// Test case 1: the signature is an HMAC of the timestamp and the payload
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1792314000." + string(payload)))
+	assert.Equal(t, "t=1792314000,v1="+hex.EncodeToString(mac.Sum(nil)), signWebhook("whsec_test", payload, timestamp))
+
+	// Test case 2: other secrets, payloads and times give other signatures
+	assert.NotEqual(t, signWebhook("whsec_test", payload, timestamp), signWebhook("whsec_other", payload, timestamp))
+	assert.NotEqual(t, signWebhook("whsec_test", payload, timestamp), signWebhook("whsec_test", []byte("{}"), timestamp))
+	assert.NotEqual(t, signWebhook("whsec_test", payload, timestamp), signWebhook("whsec_test", payload, timestamp.Add(time.Second)))
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	assert.Equal(t, time.Minute, webhookBackoff(1))
+	assert.Equal(t, 2*time.Minute, webhookBackoff(2))
+	assert.Equal(t, 256*time.Minute, webhookBackoff(maxWebhookAttempts-1))
+}
+
+func TestSubscribed(t *testing.T) {
+	// Test case 1: endpoints without event types receive every event
+	assert.True(t, subscribed(db.WebhookEndpoint{}, db.EventRefundSucceeded))
+
+	// Test case 2: otherwise only the listed ones
+	endpoint := db.WebhookEndpoint{EventTypes: db.EventPaymentCaptured + "," + db.EventPaymentDeclined}
+	assert.True(t, subscribed(endpoint, db.EventPaymentDeclined))
+	assert.False(t, subscribed(endpoint, db.EventRefundSucceeded))
+}
+
+func TestSendWebhook(t *testing.T) {
+	var signature, eventID, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature, eventID = r.Header.Get("Webhook-Signature"), r.Header.Get("Webhook-Id")
+		received, _ := io.ReadAll(r.Body)
+		body = string(received)
+		if strings.Contains(body, "fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	now := time.Now()
+
+	// Test case 1: the test server listens on a loopback address, which is refused when connecting
+	_, err := sendWebhook(server.URL, "whsec_test", "evt_0", []byte(`{"type":"payment.captured"}`), now)
+	assert.ErrorIs(t, err, ErrWebhookAddressBlocked)
+
+	defer func(client *http.Client) { webhookClient = client }(webhookClient)
+	webhookClient = &http.Client{Timeout: webhookTimeout}
+
+	// Test case 2: the payload arrives signed
+	status, err := sendWebhook(server.URL, "whsec_test", "evt_1", []byte(`{"type":"payment.captured"}`), now)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "evt_1", eventID)
+	assert.Equal(t, `{"type":"payment.captured"}`, body)
+	assert.Equal(t, signWebhook("whsec_test", []byte(body), now), signature)
+
+	// Test case 3: answers other than 2xx fail the attempt
+	status, err = sendWebhook(server.URL, "whsec_test", "evt_2", []byte(`{"type":"fail"}`), now)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusInternalServerError, status)
+}
+
+func TestBlockedWebhookIP(t *testing.T) {
+	// Test case 1: internal addresses are blocked
+	for _, address := range []string{"127.0.0.1", "::1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.100.100.200", "fd00:ec2::254", "0.0.0.0", "::ffff:127.0.0.1"} {
+		assert.True(t, blockedWebhookIP(net.ParseIP(address)), address)
+	}
+
+	// Test case 2: internal IPv4 addresses embedded in NAT64 and 6to4 addresses are blocked
+	for _, address := range []string{"64:ff9b::7f00:1", "64:ff9b::a9fe:a9fe", "2002:a00:1::", "2002:7f00:1::1"} {
+		assert.True(t, blockedWebhookIP(net.ParseIP(address)), address)
+	}
+
+	// Test case 3: public addresses are not
+	for _, address := range []string{"93.184.216.34", "2606:2800:220:1:248:1893:25c8:1946", "64:ff9b::5db8:d822", "2002:5db8:d822::1"} {
+		assert.False(t, blockedWebhookIP(net.ParseIP(address)), address)
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	defer func(config *models.EnvConfig) { Config = config }(Config)
+	Config = &models.EnvConfig{Mode: "release"}
+
+	// Test case 1: public https endpoints are accepted
+	assert.NoError(t, validateWebhookURL("https://93.184.216.34/webhooks"))
+
+	// Test case 2: release mode only accepts https
+	assert.Error(t, validateWebhookURL("http://93.184.216.34/webhooks"))
+
+	// Test case 3: hosts resolving to internal addresses are refused
+	assert.Equal(t, ErrWebhookAddressBlocked, validateWebhookURL("https://169.254.169.254/latest/meta-data"))
+	assert.Equal(t, ErrWebhookAddressBlocked, validateWebhookURL("https://localhost:8080/webhooks"))
+
+	// Test case 4: debug mode accepts http
+	Config.Mode = "debug"
+	assert.NoError(t, validateWebhookURL("http://93.184.216.34/webhooks"))
+}
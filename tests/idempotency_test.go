@@ -0,0 +1,130 @@
+package main
+
+import (
+	"checkout-task/middlewares"
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"checkout-task/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// idempotentRouter serves POST /charge behind the idempotency middleware for the legacy merchant in test
+// mode. The handler fails as the outcome query parameter asks and counts how often it ran.
+func idempotentRouter(t *testing.T, calls *int) *gin.Engine {
+	services.LoadConfig()
+	services.ConnectDB()
+	merchant := testMerchant(t)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(gin.CustomRecovery(middlewares.AppRecovery()))
+	router.POST("/charge", func(c *gin.Context) {
+		c.Set("merchantId", merchant.ID)
+		c.Set("apiKeyMode", db.APIKeyModeTest)
+	}, middlewares.IdempotencyMiddleware(), func(c *gin.Context) {
+		*calls++
+		switch c.Query("outcome") {
+		case "panic":
+			panic("handler panicked")
+		case "error":
+			models.SendErrorResponse(c, http.StatusInternalServerError, "cannot charge")
+		default:
+			models.SendResponseData(c, gin.H{"Call": *calls})
+		}
+	})
+	return router
+}
+
+func sendIdempotent(router *gin.Engine, key string, outcome string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodPost, "/charge?outcome="+outcome, strings.NewReader(body))
+	request.Header.Set(middlewares.IdempotencyKeyHeader, key)
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	calls := 0
+	router := idempotentRouter(t, &calls)
+	key := uuid.New().String()
+
+	first := sendIdempotent(router, key, "", `{"amount":1000}`)
+	assert.Equal(t, http.StatusOK, first.Code)
+
+	// The same key and body replay the stored response without running the handler again
+	replay := sendIdempotent(router, key, "", `{"amount":1000}`)
+	assert.Equal(t, http.StatusOK, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), replay.Body.String())
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotencyRejectsDifferentBody(t *testing.T) {
+	calls := 0
+	router := idempotentRouter(t, &calls)
+	key := uuid.New().String()
+
+	assert.Equal(t, http.StatusOK, sendIdempotent(router, key, "", `{"amount":1000}`).Code)
+
+	// The same key with another body is a conflict
+	conflict := sendIdempotent(router, key, "", `{"amount":2000}`)
+	assert.Equal(t, http.StatusConflict, conflict.Code)
+	assert.Contains(t, conflict.Body.String(), services.ErrIdempotencyKeyReused.Error())
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotencyReleasesKeyOnServerError(t *testing.T) {
+	calls := 0
+	router := idempotentRouter(t, &calls)
+	key := uuid.New().String()
+
+	assert.Equal(t, http.StatusInternalServerError, sendIdempotent(router, key, "error", `{"amount":1000}`).Code)
+
+	// Server errors are not stored, the retry runs the handler again
+	retry := sendIdempotent(router, key, "", `{"amount":1000}`)
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Empty(t, retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyReleasesKeyOnPanic(t *testing.T) {
+	calls := 0
+	router := idempotentRouter(t, &calls)
+	key := uuid.New().String()
+
+	assert.Equal(t, http.StatusInternalServerError, sendIdempotent(router, key, "panic", `{"amount":1000}`).Code)
+
+	// The panic released the key instead of leaving it in progress
+	retry := sendIdempotent(router, key, "", `{"amount":1000}`)
+	assert.Equal(t, http.StatusOK, retry.Code)
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyKeyInProgress(t *testing.T) {
+	services.LoadConfig()
+	services.ConnectDB()
+	key := uuid.New().String()
+
+	// Test case 1: the first request claims the key
+	record, err := services.BeginIdempotentRequest("in-progress", key, "hash")
+	assert.NoError(t, err)
+
+	// Test case 2: a retry while the first request runs is rejected
+	_, err = services.BeginIdempotentRequest("in-progress", key, "hash")
+	assert.Equal(t, services.ErrIdempotencyKeyInProgress, err)
+
+	// Test case 3: the same key in another scope is independent
+	_, err = services.BeginIdempotentRequest("other-scope", key, "hash")
+	assert.NoError(t, err)
+
+	services.CompleteIdempotentRequest(record, http.StatusCreated, []byte(`{}`))
+	replay, err := services.BeginIdempotentRequest("in-progress", key, "hash")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, replay.ResponseCode)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"checkout-task/acquirer"
+	"checkout-task/constants"
+	"checkout-task/models"
+	db "checkout-task/models/db"
+	"checkout-task/services"
+	"context"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// scriptedBank stands in for the bank simulator, it approves every operation unless a decline is set for it
+type scriptedBank struct {
+	declines map[string]string
+}
+
+func (b scriptedBank) answer(operation string) (acquirer.Response, error) {
+	if code, ok := b.declines[operation]; ok {
+		return acquirer.Response{Approved: false, Reference: uuid.New().String(), DeclineCode: code, DeclineReason: code}, nil
+	}
+	return acquirer.Response{Approved: true, Reference: uuid.New().String()}, nil
+}
+
+func (b scriptedBank) Authorize(ctx context.Context, request acquirer.AuthorizationRequest) (acquirer.Response, error) {
+	return b.answer("authorization")
+}
+
+func (b scriptedBank) Capture(ctx context.Context, authorizationReference string, request acquirer.AmountRequest) (acquirer.Response, error) {
+	return b.answer("capture")
+}
+
+func (b scriptedBank) Void(ctx context.Context, authorizationReference string, request acquirer.VoidRequest) (acquirer.Response, error) {
+	return b.answer("void")
+}
+
+func (b scriptedBank) Refund(ctx context.Context, authorizationReference string, request acquirer.AmountRequest) (acquirer.Response, error) {
+	return b.answer("refund")
+}
+
+func (b scriptedBank) Transfer(ctx context.Context, request acquirer.TransferRequest) (acquirer.Response, error) {
+	return b.answer("transfer")
+}
+
+func (b scriptedBank) Lookup(ctx context.Context, operationReference string) (acquirer.Response, error) {
+	return acquirer.Response{}, acquirer.ErrOperationNotFound
+}
+
+func (b scriptedBank) LookupAuthorization(ctx context.Context, merchantReference string) (acquirer.Response, error) {
+	return acquirer.Response{}, acquirer.ErrOperationNotFound
+}
+
+// useScriptedBank sends test payments to a scripted bank until the returned function restores the simulator
+func useScriptedBank(t *testing.T, declines map[string]string) func() {
+	services.LoadConfig()
+	services.ConnectDB()
+	services.InitVault()
+	previous := services.TestBank
+	services.TestBank = scriptedBank{declines: declines}
+	return func() { services.TestBank = previous }
+}
+
+// authorizeTestPayment authorizes a payment of the legacy merchant with the scripted bank
+func authorizeTestPayment(t *testing.T, amount int64) models.ProcessPaymentResponse {
+	expMonth, expYear := testExpiry()
+	response, err := services.AuthorizePayment(testMerchant(t).ID, db.APIKeyModeTest, models.PaymentRequest{
+		CardNumber: testCardNumber,
+		ExpMonth:   expMonth,
+		ExpYear:    expYear,
+		CVV:        123,
+		Amount:     amount,
+		Currency:   "USD",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, constants.AUTHORIZED, response.Status)
+	return response
+}
+
+func paymentStatus(t *testing.T, paymentID string) string {
+	var payment db.Payment
+	assert.NoError(t, services.DbConnection.Where("payment_id = ?", paymentID).First(&payment).Error)
+	return payment.Status
+}
+
+func TestCapturePayment(t *testing.T) {
+	defer useScriptedBank(t, nil)()
+	merchant := testMerchant(t)
+	authorization := authorizeTestPayment(t, 1200)
+
+	// Test case 1: an authorized payment is captured
+	response, err := services.CapturePayment(merchant.ID, db.APIKeyModeTest, authorization.PaymentIdentifier)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.CAPTURED, response.Status)
+	assert.Equal(t, constants.CAPTURED, paymentStatus(t, authorization.PaymentIdentifier))
+
+	// Test case 2: a captured payment cannot be captured again
+	_, err = services.CapturePayment(merchant.ID, db.APIKeyModeTest, authorization.PaymentIdentifier)
+	assert.Error(t, err)
+
+	// Test case 3: a captured payment cannot be voided
+	_, err = services.VoidPayment(merchant.ID, db.APIKeyModeTest, authorization.PaymentIdentifier)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "refund it instead")
+}
+
+func TestCapturePaymentDeclined(t *testing.T) {
+	defer useScriptedBank(t, map[string]string{"capture": "insufficient_funds"})()
+	merchant := testMerchant(t)
+	authorization := authorizeTestPayment(t, 1200)
+
+	// A declined capture returns the payment to Authorized with the decline code
+	response, err := services.CapturePayment(merchant.ID, db.APIKeyModeTest, authorization.PaymentIdentifier)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.AUTHORIZED, response.Status)
+	assert.Equal(t, "insufficient_funds", response.DeclineCode)
+	assert.Equal(t, constants.AUTHORIZED, paymentStatus(t, authorization.PaymentIdentifier))
+}
+
+func TestVoidPayment(t *testing.T) {
+	defer useScriptedBank(t, nil)()
+	merchant := testMerchant(t)
+	authorization := authorizeTestPayment(t, 1300)
+
+	// Test case 1: an authorized payment is voided
+	response, err := services.VoidPayment(merchant.ID, db.APIKeyModeTest, authorization.PaymentIdentifier)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.VOIDED, response.Status)
+	assert.Equal(t, constants.VOIDED, paymentStatus(t, authorization.PaymentIdentifier))
+
+	// Test case 2: a voided payment can no longer be captured
+	_, err = services.CapturePayment(merchant.ID, db.APIKeyModeTest, authorization.PaymentIdentifier)
+	assert.Error(t, err)
+
+	// Test case 3: a payment of another merchant cannot be voided
+	other := authorizeTestPayment(t, 1300)
+	_, err = services.VoidPayment(merchant.ID+1000000, db.APIKeyModeTest, other.PaymentIdentifier)
+	assert.Error(t, err)
+	assert.Equal(t, constants.AUTHORIZED, paymentStatus(t, other.PaymentIdentifier))
+}
+
+func TestRefundPayment(t *testing.T) {
+	defer useScriptedBank(t, nil)()
+	merchant := testMerchant(t)
+	authorization := authorizeTestPayment(t, 1400)
+
+	// Test case 1: an authorized payment that was not captured cannot be refunded
+	_, err := services.RefundPayment(merchant.ID, db.APIKeyModeTest, authorization.PaymentIdentifier, 400)
+	assert.Error(t, err)
+
+	_, err = services.CapturePayment(merchant.ID, db.APIKeyModeTest, authorization.PaymentIdentifier)
+	assert.NoError(t, err)
+
+	// Test case 2: a partial refund
+	refund, err := services.RefundPayment(merchant.ID, db.APIKeyModeTest, authorization.PaymentIdentifier, 400)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.SUCCESS, refund.Status)
+	assert.Equal(t, int64(400), refund.Amount)
+	assert.Equal(t, constants.PARTIALLYREFUNDED, paymentStatus(t, authorization.PaymentIdentifier))
+
+	// Test case 3: refunding more than is left
+	_, err = services.RefundPayment(merchant.ID, db.APIKeyModeTest, authorization.PaymentIdentifier, 1001)
+	assert.Error(t, err)
+
+	// Test case 4: a zero amount refunds the rest
+	refund, err = services.RefundPayment(merchant.ID, db.APIKeyModeTest, authorization.PaymentIdentifier, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1000), refund.Amount)
+	assert.Equal(t, constants.REFUNDED, paymentStatus(t, authorization.PaymentIdentifier))
+}
+
+func TestRefundPaymentDeclined(t *testing.T) {
+	defer useScriptedBank(t, map[string]string{"refund": "issuer_unavailable"})()
+	merchant := testMerchant(t)
+	authorization := authorizeTestPayment(t, 1500)
+	_, err := services.CapturePayment(merchant.ID, db.APIKeyModeTest, authorization.PaymentIdentifier)
+	assert.NoError(t, err)
+
+	// A declined refund leaves the payment captured
+	refund, err := services.RefundPayment(merchant.ID, db.APIKeyModeTest, authorization.PaymentIdentifier, 500)
+	assert.NoError(t, err)
+	assert.Equal(t, constants.FAILURE, refund.Status)
+	assert.Equal(t, constants.CAPTURED, paymentStatus(t, authorization.PaymentIdentifier))
+}
+
+func TestProcessPaymentVoidsDeclinedCapture(t *testing.T) {
+	defer useScriptedBank(t, map[string]string{"capture": "do_not_honour"})()
+	expMonth, expYear := testExpiry()
+
+	// The capture is declined after the authorization, the hold is released
+	response, err := services.ProcessPayment(testMerchant(t).ID, db.APIKeyModeTest, models.PaymentRequest{
+		CardNumber: testCardNumber,
+		ExpMonth:   expMonth,
+		ExpYear:    expYear,
+		CVV:        123,
+		Amount:     1600,
+		Currency:   "USD",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, constants.VOIDED, response.Status)
+	assert.Equal(t, "do_not_honour", response.DeclineCode)
+	assert.NotEmpty(t, response.CardToken)
+	assert.Equal(t, constants.VOIDED, paymentStatus(t, response.PaymentIdentifier))
+}
+
+func TestProcessPaymentReportsAuthorizedWhenVoidDeclined(t *testing.T) {
+	defer useScriptedBank(t, map[string]string{"capture": "do_not_honour", "void": "issuer_unavailable"})()
+	expMonth, expYear := testExpiry()
+
+	// Neither the capture nor the void went through, the payment is reported as still authorized
+	response, err := services.ProcessPayment(testMerchant(t).ID, db.APIKeyModeTest, models.PaymentRequest{
+		CardNumber: testCardNumber,
+		ExpMonth:   expMonth,
+		ExpYear:    expYear,
+		CVV:        123,
+		Amount:     1700,
+		Currency:   "USD",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, constants.AUTHORIZED, response.Status)
+	assert.Equal(t, constants.AUTHORIZED, paymentStatus(t, response.PaymentIdentifier))
+}
@@ -3,57 +3,84 @@ package main
 import (
 	db "checkout-task/models/db"
 	"checkout-task/services"
+	"github.com/google/uuid"
 
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 
 	"checkout-task/models"
 )
 
+// legacyMerchant owns the payments created before merchant accounts, see migrations/merchants.sql
+const legacyMerchantEmail = "legacy@payment-gateway.local"
+
+func testMerchant(t *testing.T) db.Merchant {
+	merchant, err := services.GetMerchantByEmail(legacyMerchantEmail)
+	assert.NoError(t, err)
+	return merchant
+}
+
+// testCardNumber is a valid Visa number the bank simulator holds no funds for
+const testCardNumber = "4242424242424242"
+
+// testExpiry returns an expiry month and year a year from now, so the test card never expires
+func testExpiry() (int, int) {
+	expiry := time.Now().AddDate(1, 0, 0)
+	return int(expiry.Month()), expiry.Year()
+}
+
 func TestProcessPayment_Success(t *testing.T) {
 	services.LoadConfig()
 	services.ConnectDB()
+	services.InitAcquirer()
+	services.InitVault()
+	merchant := testMerchant(t)
+	expMonth, expYear := testExpiry()
 
 	payment := models.PaymentRequest{
-		CardNumber: "1234123412341234",
-		ExpMonth:   12,
-		ExpYear:    2024,
+		CardNumber: testCardNumber,
+		ExpMonth:   expMonth,
+		ExpYear:    expYear,
 		CVV:        123,
-		Amount:     10.0,
+		Amount:     1000,
 		Currency:   "USD",
 	}
 
-	response, err := services.ProcessPayment(payment)
+	response, err := services.ProcessPayment(merchant.ID, db.APIKeyModeTest, payment)
 
 	// Assert
 	assert.NoError(t, err)
-	assert.Equal(t, "failure", response.Status)
+	assert.Equal(t, "Declined", response.Status)
 
 	payment = models.PaymentRequest{
-		CardNumber: "1234123413341234",
-		ExpMonth:   12,
-		ExpYear:    2024,
+		CardNumber: testCardNumber,
+		ExpMonth:   expMonth,
+		ExpYear:    expYear,
 		CVV:        123,
-		Amount:     10.0,
+		Amount:     1000,
 		Currency:   "USD",
 	}
 
-	response, err = services.ProcessPayment(payment)
+	response, err = services.ProcessPayment(merchant.ID, db.APIKeyModeTest, payment)
 
 	assert.NoError(t, err)
-	assert.Equal(t, "failure", response.Status)
+	assert.Equal(t, "Declined", response.Status)
 
 	// Check if payment details are stored in the database correctly
 	var paymentInfo db.Payment
-	err = services.DbConnection.Find(&paymentInfo).Where("card_number = ?", "1234123412341234").Error
+	err = services.DbConnection.Where("payment_id = ?", response.PaymentIdentifier).First(&paymentInfo).Error
 	assert.NoError(t, err)
-	assert.Equal(t, payment.CardNumber, paymentInfo.CardNumber)
+	assert.Empty(t, paymentInfo.CardNumber)
+	assert.Equal(t, response.CardToken, paymentInfo.CardToken)
+	assert.Equal(t, "4242", paymentInfo.CardLast4)
 	assert.Equal(t, payment.ExpMonth, paymentInfo.ExpMonth)
 	assert.Equal(t, payment.ExpYear, paymentInfo.ExpYear)
-	assert.Equal(t, payment.CVV, paymentInfo.CVV)
+	assert.False(t, services.DbConnection.Dialect().HasColumn("payments", "cvv"))
 	assert.Equal(t, payment.Amount, paymentInfo.Amount)
 	assert.Equal(t, payment.Currency, paymentInfo.Currency)
-	assert.Equal(t, "In-Progress", paymentInfo.Status)
+	assert.Equal(t, "Declined", paymentInfo.Status)
+	assert.Equal(t, merchant.ID, paymentInfo.MerchantID)
 }
 
 func TestRetrievePayment(t *testing.T) {
@@ -61,13 +88,13 @@ func TestRetrievePayment(t *testing.T) {
 	services.ConnectDB()
 
 	paymentID := "9bbaf0c4-8d6f-4ae1-920a-b91299b68b0a"
-	paymentInfo, err := services.RetrievePayment(paymentID)
+	paymentInfo, err := services.RetrievePayment(testMerchant(t).ID, db.APIKeyModeTest, paymentID)
 	assert.NoError(t, err)
 	assert.Equal(t, paymentID, paymentInfo.PaymentID)
 	assert.Equal(t, "**** **** **** 3457", paymentInfo.MaskedCardNumber)
-	assert.Equal(t, 34.00, paymentInfo.Amount)
+	assert.Equal(t, int64(3400), paymentInfo.Amount)
 	assert.Equal(t, "EUR", paymentInfo.Currency)
-	assert.Equal(t, "Success", paymentInfo.Status)
+	assert.Equal(t, "Captured", paymentInfo.Status)
 }
 
 func TestRetrievePaymentWithInvalidID(t *testing.T) {
@@ -75,7 +102,114 @@ func TestRetrievePaymentWithInvalidID(t *testing.T) {
 	services.ConnectDB()
 
 	paymentID := "-1"
-	_, err := services.RetrievePayment(paymentID)
+	_, err := services.RetrievePayment(testMerchant(t).ID, db.APIKeyModeTest, paymentID)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "failed to retrieve payment details")
 }
+
+func TestRetrievePaymentOfAnotherMerchant(t *testing.T) {
+	services.LoadConfig()
+	services.ConnectDB()
+
+	paymentID := "9bbaf0c4-8d6f-4ae1-920a-b91299b68b0a"
+	_, err := services.RetrievePayment(testMerchant(t).ID+1, db.APIKeyModeTest, paymentID)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to retrieve payment details")
+}
+
+func TestProcessPaymentWithCardToken(t *testing.T) {
+	services.LoadConfig()
+	services.ConnectDB()
+	services.InitAcquirer()
+	services.InitVault()
+	merchant := testMerchant(t)
+	expMonth, expYear := testExpiry()
+
+	card, err := services.TokenizeCard(merchant.ID, db.APIKeyModeTest, models.CardRequest{
+		CardNumber: testCardNumber,
+		ExpMonth:   expMonth,
+		ExpYear:    expYear,
+	})
+	assert.NoError(t, err)
+	assert.Contains(t, card.Token, "tok_")
+	assert.NotContains(t, card.EncryptedCardNumber, testCardNumber)
+
+	// Test case 1: storing the same card again returns the same token
+	again, err := services.TokenizeCard(merchant.ID, db.APIKeyModeTest, models.CardRequest{
+		CardNumber: "4242 4242 4242 4242",
+		ExpMonth:   expMonth,
+		ExpYear:    expYear,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, card.Token, again.Token)
+
+	// Test case 2: the token pays without the card number or CVV
+	response, err := services.ProcessPayment(merchant.ID, db.APIKeyModeTest, models.PaymentRequest{
+		CardToken: card.Token,
+		Amount:    1000,
+		Currency:  "USD",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, card.Token, response.CardToken)
+
+	// Test case 3: tokens of other merchants are unknown
+	_, err = services.ProcessPayment(merchant.ID+1, db.APIKeyModeTest, models.PaymentRequest{
+		CardToken: card.Token,
+		Amount:    1000,
+		Currency:  "USD",
+	})
+	assert.Equal(t, services.ErrCardTokenNotFound, err)
+}
+
+func TestListPayments(t *testing.T) {
+	services.LoadConfig()
+	services.ConnectDB()
+	services.InitAcquirer()
+	services.InitVault()
+	merchant := testMerchant(t)
+	expMonth, expYear := testExpiry()
+
+	reference := "order-" + uuid.New().String()
+	for i := 0; i < 3; i++ {
+		_, err := services.ProcessPayment(merchant.ID, db.APIKeyModeTest, models.PaymentRequest{
+			Reference:   reference,
+			Description: "2 concert tickets",
+			Metadata:    map[string]string{"customer_id": "cus_8812"},
+			CardNumber:  testCardNumber,
+			ExpMonth:    expMonth,
+			ExpYear:     expYear,
+			CVV:         123,
+			Amount:      1000,
+			Currency:    "USD",
+		})
+		assert.NoError(t, err)
+	}
+
+	// Test case 1: pages follow each other newest first without repeating a payment
+	first, err := services.ListPayments(merchant.ID, db.APIKeyModeTest, models.PaymentListRequest{Reference: reference, Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, first.Payments, 2)
+	assert.True(t, first.HasMore)
+	assert.False(t, first.Payments[0].CreatedAt.Before(first.Payments[1].CreatedAt))
+
+	second, err := services.ListPayments(merchant.ID, db.APIKeyModeTest, models.PaymentListRequest{Reference: reference, Limit: 2, Cursor: first.NextCursor})
+	assert.NoError(t, err)
+	assert.Len(t, second.Payments, 1)
+	assert.False(t, second.HasMore)
+	assert.NotContains(t, []string{first.Payments[0].PaymentID, first.Payments[1].PaymentID}, second.Payments[0].PaymentID)
+
+	// Test case 2: the merchant's details are kept with the payment
+	assert.Equal(t, reference, second.Payments[0].Reference)
+	assert.Equal(t, "2 concert tickets", second.Payments[0].Description)
+	assert.Equal(t, map[string]string{"customer_id": "cus_8812"}, second.Payments[0].Metadata)
+
+	// Test case 3: filters narrow the list
+	filtered, err := services.ListPayments(merchant.ID, db.APIKeyModeTest, models.PaymentListRequest{Reference: reference, Currency: "EUR"})
+	assert.NoError(t, err)
+	assert.Empty(t, filtered.Payments)
+
+	// Test case 4: other merchants do not see the payments
+	other, err := services.ListPayments(merchant.ID+1, db.APIKeyModeTest, models.PaymentListRequest{Reference: reference})
+	assert.NoError(t, err)
+	assert.Empty(t, other.Payments)
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Card brands detected from the leading digits of the card number
+const (
+	BrandVisa       = "visa"
+	BrandMastercard = "mastercard"
+	BrandAmex       = "amex"
+	BrandDiscover   = "discover"
+	BrandJCB        = "jcb"
+	BrandDiners     = "diners"
+	BrandUnionPay   = "unionpay"
+	BrandMaestro    = "maestro"
+)
+
+// prefixRange matches card numbers whose first len(Low) digits lie between Low and High
+type prefixRange struct {
+	Low  string
+	High string
+}
+
+type brandRule struct {
+	Brand     string
+	Prefixes  []prefixRange
+	Lengths   []int
+	CVVDigits int
+}
+
+// brandRules are checked in order, narrower ranges come before the wider ranges they overlap
+var brandRules = []brandRule{
+	{BrandAmex, []prefixRange{{"34", "34"}, {"37", "37"}}, []int{15}, 4},
+	{BrandDiners, []prefixRange{{"300", "305"}, {"3095", "3095"}, {"36", "36"}, {"38", "39"}}, []int{14, 15, 16, 17, 18, 19}, 3},
+	{BrandJCB, []prefixRange{{"3528", "3589"}}, []int{16, 17, 18, 19}, 3},
+	{BrandVisa, []prefixRange{{"4", "4"}}, []int{13, 16, 19}, 3},
+	{BrandMaestro, []prefixRange{{"5018", "5018"}, {"5020", "5020"}, {"5038", "5038"}, {"5893", "5893"}, {"6304", "6304"}, {"6759", "6759"}, {"6761", "6763"}}, []int{12, 13, 14, 15, 16, 17, 18, 19}, 3},
+	{BrandMastercard, []prefixRange{{"51", "55"}, {"2221", "2720"}}, []int{16}, 3},
+	{BrandDiscover, []prefixRange{{"6011", "6011"}, {"622126", "622925"}, {"644", "649"}, {"65", "65"}}, []int{16, 17, 18, 19}, 3},
+	{BrandUnionPay, []prefixRange{{"62", "62"}, {"81", "81"}}, []int{16, 17, 18, 19}, 3},
+}
+
+func findBrandRule(cardNumber string) (brandRule, bool) {
+	for _, rule := range brandRules {
+		for _, prefix := range rule.Prefixes {
+			if len(cardNumber) < len(prefix.Low) {
+				continue
+			}
+			leading := cardNumber[:len(prefix.Low)]
+			if leading >= prefix.Low && leading <= prefix.High {
+				return rule, true
+			}
+		}
+	}
+	return brandRule{}, false
+}
+
+// NormalizeCardNumber removes the spaces card numbers are often written with
+func NormalizeCardNumber(cardNumber string) string {
+	return strings.ReplaceAll(cardNumber, " ", "")
+}
+
+// DetectBrand returns the brand of the card number, or "" when no brand uses its leading digits
+func DetectBrand(cardNumber string) string {
+	rule, ok := findBrandRule(NormalizeCardNumber(cardNumber))
+	if !ok {
+		return ""
+	}
+	return rule.Brand
+}
+
+// LuhnValid checks the Luhn checksum of a card number made only of digits
+func LuhnValid(cardNumber string) bool {
+	sum := 0
+	double := false
+	for i := len(cardNumber) - 1; i >= 0; i-- {
+		digit := int(cardNumber[i] - '0')
+		if digit < 0 || digit > 9 {
+			return false
+		}
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return len(cardNumber) > 0 && sum%10 == 0
+}
+
+// ValidateBrandCVV checks the CVV has as many digits as the brand uses, 4 for Amex and 3 otherwise
+func ValidateBrandCVV(brand string, cvv int) bool {
+	digits := 3
+	for _, rule := range brandRules {
+		if rule.Brand == brand {
+			digits = rule.CVVDigits
+		}
+	}
+	return cvv > 0 && len(strconv.Itoa(cvv)) == digits
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDetectBrand(t *testing.T) {
+	// Test case 1: test card numbers of every brand
+	assert.Equal(t, BrandVisa, DetectBrand("4111 1111 1111 1111"))
+	assert.Equal(t, BrandMastercard, DetectBrand("5555555555554444"))
+	assert.Equal(t, BrandMastercard, DetectBrand("2223003122003222"))
+	assert.Equal(t, BrandAmex, DetectBrand("378282246310005"))
+	assert.Equal(t, BrandDiscover, DetectBrand("6011111111111117"))
+	assert.Equal(t, BrandJCB, DetectBrand("3530111333300000"))
+	assert.Equal(t, BrandDiners, DetectBrand("30569309025904"))
+	assert.Equal(t, BrandUnionPay, DetectBrand("6200000000000005"))
+	assert.Equal(t, BrandMaestro, DetectBrand("6759649826438453"))
+
+	// Test case 2: overlapping ranges go to the narrower brand
+	assert.Equal(t, BrandDiscover, DetectBrand("6221260000000000"))
+	assert.Equal(t, BrandUnionPay, DetectBrand("6229260000000000"))
+	assert.Equal(t, BrandMaestro, DetectBrand("5018000000000009"))
+
+	// Test case 3: unknown leading digits
+	assert.Equal(t, "", DetectBrand("1234123412341234"))
+	assert.Equal(t, "", DetectBrand("2721000000000000"))
+	assert.Equal(t, "", DetectBrand(""))
+}
+
+func TestLuhnValid(t *testing.T) {
+	assert.Equal(t, true, LuhnValid("4242424242424242"))
+	assert.Equal(t, true, LuhnValid("378282246310005"))
+	assert.Equal(t, false, LuhnValid("4242424242424241"))
+	assert.Equal(t, false, LuhnValid("42424242424242a2"))
+	assert.Equal(t, false, LuhnValid(""))
+}
+
+func TestValidateBrandCVV(t *testing.T) {
+	// Test case 1: Amex uses a 4 digit CID
+	assert.Equal(t, true, ValidateBrandCVV(BrandAmex, 1234))
+	assert.Equal(t, false, ValidateBrandCVV(BrandAmex, 123))
+
+	// Test case 2: every other brand uses 3 digits
+	assert.Equal(t, true, ValidateBrandCVV(BrandVisa, 123))
+	assert.Equal(t, false, ValidateBrandCVV(BrandVisa, 1234))
+	assert.Equal(t, true, ValidateCVV("5555555555554444", 123))
+	assert.Equal(t, false, ValidateCVV("378282246310005", 123))
+	assert.Equal(t, false, ValidateBrandCVV(BrandVisa, 0))
+}
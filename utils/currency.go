@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+)
+
+// currencyInfo describes a supported currency: the number of decimal places of its minor unit
+// and the largest amount, in minor units, accepted for a single payment
+type currencyInfo struct {
+	exponent  int
+	maxAmount int64
+}
+
+// currencies maps the supported ISO 4217 currency codes to their minor unit and payment cap.
+// All amounts are stored in minor units, the caps are all roughly worth 1000 USD.
+var currencies = map[string]currencyInfo{
+	"AUD": {exponent: 2, maxAmount: 150000},
+	"BHD": {exponent: 3, maxAmount: 400000},
+	"CAD": {exponent: 2, maxAmount: 140000},
+	"CHF": {exponent: 2, maxAmount: 90000},
+	"CLP": {exponent: 0, maxAmount: 950000},
+	"CZK": {exponent: 2, maxAmount: 2300000},
+	"DKK": {exponent: 2, maxAmount: 700000},
+	"EUR": {exponent: 2, maxAmount: 100000},
+	"GBP": {exponent: 2, maxAmount: 100000},
+	"HUF": {exponent: 2, maxAmount: 38000000},
+	"ISK": {exponent: 0, maxAmount: 140000},
+	"JOD": {exponent: 3, maxAmount: 700000},
+	"JPY": {exponent: 0, maxAmount: 150000},
+	"KRW": {exponent: 0, maxAmount: 1400000},
+	"KWD": {exponent: 3, maxAmount: 300000},
+	"NOK": {exponent: 2, maxAmount: 1100000},
+	"OMR": {exponent: 3, maxAmount: 400000},
+	"PLN": {exponent: 2, maxAmount: 400000},
+	"SEK": {exponent: 2, maxAmount: 1100000},
+	"TND": {exponent: 3, maxAmount: 3100000},
+	"USD": {exponent: 2, maxAmount: 100000},
+	"VND": {exponent: 0, maxAmount: 25000000},
+}
+
+// CurrencyExponent returns the number of decimal places of the currency's minor unit
+func CurrencyExponent(currency string) (int, bool) {
+	info, ok := currencies[currency]
+	return info.exponent, ok
+}
+
+// MaxPaymentAmount returns the largest amount in minor units a single payment in the currency may have
+func MaxPaymentAmount(currency string) int64 {
+	return currencies[currency].maxAmount
+}
+
+// MinorUnitsPerMajor returns how many minor units make up one major unit of the currency, e.g. 100 for USD
+func MinorUnitsPerMajor(currency string) int64 {
+	factor := int64(1)
+	exponent, _ := CurrencyExponent(currency)
+	for i := 0; i < exponent; i++ {
+		factor *= 10
+	}
+	return factor
+}
+
+// FormatMinorUnits renders an amount in minor units as a decimal string, e.g. 1050 USD as "10.50"
+func FormatMinorUnits(amount int64, currency string) string {
+	exponent, _ := CurrencyExponent(currency)
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCurrencyExponent(t *testing.T) {
+	// Test case 1: currencies with zero, two and three decimal places
+	exponent, ok := CurrencyExponent("JPY")
+	assert.Equal(t, true, ok)
+	assert.Equal(t, 0, exponent)
+
+	exponent, ok = CurrencyExponent("USD")
+	assert.Equal(t, true, ok)
+	assert.Equal(t, 2, exponent)
+
+	exponent, ok = CurrencyExponent("KWD")
+	assert.Equal(t, true, ok)
+	assert.Equal(t, 3, exponent)
+
+	// Test case 2: unsupported currency
+	_, ok = CurrencyExponent("XYZ")
+	assert.Equal(t, false, ok)
+}
+
+func TestMinorUnitsPerMajor(t *testing.T) {
+	assert.Equal(t, int64(1), MinorUnitsPerMajor("JPY"))
+	assert.Equal(t, int64(100), MinorUnitsPerMajor("EUR"))
+	assert.Equal(t, int64(1000), MinorUnitsPerMajor("KWD"))
+}
+
+func TestFormatMinorUnits(t *testing.T) {
+	assert.Equal(t, "10.50", FormatMinorUnits(1050, "USD"))
+	assert.Equal(t, "0.05", FormatMinorUnits(5, "USD"))
+	assert.Equal(t, "1050", FormatMinorUnits(1050, "JPY"))
+	assert.Equal(t, "1.050", FormatMinorUnits(1050, "KWD"))
+	assert.Equal(t, "-0.001", FormatMinorUnits(-1, "KWD"))
+}
+
+func TestMaxPaymentAmount(t *testing.T) {
+	// Test case 1: caps are given in minor units of each currency
+	assert.Equal(t, int64(100000), MaxPaymentAmount("USD"))
+	assert.Equal(t, int64(150000), MaxPaymentAmount("JPY"))
+	assert.Equal(t, int64(300000), MaxPaymentAmount("KWD"))
+
+	// Test case 2: unsupported currency
+	assert.Equal(t, int64(0), MaxPaymentAmount("XYZ"))
+}
@@ -1,7 +1,6 @@
 package utils
 
 import (
-	"strings"
 	"time"
 )
 
@@ -14,19 +13,23 @@ func Contains(list []string, item string) bool {
 	return false
 }
 
+// ValidateCardNumber checks the card number belongs to a known brand, has a length the brand
+// issues and passes the Luhn check
 func ValidateCardNumber(cardNumber string) bool {
-	cardNumber = strings.ReplaceAll(cardNumber, " ", "")
-	if len(cardNumber) != 16 {
+	cardNumber = NormalizeCardNumber(cardNumber)
+	rule, ok := findBrandRule(cardNumber)
+	if !ok {
 		return false
 	}
 
-	for i := 0; i < 16; i++ {
-		if cardNumber[i] < '0' || cardNumber[i] > '9' {
-			return false
+	validLength := false
+	for _, length := range rule.Lengths {
+		if len(cardNumber) == length {
+			validLength = true
 		}
 	}
 
-	return true
+	return validLength && LuhnValid(cardNumber)
 }
 
 func ValidateExpiryDate(expMonth int, expYear int) bool {
@@ -38,7 +41,7 @@ func ValidateExpiryDate(expMonth int, expYear int) bool {
 	return true
 }
 
-func ValidateAmount(amount float64) bool {
+func ValidateAmount(amount int64) bool {
 	if amount <= 0 {
 		return false
 	}
@@ -47,22 +50,18 @@ func ValidateAmount(amount float64) bool {
 }
 
 func ValidateCurrency(currency string) bool {
-	supportedCurrencies := []string{"USD", "EUR", "GBP"}
-	for _, c := range supportedCurrencies {
-		if c == currency {
-			return true
-		}
-	}
-
-	return false
+	_, ok := CurrencyExponent(currency)
+	return ok
 }
 
-func ValidateCVV(cvv int) bool {
-	if cvv < 100 || cvv > 999 {
-		return false
-	}
+// ValidateCVV checks the CVV has as many digits as the brand of the card number uses
+func ValidateCVV(cardNumber string, cvv int) bool {
+	return ValidateBrandCVV(DetectBrand(cardNumber), cvv)
+}
 
-	return true
+// MaskLast4 masks a card number of which only the last four digits are known
+func MaskLast4(last4 string) string {
+	return "**** **** **** " + last4
 }
 
 // maskCardNumber function
@@ -26,20 +26,36 @@ func TestContains(t *testing.T) {
 
 func TestValidateCardNumber(t *testing.T) {
 	// Test case 1: valid card number
-	cardNumber := "2345 6789 0123 4567"
+	cardNumber := "4242 4242 4242 4242"
 	result := ValidateCardNumber(cardNumber)
 
 	assert.Equal(t, true, result)
 
 	// Test case 2: invalid length of card number
-	cardNumber = "23456789012345"
+	cardNumber = "42424242424242"
 	result = ValidateCardNumber(cardNumber)
 	assert.Equal(t, false, result)
 
 	// Test case 3: invalid characters in card number
-	cardNumber = "1234 5678 9012 3A6F"
+	cardNumber = "4242 4242 4242 4A4F"
 	result = ValidateCardNumber(cardNumber)
 	assert.Equal(t, false, result)
+
+	// Test case 4: failing Luhn check
+	cardNumber = "4242 4242 4242 4241"
+	result = ValidateCardNumber(cardNumber)
+	assert.Equal(t, false, result)
+
+	// Test case 5: numbers of no known brand
+	cardNumber = "2345 6789 0123 4567"
+	result = ValidateCardNumber(cardNumber)
+	assert.Equal(t, false, result)
+
+	// Test case 6: brands with other lengths than 16 digits
+	assert.Equal(t, true, ValidateCardNumber("378282246310005"))
+	assert.Equal(t, true, ValidateCardNumber("30569309025904"))
+	assert.Equal(t, true, ValidateCardNumber("6759649826438453"))
+	assert.Equal(t, false, ValidateCardNumber("3782822463100050"))
 }
 
 func TestValidateExpiryDate(t *testing.T) {
@@ -65,17 +81,17 @@ func TestValidateExpiryDate(t *testing.T) {
 
 func TestValidateAmount(t *testing.T) {
 	// Test case 1: valid amount
-	amount := 100.0
+	amount := int64(10000)
 	result := ValidateAmount(amount)
 	assert.Equal(t, true, result)
 
 	// Test case 2: invalid amount (0)
-	amount = 0.0
+	amount = 0
 	result = ValidateAmount(amount)
 	assert.Equal(t, false, result)
 
 	// Test case 3: invalid amount (-100)
-	amount = -100.0
+	amount = -10000
 	result = ValidateAmount(amount)
 	assert.Equal(t, false, result)
 }
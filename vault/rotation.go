@@ -0,0 +1,175 @@
+package vault
+
+import (
+	"checkout-task/logger"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+)
+
+// Column is a database column holding values sealed by the vault
+type Column struct {
+	Table string
+	Name  string
+}
+
+func (c Column) String() string {
+	return c.Table + "." + c.Name
+}
+
+// RetiredKeysTable records the keys that no longer seal any stored value
+const RetiredKeysTable = "retired_vault_keys"
+
+// DisableRetiredKeys stops the vault from using the keys recorded as retired
+func (v *Vault) DisableRetiredKeys(db *gorm.DB) error {
+	var retiredKeyIDs []string
+	if err := db.Table(RetiredKeysTable).Pluck("key_id", &retiredKeyIDs).Error; err != nil {
+		return fmt.Errorf("cannot read retired keys: %w", err)
+	}
+
+	for _, keyID := range retiredKeyIDs {
+		if _, ok := v.keks[keyID]; !ok {
+			continue
+		}
+		if err := v.DisableKey(keyID); err != nil {
+			return fmt.Errorf("the active key %s has been retired", keyID)
+		}
+		logger.Info("Retired vault key is still configured, it can be removed from the vault keys", zap.String("key_id", keyID))
+	}
+	return nil
+}
+
+type sealedRow struct {
+	ID    int64
+	Value string
+}
+
+// pendingCondition selects the envelope encrypted values of the column not sealed under the active key.
+// Values from before envelope encryption are left to the encrypt-card-data migration.
+func (v *Vault) pendingCondition(column Column) string {
+	return fmt.Sprintf("%s LIKE 'env:%%' AND %s NOT LIKE 'env:%s:%%'", column.Name, column.Name, v.activeKeyID)
+}
+
+// Pending counts the values of the column that still need to be moved to the active key
+func (v *Vault) Pending(db *gorm.DB, column Column) (int, error) {
+	var count int
+	err := db.Table(column.Table).Where(v.pendingCondition(column)).Count(&count).Error
+	return count, err
+}
+
+// CountByKey counts the values of the column that depend on keyID. For LegacyKeyID this includes
+// every value without a key id, values that were never encrypted among them.
+func CountByKey(db *gorm.DB, column Column, keyID string) (int, error) {
+	condition := fmt.Sprintf("%s LIKE 'env:%s:%%'", column.Name, keyID)
+	if keyID == LegacyKeyID {
+		condition = fmt.Sprintf("%s OR (%s <> '' AND %s NOT LIKE 'env:%%:%%:%%')", condition, column.Name, column.Name)
+	}
+
+	var count int
+	err := db.Table(column.Table).Where(condition).Count(&count).Error
+	return count, err
+}
+
+// Rotate seals up to batchSize values of the column under the active key and returns how many it moved.
+// Rows changed in the meantime are skipped and picked up by the next batch.
+func (v *Vault) Rotate(db *gorm.DB, column Column, batchSize int) (int, error) {
+	var rows []sealedRow
+	if err := db.Table(column.Table).Select("id, " + column.Name + " AS value").
+		Where(v.pendingCondition(column)).
+		Order("id asc").Limit(batchSize).Scan(&rows).Error; err != nil {
+		return 0, fmt.Errorf("cannot read %s: %w", column, err)
+	}
+
+	rotated := 0
+	for _, row := range rows {
+		value, err := v.Reencrypt(row.Value)
+		if err != nil {
+			return rotated, fmt.Errorf("cannot re-encrypt %s of row %d: %w", column, row.ID, err)
+		}
+
+		result := db.Table(column.Table).Where("id = ? AND "+column.Name+" = ?", row.ID, row.Value).UpdateColumn(column.Name, value)
+		if result.Error != nil {
+			return rotated, fmt.Errorf("cannot update %s of row %d: %w", column, row.ID, result.Error)
+		}
+		rotated += int(result.RowsAffected)
+	}
+
+	return rotated, nil
+}
+
+// FingerprintColumn is a column holding the fingerprint of the card number sealed in another column of the row
+type FingerprintColumn struct {
+	Table       string
+	CardNumber  string
+	Fingerprint string
+}
+
+// Refingerprint recomputes the fingerprints of the column from the sealed card numbers, which is needed after
+// the fingerprint key changed, and returns how many it changed. Card numbers that were never encrypted are
+// left to the encrypt-card-data migration.
+func (v *Vault) Refingerprint(db *gorm.DB, column FingerprintColumn, batchSize int) (int, error) {
+	changed := 0
+	lastID := int64(0)
+	for {
+		var rows []struct {
+			ID          int64
+			CardNumber  string
+			Fingerprint string
+		}
+		if err := db.Table(column.Table).
+			Select("id, "+column.CardNumber+" AS card_number, "+column.Fingerprint+" AS fingerprint").
+			Where("id > ? AND "+column.CardNumber+" LIKE 'env:%'", lastID).
+			Order("id asc").Limit(batchSize).Scan(&rows).Error; err != nil {
+			return changed, fmt.Errorf("cannot read %s: %w", column.Table, err)
+		}
+		if len(rows) == 0 {
+			return changed, nil
+		}
+
+		for _, row := range rows {
+			lastID = row.ID
+			cardNumber, err := v.Decrypt(row.CardNumber)
+			if err != nil {
+				return changed, fmt.Errorf("cannot decrypt %s of row %d: %w", column.Table, row.ID, err)
+			}
+			fingerprint := v.Fingerprint(cardNumber)
+			if fingerprint == row.Fingerprint {
+				continue
+			}
+			if err := db.Table(column.Table).Where("id = ?", row.ID).UpdateColumn(column.Fingerprint, fingerprint).Error; err != nil {
+				return changed, fmt.Errorf("cannot update %s of row %d: %w", column.Table, row.ID, err)
+			}
+			changed++
+		}
+	}
+}
+
+// RotateColumns moves every value of the columns to the active key, batch by batch, logging its progress
+func (v *Vault) RotateColumns(db *gorm.DB, columns []Column, batchSize int) error {
+	for _, column := range columns {
+		pending, err := v.Pending(db, column)
+		if err != nil {
+			return fmt.Errorf("cannot count %s: %w", column, err)
+		}
+		if pending == 0 {
+			continue
+		}
+
+		logger.Info("Re-encrypting under the active key", zap.String("column", column.String()), zap.String("key_id", v.activeKeyID), zap.Int("pending", pending))
+		done := 0
+		for {
+			rotated, err := v.Rotate(db, column, batchSize)
+			if err != nil {
+				return err
+			}
+			if rotated == 0 {
+				break
+			}
+
+			done += rotated
+			logger.Info("Re-encryption progress", zap.String("column", column.String()), zap.Int("done", done), zap.Int("total", pending))
+		}
+	}
+
+	return nil
+}
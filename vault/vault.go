@@ -0,0 +1,276 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TokenPrefix starts every card token handed out by the vault
+const TokenPrefix = "tok_"
+
+// envelopePrefix starts values sealed with envelope encryption
+const envelopePrefix = "env:"
+
+// LegacyKeyID is the key values sealed before key ids were stored with them belong to,
+// the key formerly configured as VAULT_KEY
+const LegacyKeyID = "v1"
+
+var (
+	ErrInvalidCiphertext = errors.New("vault: invalid ciphertext")
+	ErrUnknownKey        = errors.New("vault: value is sealed under a key that is not configured")
+)
+
+var keyIDPattern = regexp.MustCompile("^[A-Za-z0-9]{1,32}$")
+
+// Vault encrypts card numbers with envelope encryption: every value is sealed with AES-256-GCM under
+// its own data key, and the data key is stored next to it wrapped by a key-encryption key (KEK).
+// Several KEK versions can be configured, values are sealed as "env:<key id>:<wrapped data key>:<ciphertext>"
+// under the active one and opened with whichever key their id names.
+// It also derives keyed fingerprints, so a card can be found again without decrypting every stored number.
+type Vault struct {
+	keks           map[string]cipher.AEAD
+	activeKeyID    string
+	fingerprintKey []byte
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns the nonce followed by the ciphertext of plaintext
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrInvalidCiphertext
+	}
+	plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrInvalidCiphertext
+	}
+	return plaintext, nil
+}
+
+// New creates a vault from 32 byte key-encryption keys by key id, sealing new values under
+// activeKeyID. Fingerprints are keyed with fingerprintKey, a key of its own that does not rotate with the KEKs.
+func New(keys map[string][]byte, activeKeyID string, fingerprintKey []byte) (*Vault, error) {
+	if _, ok := keys[activeKeyID]; !ok {
+		return nil, fmt.Errorf("vault: active key %q is not configured", activeKeyID)
+	}
+	if len(fingerprintKey) != 32 {
+		return nil, fmt.Errorf("vault: fingerprint key must be 32 bytes, got %d", len(fingerprintKey))
+	}
+	for keyID, key := range keys {
+		if hmac.Equal(key, fingerprintKey) {
+			return nil, fmt.Errorf("vault: fingerprint key must not be the same as key %s", keyID)
+		}
+	}
+
+	keks := make(map[string]cipher.AEAD, len(keys))
+	for keyID, key := range keys {
+		if !keyIDPattern.MatchString(keyID) {
+			return nil, fmt.Errorf("vault: key id %q must be letters and digits only", keyID)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("vault: key %s must be 32 bytes, got %d", keyID, len(key))
+		}
+		kek, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		keks[keyID] = kek
+	}
+
+	mac := hmac.New(sha256.New, fingerprintKey)
+	mac.Write([]byte("card fingerprint"))
+
+	return &Vault{keks: keks, activeKeyID: activeKeyID, fingerprintKey: mac.Sum(nil)}, nil
+}
+
+// NewFromConfig creates a vault from keys given as "<key id>:<base64 key>" separated by commas,
+// such as "v1:...,v2:...", and a base64 fingerprint key
+func NewFromConfig(keys string, activeKeyID string, fingerprintKey string) (*Vault, error) {
+	decodedKeys := map[string][]byte{}
+	for _, entry := range strings.Split(keys, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("vault: keys must be given as <key id>:<base64 key>")
+		}
+		if _, ok := decodedKeys[parts[0]]; ok {
+			return nil, fmt.Errorf("vault: key %s is configured twice", parts[0])
+		}
+		key, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("vault: key %s is not valid base64: %w", parts[0], err)
+		}
+		decodedKeys[parts[0]] = key
+	}
+
+	decodedFingerprintKey, err := base64.StdEncoding.DecodeString(fingerprintKey)
+	if err != nil {
+		return nil, fmt.Errorf("vault: fingerprint key is not valid base64: %w", err)
+	}
+
+	return New(decodedKeys, activeKeyID, decodedFingerprintKey)
+}
+
+// ActiveKeyID is the key new values are sealed under
+func (v *Vault) ActiveKeyID() string {
+	return v.activeKeyID
+}
+
+// KeyIDs lists the configured keys
+func (v *Vault) KeyIDs() []string {
+	keyIDs := make([]string, 0, len(v.keks))
+	for keyID := range v.keks {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+	return keyIDs
+}
+
+// DisableKey stops the vault from using a key, values sealed under it can no longer be opened
+func (v *Vault) DisableKey(keyID string) error {
+	if keyID == v.activeKeyID {
+		return fmt.Errorf("vault: key %s is the active key", keyID)
+	}
+	delete(v.keks, keyID)
+	return nil
+}
+
+// Encrypt seals plaintext under a new data key and returns "env:<key id>:<wrapped data key>:<ciphertext>",
+// the wrapped key and ciphertext base64 encoded
+func (v *Vault) Encrypt(plaintext string) (string, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := seal(aead, []byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+	wrappedKey, err := seal(v.keks[v.activeKeyID], dataKey)
+	if err != nil {
+		return "", err
+	}
+
+	return envelopePrefix + v.activeKeyID + ":" + base64.StdEncoding.EncodeToString(wrappedKey) + ":" + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverses Encrypt. Values sealed before key ids were stored with them, and values sealed
+// directly under the KEK as the vault did before envelope encryption, are opened with LegacyKeyID.
+func (v *Vault) Decrypt(ciphertext string) (string, error) {
+	if !IsEncrypted(ciphertext) {
+		kek, ok := v.keks[LegacyKeyID]
+		if !ok {
+			return "", ErrUnknownKey
+		}
+		sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+		if err != nil {
+			return "", ErrInvalidCiphertext
+		}
+		plaintext, err := open(kek, sealed)
+		return string(plaintext), err
+	}
+
+	keyID := KeyID(ciphertext)
+	parts := strings.Split(strings.TrimPrefix(ciphertext, envelopePrefix), ":")
+	if len(parts) == 3 {
+		parts = parts[1:]
+	}
+	if len(parts) != 2 {
+		return "", ErrInvalidCiphertext
+	}
+
+	kek, ok := v.keks[keyID]
+	if !ok {
+		return "", ErrUnknownKey
+	}
+	wrappedKey, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	sealed, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+
+	dataKey, err := open(kek, wrappedKey)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", ErrInvalidCiphertext
+	}
+	plaintext, err := open(aead, sealed)
+	return string(plaintext), err
+}
+
+// Reencrypt opens ciphertext and seals it again under the active key
+func (v *Vault) Reencrypt(ciphertext string) (string, error) {
+	plaintext, err := v.Decrypt(ciphertext)
+	if err != nil {
+		return "", err
+	}
+	return v.Encrypt(plaintext)
+}
+
+// IsEncrypted reports whether value was sealed with envelope encryption, anything else is
+// either plaintext or from before envelope encryption and needs to be migrated
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, envelopePrefix)
+}
+
+// KeyID returns the id of the key an envelope encrypted value is sealed under
+func KeyID(ciphertext string) string {
+	if !IsEncrypted(ciphertext) {
+		return ""
+	}
+	parts := strings.Split(strings.TrimPrefix(ciphertext, envelopePrefix), ":")
+	if len(parts) == 3 {
+		return parts[0]
+	}
+	return LegacyKeyID
+}
+
+// Fingerprint returns a keyed hash of the card number, equal card numbers give equal fingerprints
+func (v *Vault) Fingerprint(cardNumber string) string {
+	mac := hmac.New(sha256.New, v.fingerprintKey)
+	mac.Write([]byte(cardNumber))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewToken returns a random card token such as "tok_<32 hex characters>", it carries no card data
+func NewToken() (string, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	return TokenPrefix + hex.EncodeToString(random), nil
+}
@@ -0,0 +1,152 @@
+package vault
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var (
+	testKeyV1          = []byte("0123456789abcdef0123456789abcdef")
+	testKeyV2          = []byte("fedcba9876543210fedcba9876543210")
+	testFingerprintKey = []byte("ffffffffffffffffffffffffffffffff")
+)
+
+func testVault(t *testing.T) *Vault {
+	v, err := New(map[string][]byte{"v1": testKeyV1}, "v1", testFingerprintKey)
+	assert.NoError(t, err)
+	return v
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	v := testVault(t)
+
+	// Test case 1: a card number survives the round trip and is not readable in between
+	ciphertext, err := v.Encrypt("4242424242424242")
+	assert.NoError(t, err)
+	assert.NotContains(t, ciphertext, "4242424242424242")
+	assert.True(t, IsEncrypted(ciphertext))
+	assert.True(t, strings.HasPrefix(ciphertext, "env:v1:"))
+
+	plaintext, err := v.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "4242424242424242", plaintext)
+
+	// Test case 2: encrypting twice gives different ciphertexts
+	other, err := v.Encrypt("4242424242424242")
+	assert.NoError(t, err)
+	assert.NotEqual(t, ciphertext, other)
+
+	// Test case 3: tampered ciphertexts and other keys are rejected
+	_, err = v.Decrypt(ciphertext[:len(ciphertext)-4] + "AAAA")
+	assert.Equal(t, ErrInvalidCiphertext, err)
+	_, err = v.Decrypt("env:AAAA")
+	assert.Equal(t, ErrInvalidCiphertext, err)
+
+	otherVault, err := New(map[string][]byte{"v1": testKeyV2}, "v1", testFingerprintKey)
+	assert.NoError(t, err)
+	_, err = otherVault.Decrypt(ciphertext)
+	assert.Equal(t, ErrInvalidCiphertext, err)
+}
+
+func TestDecryptBeforeKeyIDs(t *testing.T) {
+	v := testVault(t)
+
+	// Test case 1: values sealed directly under the key encryption key are opened with the legacy key
+	sealed, err := seal(v.keks[LegacyKeyID], []byte("4242424242424242"))
+	assert.NoError(t, err)
+	legacy := base64.StdEncoding.EncodeToString(sealed)
+	assert.False(t, IsEncrypted(legacy))
+
+	plaintext, err := v.Decrypt(legacy)
+	assert.NoError(t, err)
+	assert.Equal(t, "4242424242424242", plaintext)
+
+	// Test case 2: so are envelopes without a key id
+	ciphertext, err := v.Encrypt("4242424242424242")
+	assert.NoError(t, err)
+	withoutKeyID := strings.Replace(ciphertext, "env:v1:", "env:", 1)
+	assert.Equal(t, LegacyKeyID, KeyID(withoutKeyID))
+
+	plaintext, err = v.Decrypt(withoutKeyID)
+	assert.NoError(t, err)
+	assert.Equal(t, "4242424242424242", plaintext)
+
+	// Test case 3: plaintext card numbers are not
+	assert.False(t, IsEncrypted("4242424242424242"))
+	assert.Equal(t, "", KeyID("4242424242424242"))
+	_, err = v.Decrypt("4242424242424242")
+	assert.Equal(t, ErrInvalidCiphertext, err)
+}
+
+func TestKeyRotation(t *testing.T) {
+	v1 := testVault(t)
+	ciphertext, err := v1.Encrypt("4242424242424242")
+	assert.NoError(t, err)
+
+	// Test case 1: after rotation new values are sealed under the new key, old ones still open
+	v2, err := New(map[string][]byte{"v1": testKeyV1, "v2": testKeyV2}, "v2", testFingerprintKey)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"v1", "v2"}, v2.KeyIDs())
+
+	plaintext, err := v2.Decrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "4242424242424242", plaintext)
+
+	rotated, err := v2.Reencrypt(ciphertext)
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", KeyID(rotated))
+
+	// Test case 2: fingerprints do not change with the key
+	assert.Equal(t, v1.Fingerprint("4242424242424242"), v2.Fingerprint("4242424242424242"))
+
+	// Test case 3: once the old key is disabled only rotated values open
+	assert.Error(t, v2.DisableKey("v2"))
+	assert.NoError(t, v2.DisableKey("v1"))
+	_, err = v2.Decrypt(ciphertext)
+	assert.Equal(t, ErrUnknownKey, err)
+	_, err = v2.Decrypt(rotated)
+	assert.NoError(t, err)
+}
+
+func TestFingerprint(t *testing.T) {
+	v := testVault(t)
+
+	assert.Equal(t, v.Fingerprint("4242424242424242"), v.Fingerprint("4242424242424242"))
+	assert.NotEqual(t, v.Fingerprint("4242424242424242"), v.Fingerprint("4000000000000002"))
+}
+
+func TestNewFromConfig(t *testing.T) {
+	keys := "v1:" + base64.StdEncoding.EncodeToString(testKeyV1) + ", v2:" + base64.StdEncoding.EncodeToString(testKeyV2)
+	fingerprintKey := base64.StdEncoding.EncodeToString(testFingerprintKey)
+
+	v, err := NewFromConfig(keys, "v2", fingerprintKey)
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", v.ActiveKeyID())
+
+	// Test case 1: the active key must be configured
+	_, err = NewFromConfig(keys, "v3", fingerprintKey)
+	assert.Error(t, err)
+
+	// Test case 2: keys must be 32 bytes of valid base64 under a plain id
+	_, err = NewFromConfig("v1:"+base64.StdEncoding.EncodeToString([]byte("too short")), "v1", fingerprintKey)
+	assert.Error(t, err)
+	_, err = NewFromConfig("v1:not base64!", "v1", fingerprintKey)
+	assert.Error(t, err)
+	_, err = NewFromConfig("v_1:"+base64.StdEncoding.EncodeToString(testKeyV1), "v_1", fingerprintKey)
+	assert.Error(t, err)
+	_, err = NewFromConfig(base64.StdEncoding.EncodeToString(testKeyV1), "v1", fingerprintKey)
+	assert.Error(t, err)
+
+	// Test case 3: the fingerprint key must not be one of the keys
+	_, err = NewFromConfig(keys, "v2", base64.StdEncoding.EncodeToString(testKeyV1))
+	assert.Error(t, err)
+}
+
+func TestNewToken(t *testing.T) {
+	token, err := NewToken()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(token, TokenPrefix))
+	assert.Len(t, token, len(TokenPrefix)+32)
+}